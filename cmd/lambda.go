@@ -2,14 +2,53 @@ package cmd
 
 import (
 	"github.com/Blue-Pix/abc/lib/lambda"
+	"github.com/Blue-Pix/abc/lib/lambda/concurrency"
+	"github.com/Blue-Pix/abc/lib/lambda/cost"
+	"github.com/Blue-Pix/abc/lib/lambda/exposure"
+	"github.com/Blue-Pix/abc/lib/lambda/idle"
+	"github.com/Blue-Pix/abc/lib/lambda/layers"
+	"github.com/Blue-Pix/abc/lib/lambda/logs"
+	"github.com/Blue-Pix/abc/lib/lambda/logs/retention"
+	"github.com/Blue-Pix/abc/lib/lambda/prune_versions"
+	"github.com/Blue-Pix/abc/lib/lambda/rightsize"
+	"github.com/Blue-Pix/abc/lib/lambda/scan_env"
 	"github.com/Blue-Pix/abc/lib/lambda/stats"
+	"github.com/Blue-Pix/abc/lib/lambda/storage"
+	"github.com/Blue-Pix/abc/lib/lambda/triggers"
+	"github.com/Blue-Pix/abc/lib/lambda/upgrade_runtime"
 )
 
 var lambdaCmd = lambda.NewCmd()
 var statsCmd = stats.NewCmd()
+var storageCmd = storage.NewCmd()
+var pruneVersionsCmd = prune_versions.NewCmd()
+var upgradeRuntimeCmd = upgrade_runtime.NewCmd()
+var layersCmd = layers.NewCmd()
+var idleCmd = idle.NewCmd()
+var costCmd = cost.NewCmd()
+var rightsizeCmd = rightsize.NewCmd()
+var scanEnvCmd = scan_env.NewCmd()
+var exposureCmd = exposure.NewCmd()
+var concurrencyCmd = concurrency.NewCmd()
+var triggersCmd = triggers.NewCmd()
+var logsCmd = logs.NewCmd()
+var logsRetentionCmd = retention.NewCmd()
 
 func init() {
 	lambdaCmd.SetOut(rootCmd.OutOrStdout())
 	rootCmd.AddCommand(lambdaCmd)
 	lambdaCmd.AddCommand(statsCmd)
+	lambdaCmd.AddCommand(storageCmd)
+	lambdaCmd.AddCommand(pruneVersionsCmd)
+	lambdaCmd.AddCommand(upgradeRuntimeCmd)
+	lambdaCmd.AddCommand(layersCmd)
+	lambdaCmd.AddCommand(idleCmd)
+	lambdaCmd.AddCommand(costCmd)
+	lambdaCmd.AddCommand(rightsizeCmd)
+	lambdaCmd.AddCommand(scanEnvCmd)
+	lambdaCmd.AddCommand(exposureCmd)
+	lambdaCmd.AddCommand(concurrencyCmd)
+	lambdaCmd.AddCommand(triggersCmd)
+	lambdaCmd.AddCommand(logsCmd)
+	logsCmd.AddCommand(logsRetentionCmd)
 }
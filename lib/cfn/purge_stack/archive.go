@@ -0,0 +1,150 @@
+package purge_stack
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// archive downloads current objects of the bucket before it is emptied.
+// Objects are saved under <archive-dir>/<bucket>/,
+// or into <archive-dir>/<bucket>.tar.gz with --archive-tar.
+func (h *S3Handler) archive(bucket string, objects []*s3Object, out io.Writer) error {
+	var current []*s3Object
+	for _, o := range objects {
+		if o.latest && !o.deleteMarker {
+			current = append(current, o)
+		}
+	}
+	var dest string
+	var err error
+	if archiveTar {
+		dest = filepath.Join(archiveDir, bucket+".tar.gz")
+		err = h.archiveTar(bucket, current, dest)
+	} else {
+		dest = filepath.Join(archiveDir, bucket)
+		err = h.archiveFiles(bucket, current, dest)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to archive %s: %s", bucket, err)
+	}
+	fmt.Fprintf(out, "Archived %d objects of %s to %s.\n", len(current), bucket, dest)
+	return nil
+}
+
+func (h *S3Handler) archiveFiles(bucket string, objects []*s3Object, dir string) error {
+	for _, o := range objects {
+		name := filepath.Join(dir, filepath.FromSlash(archivePath(o.key)))
+		// "folder" created by console.
+		if strings.HasSuffix(o.key, "/") {
+			if err := os.MkdirAll(name, 0755); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			return err
+		}
+		if err := h.download(bucket, o, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *S3Handler) download(bucket string, o *s3Object, name string) error {
+	body, _, err := h.getObject(bucket, o)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, body); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (h *S3Handler) archiveTar(bucket string, objects []*s3Object, file string) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gw)
+	for _, o := range objects {
+		name := archivePath(o.key)
+		if strings.HasSuffix(o.key, "/") {
+			if err := tw.WriteHeader(&tar.Header{Name: name + "/", Typeflag: tar.TypeDir, Mode: 0755}); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := h.writeTarEntry(tw, bucket, o, name); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gw.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func (h *S3Handler) writeTarEntry(tw *tar.Writer, bucket string, o *s3Object, name string) error {
+	body, size, err := h.getObject(bucket, o)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	if err := tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: size}); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, body)
+	return err
+}
+
+// getObject returns body and size of the object version.
+func (h *S3Handler) getObject(bucket string, o *s3Object) (io.ReadCloser, int64, error) {
+	params := &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(o.key),
+	}
+	// version id of objects put before versioning is enabled.
+	if o.versionId != "" && o.versionId != "null" {
+		params.VersionId = aws.String(o.versionId)
+	}
+	resp, err := h.Client.GetObject(params)
+	if err != nil {
+		return nil, 0, err
+	}
+	size := o.size
+	if resp.ContentLength != nil {
+		size = aws.Int64Value(resp.ContentLength)
+	}
+	return resp.Body, size, nil
+}
+
+// archivePath converts object key to relative path,
+// so that keys like "../foo" are not written outside of the archive.
+func archivePath(key string) string {
+	return strings.TrimPrefix(path.Clean("/"+key), "/")
+}
@@ -0,0 +1,118 @@
+package purge_stack
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/backup"
+	"github.com/aws/aws-sdk-go/service/backup/backupiface"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+// BackupVaultHandler deletes all recovery points in backup vaults,
+// which cannot be deleted while they contain recovery points.
+// It is opt-in, enabled by --drain-backup-vaults.
+type BackupVaultHandler struct {
+	Client backupiface.BackupAPI
+}
+
+func init() {
+	Register(&BackupVaultHandler{})
+}
+
+func (h *BackupVaultHandler) Name() string {
+	return "backup"
+}
+
+func (h *BackupVaultHandler) ResourceTypes() []string {
+	return []string{"AWS::Backup::BackupVault"}
+}
+
+func (h *BackupVaultHandler) OptIn() bool {
+	return true
+}
+
+func (h *BackupVaultHandler) InitClient(sess *session.Session) {
+	if h.Client == nil {
+		h.Client = backup.New(sess)
+	}
+}
+
+func (h *BackupVaultHandler) Plan(stack string, resource *cloudformation.StackResourceSummary) (*Task, error) {
+	points, err := h.listRecoveryPoints(aws.StringValue(resource.PhysicalResourceId))
+	if err != nil {
+		return nil, err
+	}
+	if len(points) == 0 {
+		return nil, nil
+	}
+	return &Task{
+		Handler:  h,
+		Stack:    stack,
+		Resource: resource,
+		Summary:  fmt.Sprintf("delete %d recovery points", len(points)),
+		Data:     points,
+	}, nil
+}
+
+// Clean deletes recovery points planned.
+// Child recovery points of composite backups are deleted before their parent.
+func (h *BackupVaultHandler) Clean(task *Task, out io.Writer) error {
+	vault := aws.StringValue(task.Resource.PhysicalResourceId)
+	points := task.Data.([]*backup.RecoveryPointByBackupVault)
+	sort.SliceStable(points, func(i, j int) bool {
+		return !aws.BoolValue(points[i].IsParent) && aws.BoolValue(points[j].IsParent)
+	})
+	deleted, failed := 0, 0
+	for _, point := range points {
+		_, err := h.Client.DeleteRecoveryPoint(&backup.DeleteRecoveryPointInput{
+			BackupVaultName:  aws.String(vault),
+			RecoveryPointArn: point.RecoveryPointArn,
+		})
+		// recovery points already deleted (e.g. by lifecycle) are ignored.
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == backup.ErrCodeResourceNotFoundException {
+			err = nil
+		}
+		if err != nil {
+			fmt.Fprintf(out, "Failed to delete recovery point %s: %s\n", aws.StringValue(point.RecoveryPointArn), err)
+			failed++
+			continue
+		}
+		deleted++
+		recordTask(task, &deletedItem{Kind: deletedRecoveryPoint, Id: aws.StringValue(point.RecoveryPointArn)})
+		fmt.Fprintf(out, "%s: %d/%d recovery points deleted.\n", vault, deleted, len(points))
+	}
+	if failed > 0 {
+		return errors.New(fmt.Sprintf("failed to delete recovery points of %s", vault))
+	}
+	return nil
+}
+
+func (h *BackupVaultHandler) Report(task *Task) string {
+	return fmt.Sprintf("All recovery points in %s successfully deleted.", aws.StringValue(task.Resource.PhysicalResourceId))
+}
+
+func (h *BackupVaultHandler) listRecoveryPoints(vault string) ([]*backup.RecoveryPointByBackupVault, error) {
+	var points []*backup.RecoveryPointByBackupVault
+	var token *string
+	for {
+		resp, err := h.Client.ListRecoveryPointsByBackupVault(&backup.ListRecoveryPointsByBackupVaultInput{
+			BackupVaultName: aws.String(vault),
+			NextToken:       token,
+		})
+		if err != nil {
+			return nil, err
+		}
+		points = append(points, resp.RecoveryPoints...)
+		if resp.NextToken == nil {
+			break
+		}
+		token = resp.NextToken
+	}
+	return points, nil
+}
@@ -0,0 +1,296 @@
+package purge_stack
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/aws/aws-sdk-go/service/ecr/ecriface"
+)
+
+const (
+	// BatchDeleteImage accepts at most 100 image ids at once.
+	ecrBatchDeleteLimit   = 100
+	ecrDefaultConcurrency = 4
+	ecrMaxRetries         = 3
+	ecrDefaultRetryWait   = 1 * time.Second
+)
+
+// media types of manifest list (image index), which reference child images.
+var imageIndexMediaTypes = []string{
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.index.v1+json",
+}
+
+// failure codes of BatchDeleteImage worth retrying.
+var retryableImageFailureCodes = []string{
+	ecr.ImageFailureCodeImageReferencedByManifestList,
+	ecr.ImageFailureCodeKmsError,
+}
+
+// EcrHandler deletes all images in ECR repository.
+// Image indexes are deleted before their children,
+// and images are deleted in chunks of 100 concurrently.
+type EcrHandler struct {
+	Client ecriface.ECRAPI
+	// Concurrency is the number of BatchDeleteImage calls in parallel. (default 4)
+	Concurrency int
+	// RetryWait is the interval before retrying failed images. (default 1s)
+	RetryWait time.Duration
+}
+
+// ecrImages is the plan of EcrHandler.
+type ecrImages struct {
+	indexes []*ecr.ImageIdentifier
+	images  []*ecr.ImageIdentifier
+	// image tags by digest, for report.
+	tags map[string][]*string
+}
+
+func init() {
+	Register(&EcrHandler{})
+}
+
+func (h *EcrHandler) Name() string {
+	return "ecr"
+}
+
+func (h *EcrHandler) ResourceTypes() []string {
+	return []string{"AWS::ECR::Repository"}
+}
+
+func (h *EcrHandler) InitClient(sess *session.Session) {
+	if h.Client == nil {
+		h.Client = ecr.New(sess)
+	}
+}
+
+func (h *EcrHandler) Plan(stack string, resource *cloudformation.StackResourceSummary) (*Task, error) {
+	details, err := h.listImages(nil, resource.PhysicalResourceId, []*ecr.ImageDetail{})
+	if err != nil {
+		return nil, err
+	}
+	if len(details) == 0 {
+		return nil, nil
+	}
+	data := &ecrImages{tags: make(map[string][]*string)}
+	for _, d := range details {
+		data.tags[aws.StringValue(d.ImageDigest)] = d.ImageTags
+		id := &ecr.ImageIdentifier{ImageDigest: d.ImageDigest}
+		if isImageIndex(d) {
+			data.indexes = append(data.indexes, id)
+		} else {
+			data.images = append(data.images, id)
+		}
+	}
+	summary := fmt.Sprintf("delete %d images", len(details))
+	if len(data.indexes) > 0 {
+		summary += fmt.Sprintf(" (including %d image indexes)", len(data.indexes))
+	}
+	return &Task{
+		Handler:  h,
+		Stack:    stack,
+		Resource: resource,
+		Summary:  summary,
+		Data:     data,
+	}, nil
+}
+
+func (h *EcrHandler) Clean(task *Task, out io.Writer) error {
+	repositoryName := task.Resource.PhysicalResourceId
+	data := task.Data.(*ecrImages)
+	progress := &ecrProgress{
+		out:            out,
+		task:           task,
+		repositoryName: aws.StringValue(repositoryName),
+		total:          len(data.indexes) + len(data.images),
+	}
+	// image index must be deleted first, as it refers to child images.
+	for _, images := range [][]*ecr.ImageIdentifier{data.indexes, data.images} {
+		failures, err := h.deleteImages(images, repositoryName, progress)
+		if err != nil {
+			return err
+		}
+		if len(failures) > 0 {
+			fmt.Fprintln(out, failures)
+			return errors.New(fmt.Sprintf("failed to delete images of %s", aws.StringValue(repositoryName)))
+		}
+	}
+	return nil
+}
+
+func (h *EcrHandler) Report(task *Task) string {
+	return fmt.Sprintf("All images in %s successfully deleted.", aws.StringValue(task.Resource.PhysicalResourceId))
+}
+
+func isImageIndex(detail *ecr.ImageDetail) bool {
+	for _, t := range imageIndexMediaTypes {
+		if aws.StringValue(detail.ImageManifestMediaType) == t {
+			return true
+		}
+	}
+	return false
+}
+
+func isRetryableImageFailure(failure *ecr.ImageFailure) bool {
+	for _, c := range retryableImageFailureCodes {
+		if aws.StringValue(failure.FailureCode) == c {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *EcrHandler) listImages(token *string, repositoryName *string, images []*ecr.ImageDetail) ([]*ecr.ImageDetail, error) {
+	params := &ecr.DescribeImagesInput{
+		NextToken:      token,
+		MaxResults:     aws.Int64(1000),
+		RepositoryName: repositoryName,
+	}
+	resp, err := h.Client.DescribeImages(params)
+	if err != nil {
+		return nil, err
+	}
+	images = append(images, resp.ImageDetails...)
+	if resp.NextToken != nil {
+		images, err = h.listImages(resp.NextToken, repositoryName, images)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return images, nil
+}
+
+// deleteImages deletes images in chunks concurrently.
+// Retryable failures are retried up to 3 times, and remaining failures are returned.
+func (h *EcrHandler) deleteImages(images []*ecr.ImageIdentifier, repositoryName *string, progress *ecrProgress) ([]*ecr.ImageFailure, error) {
+	var failures []*ecr.ImageFailure
+	for attempt := 0; len(images) > 0; attempt++ {
+		if attempt > 0 {
+			time.Sleep(h.retryWait() * time.Duration(attempt))
+		}
+		var err error
+		failures, err = h.deleteChunks(images, repositoryName, progress)
+		if err != nil {
+			return nil, err
+		}
+		if attempt == ecrMaxRetries {
+			break
+		}
+		images = nil
+		for _, f := range failures {
+			if !isRetryableImageFailure(f) {
+				return failures, nil
+			}
+			images = append(images, f.ImageId)
+		}
+	}
+	return failures, nil
+}
+
+func (h *EcrHandler) deleteChunks(images []*ecr.ImageIdentifier, repositoryName *string, progress *ecrProgress) ([]*ecr.ImageFailure, error) {
+	var chunks [][]*ecr.ImageIdentifier
+	for i := 0; i < len(images); i += ecrBatchDeleteLimit {
+		end := i + ecrBatchDeleteLimit
+		if end > len(images) {
+			end = len(images)
+		}
+		chunks = append(chunks, images[i:end])
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		failures []*ecr.ImageFailure
+		firstErr error
+	)
+	sem := make(chan struct{}, h.concurrency())
+	for _, chunk := range chunks {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(chunk []*ecr.ImageIdentifier) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			resp, err := h.Client.BatchDeleteImage(&ecr.BatchDeleteImageInput{
+				ImageIds:       chunk,
+				RepositoryName: repositoryName,
+			})
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				return
+			}
+			failed := make(map[string]bool)
+			chunkFailures := 0
+			for _, f := range resp.Failures {
+				if f.ImageId != nil {
+					failed[aws.StringValue(f.ImageId.ImageDigest)] = true
+				}
+				// already deleted, e.g. child image removed together with its index.
+				if aws.StringValue(f.FailureCode) == ecr.ImageFailureCodeImageNotFound {
+					continue
+				}
+				failures = append(failures, f)
+				chunkFailures++
+			}
+			var deleted []*ecr.ImageIdentifier
+			for _, id := range chunk {
+				if !failed[aws.StringValue(id.ImageDigest)] {
+					deleted = append(deleted, id)
+				}
+			}
+			progress.add(deleted, len(chunk)-chunkFailures)
+		}(chunk)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return failures, nil
+}
+
+func (h *EcrHandler) concurrency() int {
+	if h.Concurrency > 0 {
+		return h.Concurrency
+	}
+	return ecrDefaultConcurrency
+}
+
+func (h *EcrHandler) retryWait() time.Duration {
+	if h.RetryWait > 0 {
+		return h.RetryWait
+	}
+	return ecrDefaultRetryWait
+}
+
+// ecrProgress prints the number of deleted images per repository,
+// and records deleted images to report.
+// It is called by the caller holding the lock.
+type ecrProgress struct {
+	out            io.Writer
+	task           *Task
+	repositoryName string
+	total          int
+	deleted        int
+}
+
+// add records deleted images, and counts n images as done,
+// which include images not found.
+func (p *ecrProgress) add(deleted []*ecr.ImageIdentifier, n int) {
+	tags := p.task.Data.(*ecrImages).tags
+	for _, id := range deleted {
+		digest := aws.StringValue(id.ImageDigest)
+		recordTask(p.task, &deletedItem{Kind: deletedEcrImage, Id: digest, Tags: aws.StringValueSlice(tags[digest])})
+	}
+	p.deleted += n
+	fmt.Fprintf(p.out, "%s: %d/%d images deleted.\n", p.repositoryName, p.deleted, p.total)
+}
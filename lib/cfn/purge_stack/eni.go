@@ -0,0 +1,229 @@
+package purge_stack
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+)
+
+const (
+	eniDefaultPollInterval = 15 * time.Second
+	// Lambda may take up to 20 minutes to release hyperplane ENIs after functions are deleted.
+	eniDefaultTimeout = 20 * time.Minute
+)
+
+// EniHandler releases Lambda ENIs attached to security groups and subnets,
+// which cause DependencyViolation on their deletion.
+// ENIs in use are released by Lambda after the functions are deleted with the stack,
+// so it waits for them to be detached and deletes them while stack deletion is in progress.
+type EniHandler struct {
+	Client ec2iface.EC2API
+	// PollInterval is the interval to check ENIs status. (default 15s)
+	PollInterval time.Duration
+	// Timeout is how long to wait for Lambda ENIs to be detached. (default 20m)
+	Timeout time.Duration
+}
+
+func init() {
+	Register(&EniHandler{})
+}
+
+func (h *EniHandler) Name() string {
+	return "eni"
+}
+
+func (h *EniHandler) ResourceTypes() []string {
+	return []string{"AWS::EC2::SecurityGroup", "AWS::EC2::Subnet"}
+}
+
+func (h *EniHandler) InitClient(sess *session.Session) {
+	if h.Client == nil {
+		h.Client = ec2.New(sess)
+	}
+}
+
+func (h *EniHandler) Plan(stack string, resource *cloudformation.StackResourceSummary) (*Task, error) {
+	enis, err := h.listNetworkInterfaces(resource)
+	if err != nil {
+		return nil, err
+	}
+	if len(enis) == 0 {
+		return nil, nil
+	}
+	lambdas, others := splitLambdaEnis(enis)
+	var summaries []string
+	if len(lambdas) > 0 {
+		summaries = append(summaries, fmt.Sprintf("release %d Lambda ENIs", len(lambdas)))
+	}
+	if len(others) > 0 {
+		summaries = append(summaries, fmt.Sprintf("%d ENIs of others block deletion (%s)", len(others), ownersString(others)))
+	}
+	return &Task{
+		Handler:  h,
+		Stack:    stack,
+		Resource: resource,
+		Summary:  strings.Join(summaries, ", "),
+	}, nil
+}
+
+// Clean deletes Lambda ENIs already detached.
+func (h *EniHandler) Clean(task *Task, out io.Writer) error {
+	_, err := h.deleteAvailableEnis(task, out)
+	return err
+}
+
+func (h *EniHandler) Report(task *Task) string {
+	return fmt.Sprintf("Detached Lambda ENIs of %s deleted.", aws.StringValue(task.Resource.PhysicalResourceId))
+}
+
+// AfterDelete waits for Lambda ENIs to be detached, and deletes them.
+// ENIs which cannot be released are reported by owner.
+func (h *EniHandler) AfterDelete(task *Task, out io.Writer) error {
+	resourceId := aws.StringValue(task.Resource.PhysicalResourceId)
+	deadline := time.Now().Add(h.timeout())
+	for {
+		remaining, err := h.deleteAvailableEnis(task, out)
+		if err != nil {
+			return err
+		}
+		lambdas, others := splitLambdaEnis(remaining)
+		if len(lambdas) == 0 {
+			if len(others) > 0 {
+				fmt.Fprintf(out, "%s is still blocked by ENIs of others: %s\n", resourceId, ownersString(others))
+			} else {
+				fmt.Fprintf(out, "All Lambda ENIs of %s released.\n", resourceId)
+			}
+			return nil
+		}
+		if time.Now().After(deadline) {
+			fmt.Fprintf(out, "%s is still blocked by ENIs: %s\n", resourceId, ownersString(remaining))
+			return errors.New(fmt.Sprintf("timed out waiting for Lambda ENIs of %s to be detached", resourceId))
+		}
+		fmt.Fprintf(out, "Waiting for %d Lambda ENIs of %s to be detached...\n", len(lambdas), resourceId)
+		time.Sleep(h.pollInterval())
+	}
+}
+
+// deleteAvailableEnis deletes detached Lambda ENIs, and returns the rest.
+func (h *EniHandler) deleteAvailableEnis(task *Task, out io.Writer) ([]*ec2.NetworkInterface, error) {
+	enis, err := h.listNetworkInterfaces(task.Resource)
+	if err != nil {
+		return nil, err
+	}
+	var remaining []*ec2.NetworkInterface
+	for _, eni := range enis {
+		if !isLambdaEni(eni) || aws.StringValue(eni.Status) != ec2.NetworkInterfaceStatusAvailable {
+			remaining = append(remaining, eni)
+			continue
+		}
+		_, err := h.Client.DeleteNetworkInterface(&ec2.DeleteNetworkInterfaceInput{
+			NetworkInterfaceId: eni.NetworkInterfaceId,
+		})
+		if err != nil {
+			return nil, err
+		}
+		recordTask(task, &deletedItem{Kind: deletedNetworkInterface, Id: aws.StringValue(eni.NetworkInterfaceId)})
+		fmt.Fprintf(out, "Lambda ENI %s successfully deleted.\n", aws.StringValue(eni.NetworkInterfaceId))
+	}
+	return remaining, nil
+}
+
+func (h *EniHandler) listNetworkInterfaces(resource *cloudformation.StackResourceSummary) ([]*ec2.NetworkInterface, error) {
+	filterName := "group-id"
+	if aws.StringValue(resource.ResourceType) == "AWS::EC2::Subnet" {
+		filterName = "subnet-id"
+	}
+	var enis []*ec2.NetworkInterface
+	var token *string
+	for {
+		resp, err := h.Client.DescribeNetworkInterfaces(&ec2.DescribeNetworkInterfacesInput{
+			Filters: []*ec2.Filter{
+				{Name: aws.String(filterName), Values: []*string{resource.PhysicalResourceId}},
+			},
+			NextToken: token,
+		})
+		if err != nil {
+			return nil, err
+		}
+		enis = append(enis, resp.NetworkInterfaces...)
+		if resp.NextToken == nil {
+			break
+		}
+		token = resp.NextToken
+	}
+	return enis, nil
+}
+
+func (h *EniHandler) pollInterval() time.Duration {
+	if h.PollInterval > 0 {
+		return h.PollInterval
+	}
+	return eniDefaultPollInterval
+}
+
+func (h *EniHandler) timeout() time.Duration {
+	if h.Timeout > 0 {
+		return h.Timeout
+	}
+	return eniDefaultTimeout
+}
+
+func isLambdaEni(eni *ec2.NetworkInterface) bool {
+	return aws.StringValue(eni.InterfaceType) == ec2.NetworkInterfaceTypeLambda ||
+		strings.HasPrefix(aws.StringValue(eni.Description), "AWS Lambda VPC ENI")
+}
+
+func splitLambdaEnis(enis []*ec2.NetworkInterface) ([]*ec2.NetworkInterface, []*ec2.NetworkInterface) {
+	var lambdas, others []*ec2.NetworkInterface
+	for _, eni := range enis {
+		if isLambdaEni(eni) {
+			lambdas = append(lambdas, eni)
+		} else {
+			others = append(others, eni)
+		}
+	}
+	return lambdas, others
+}
+
+// eniOwner describes who uses the ENI.
+func eniOwner(eni *ec2.NetworkInterface) string {
+	switch {
+	case isLambdaEni(eni):
+		return "lambda"
+	case eni.Attachment != nil && aws.StringValue(eni.Attachment.InstanceId) != "":
+		return "instance " + aws.StringValue(eni.Attachment.InstanceId)
+	case aws.StringValue(eni.RequesterId) != "":
+		return aws.StringValue(eni.RequesterId)
+	case aws.StringValue(eni.InterfaceType) != "" && aws.StringValue(eni.InterfaceType) != ec2.NetworkInterfaceTypeInterface:
+		return aws.StringValue(eni.InterfaceType)
+	default:
+		return "account " + aws.StringValue(eni.OwnerId)
+	}
+}
+
+// ownersString groups ENIs by owner.
+// e.g. amazon-elb: eni-1, eni-2; instance i-xxx: eni-3
+func ownersString(enis []*ec2.NetworkInterface) string {
+	var owners []string
+	byOwner := make(map[string][]string)
+	for _, eni := range enis {
+		owner := eniOwner(eni)
+		if _, ok := byOwner[owner]; !ok {
+			owners = append(owners, owner)
+		}
+		byOwner[owner] = append(byOwner[owner], aws.StringValue(eni.NetworkInterfaceId))
+	}
+	var list []string
+	for _, owner := range owners {
+		list = append(list, fmt.Sprintf("%s: %s", owner, strings.Join(byOwner[owner], ", ")))
+	}
+	return strings.Join(list, "; ")
+}
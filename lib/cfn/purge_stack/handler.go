@@ -0,0 +1,119 @@
+package purge_stack
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+// Handler cleans up contents of a CloudFormation resource type,
+// which prevent the resource from being deleted.
+// Each handler has its own aws client.
+type Handler interface {
+	// Name is used for --handlers and --skip-handlers flags.
+	Name() string
+	// ResourceTypes are CloudFormation resource types the handler is responsible for.
+	// e.g. AWS::ECR::Repository
+	ResourceTypes() []string
+	// InitClient creates aws client unless it is already set.
+	InitClient(sess *session.Session)
+	// Plan returns what to do for the resource.
+	// Return nil if there is nothing to clean.
+	Plan(stack string, resource *cloudformation.StackResourceSummary) (*Task, error)
+	// Clean performs the task.
+	Clean(task *Task, out io.Writer) error
+	// Report returns the result of the task.
+	Report(task *Task) string
+}
+
+// AfterDeleter is implemented by handlers which have work to do
+// while stack deletion is in progress.
+type AfterDeleter interface {
+	AfterDelete(task *Task, out io.Writer) error
+}
+
+// Task is a unit of cleanup planned by the handler.
+type Task struct {
+	Handler  Handler
+	Stack    string
+	Resource *cloudformation.StackResourceSummary
+	// Summary describes the task in one line, which is shown in plan.
+	Summary string
+	// Data is handler specific.
+	Data interface{}
+}
+
+// OptInHandler is implemented by handlers which do not run by default.
+// They run only when enabled by their own flag or named in --handlers.
+type OptInHandler interface {
+	OptIn() bool
+}
+
+func isOptIn(h Handler) bool {
+	o, ok := h.(OptInHandler)
+	return ok && o.OptIn()
+}
+
+// handler registry, keyed by CloudFormation resource type.
+var registry = make(map[string]Handler)
+
+// Register adds handler to registry.
+// Handler for the same resource type is overwritten.
+func Register(h Handler) {
+	for _, t := range h.ResourceTypes() {
+		registry[t] = h
+	}
+}
+
+// handlerNames returns all names of registered handlers in sorted order.
+func handlerNames() []string {
+	var names []string
+	for name := range handlersByName() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func handlersByName() map[string]Handler {
+	byName := make(map[string]Handler)
+	for _, h := range registry {
+		byName[h.Name()] = h
+	}
+	return byName
+}
+
+// enabledHandlers returns handlers to run, keyed by resource type,
+// according to --handlers and --skip-handlers.
+// optIns are names of opt-in handlers enabled by their own flags.
+func enabledHandlers(only []string, skip []string, optIns []string) (map[string]Handler, error) {
+	byName := handlersByName()
+	for _, name := range append(append([]string{}, only...), skip...) {
+		if _, ok := byName[name]; !ok {
+			return nil, fmt.Errorf("unknown handler: %s (available: %v)", name, handlerNames())
+		}
+	}
+
+	enabled := make(map[string]Handler)
+	if len(only) == 0 {
+		for t, h := range registry {
+			if !isOptIn(h) {
+				enabled[t] = h
+			}
+		}
+	}
+	for _, name := range append(append([]string{}, only...), optIns...) {
+		for _, t := range byName[name].ResourceTypes() {
+			enabled[t] = byName[name]
+		}
+	}
+	for _, name := range skip {
+		for _, t := range byName[name].ResourceTypes() {
+			delete(enabled, t)
+		}
+	}
+	return enabled, nil
+}
@@ -0,0 +1,162 @@
+package purge_stack
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/cloudcontrolapi"
+	"github.com/aws/aws-sdk-go/service/cloudcontrolapi/cloudcontrolapiiface"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs/cloudwatchlogsiface"
+	"gopkg.in/yaml.v2"
+)
+
+var LogsClient cloudwatchlogsiface.CloudWatchLogsAPI
+var CloudControlClient cloudcontrolapiiface.CloudControlApiAPI
+
+// leftovers are things which remain after stack deletion.
+type leftovers struct {
+	// log groups created by Lambda at runtime, not by the stack.
+	logGroups []string
+	// resources with DeletionPolicy: Retain.
+	retained []*retainedResource
+}
+
+type retainedResource struct {
+	stack        string
+	logicalId    string
+	physicalId   string
+	resourceType string
+}
+
+// collectLeftovers records Lambda function names and retained resources of the stack tree.
+// It must be called before stack deletion.
+func collectLeftovers(tree *stackNode) (*leftovers, error) {
+	l := &leftovers{}
+	for _, node := range tree.postOrder() {
+		for _, r := range node.resources {
+			if aws.StringValue(r.ResourceType) == "AWS::Lambda::Function" && aws.StringValue(r.PhysicalResourceId) != "" {
+				l.logGroups = append(l.logGroups, "/aws/lambda/"+aws.StringValue(r.PhysicalResourceId))
+			}
+		}
+		retained, err := retainedLogicalIds(node.id)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range node.resources {
+			if !retained[aws.StringValue(r.LogicalResourceId)] || aws.StringValue(r.PhysicalResourceId) == "" {
+				continue
+			}
+			l.retained = append(l.retained, &retainedResource{
+				stack:        node.name,
+				logicalId:    aws.StringValue(r.LogicalResourceId),
+				physicalId:   aws.StringValue(r.PhysicalResourceId),
+				resourceType: aws.StringValue(r.ResourceType),
+			})
+		}
+	}
+	return l, nil
+}
+
+// retainedLogicalIds returns logical ids of resources which have DeletionPolicy: Retain in the template.
+// Processed template is used, so that resources generated by transform are also included.
+func retainedLogicalIds(stackId string) (map[string]bool, error) {
+	resp, err := CfnClient.GetTemplate(&cloudformation.GetTemplateInput{
+		StackName:     aws.String(stackId),
+		TemplateStage: aws.String(cloudformation.TemplateStageProcessed),
+	})
+	if err != nil {
+		return nil, err
+	}
+	// JSON is also parsed as YAML.
+	var template struct {
+		Resources map[string]struct {
+			DeletionPolicy string `yaml:"DeletionPolicy"`
+		} `yaml:"Resources"`
+	}
+	if err := yaml.Unmarshal([]byte(aws.StringValue(resp.TemplateBody)), &template); err != nil {
+		return nil, err
+	}
+	ids := make(map[string]bool)
+	for id, resource := range template.Resources {
+		if resource.DeletionPolicy == "Retain" || resource.DeletionPolicy == "RetainExceptOnCreate" {
+			ids[id] = true
+		}
+	}
+	return ids, nil
+}
+
+func (l *leftovers) String() string {
+	b := &strings.Builder{}
+	b.WriteString("Leftovers to clean up after deletion:\n")
+	if len(l.logGroups) == 0 && len(l.retained) == 0 {
+		b.WriteString("- nothing\n")
+	}
+	for _, name := range l.logGroups {
+		b.WriteString(fmt.Sprintf("- log group %s\n", name))
+	}
+	for _, r := range l.retained {
+		action := "keep"
+		if deleteRetained {
+			action = "delete"
+		}
+		b.WriteString(fmt.Sprintf("- retained %s %s %s (%s): %s\n", r.stack, r.logicalId, r.physicalId, r.resourceType, action))
+	}
+	return b.String()
+}
+
+// clean deletes leftovers. It must be called after stack deletion completes.
+// Retained resources are deleted via Cloud Control API only if --delete-retained is specified.
+func (l *leftovers) clean(out io.Writer) error {
+	failed := 0
+	sort.Strings(l.logGroups)
+	for _, name := range l.logGroups {
+		_, err := LogsClient.DeleteLogGroup(&cloudwatchlogs.DeleteLogGroupInput{LogGroupName: aws.String(name)})
+		if err != nil {
+			if aerr, ok := err.(awserr.Error); ok && aerr.Code() == cloudwatchlogs.ErrCodeResourceNotFoundException {
+				fmt.Fprintf(out, "Log group %s does not exist.\n", name)
+				continue
+			}
+			fmt.Fprintf(out, "Failed to delete log group %s: %s\n", name, err)
+			failed++
+			continue
+		}
+		record(&deletedItem{Kind: deletedLogGroup, Id: name})
+		fmt.Fprintf(out, "Log group %s successfully deleted.\n", name)
+	}
+	if !deleteRetained {
+		return nil
+	}
+	for _, r := range l.retained {
+		if err := deleteResource(r.resourceType, r.physicalId); err != nil {
+			fmt.Fprintf(out, "Failed to delete retained %s (%s): %s\n", r.physicalId, r.resourceType, err)
+			failed++
+			continue
+		}
+		record(&deletedItem{Stack: r.stack, ResourceType: r.resourceType, Kind: deletedRetainedResource, Id: r.physicalId})
+		fmt.Fprintf(out, "Retained %s (%s) successfully deleted.\n", r.physicalId, r.resourceType)
+	}
+	if failed > 0 {
+		return errors.New(fmt.Sprintf("failed to clean up %d leftovers", failed))
+	}
+	return nil
+}
+
+func deleteResource(resourceType string, identifier string) error {
+	resp, err := CloudControlClient.DeleteResource(&cloudcontrolapi.DeleteResourceInput{
+		TypeName:   aws.String(resourceType),
+		Identifier: aws.String(identifier),
+	})
+	if err != nil {
+		return err
+	}
+	return CloudControlClient.WaitUntilResourceRequestSuccess(&cloudcontrolapi.GetResourceRequestStatusInput{
+		RequestToken: resp.ProgressEvent.RequestToken,
+	})
+}
@@ -2,10 +2,32 @@ package purge_stack
 
 import (
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/backup"
+	"github.com/aws/aws-sdk-go/service/backup/backupiface"
+	"github.com/aws/aws-sdk-go/service/cloudcontrolapi"
+	"github.com/aws/aws-sdk-go/service/cloudcontrolapi/cloudcontrolapiiface"
 	"github.com/aws/aws-sdk-go/service/cloudformation"
 	"github.com/aws/aws-sdk-go/service/cloudformation/cloudformationiface"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs/cloudwatchlogsiface"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
 	"github.com/aws/aws-sdk-go/service/ecr"
 	"github.com/aws/aws-sdk-go/service/ecr/ecriface"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/elbv2/elbv2iface"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/aws/aws-sdk-go/service/route53/route53iface"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/aws/aws-sdk-go/service/sts/stsiface"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -32,6 +54,47 @@ func (client *MockCfnClient) DeleteStack(params *cloudformation.DeleteStackInput
 	}
 }
 
+func (client *MockCfnClient) DescribeStacks(params *cloudformation.DescribeStacksInput) (*cloudformation.DescribeStacksOutput, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*cloudformation.DescribeStacksOutput), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
+func (client *MockCfnClient) ListImports(params *cloudformation.ListImportsInput) (*cloudformation.ListImportsOutput, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*cloudformation.ListImportsOutput), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
+func (client *MockCfnClient) GetTemplate(params *cloudformation.GetTemplateInput) (*cloudformation.GetTemplateOutput, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*cloudformation.GetTemplateOutput), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
+func (client *MockCfnClient) UpdateTerminationProtection(params *cloudformation.UpdateTerminationProtectionInput) (*cloudformation.UpdateTerminationProtectionOutput, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*cloudformation.UpdateTerminationProtectionOutput), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
+func (client *MockCfnClient) WaitUntilStackDeleteComplete(params *cloudformation.DescribeStacksInput) error {
+	args := client.Called(params)
+	return args.Error(0)
+}
+
 type MockEcrClient struct {
 	mock.Mock
 	ecriface.ECRAPI
@@ -55,6 +118,273 @@ func (client *MockEcrClient) BatchDeleteImage(params *ecr.BatchDeleteImageInput)
 	}
 }
 
+type MockEc2Client struct {
+	mock.Mock
+	ec2iface.EC2API
+}
+
+func (client *MockEc2Client) DescribeNetworkInterfaces(params *ec2.DescribeNetworkInterfacesInput) (*ec2.DescribeNetworkInterfacesOutput, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*ec2.DescribeNetworkInterfacesOutput), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
+func (client *MockEc2Client) DeleteNetworkInterface(params *ec2.DeleteNetworkInterfaceInput) (*ec2.DeleteNetworkInterfaceOutput, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*ec2.DeleteNetworkInterfaceOutput), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
+type MockLogsClient struct {
+	mock.Mock
+	cloudwatchlogsiface.CloudWatchLogsAPI
+}
+
+func (client *MockLogsClient) DeleteLogGroup(params *cloudwatchlogs.DeleteLogGroupInput) (*cloudwatchlogs.DeleteLogGroupOutput, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*cloudwatchlogs.DeleteLogGroupOutput), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
+type MockCloudControlClient struct {
+	mock.Mock
+	cloudcontrolapiiface.CloudControlApiAPI
+}
+
+func (client *MockCloudControlClient) DeleteResource(params *cloudcontrolapi.DeleteResourceInput) (*cloudcontrolapi.DeleteResourceOutput, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*cloudcontrolapi.DeleteResourceOutput), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
+func (client *MockCloudControlClient) WaitUntilResourceRequestSuccess(params *cloudcontrolapi.GetResourceRequestStatusInput) error {
+	args := client.Called(params)
+	return args.Error(0)
+}
+
+type MockRdsClient struct {
+	mock.Mock
+	rdsiface.RDSAPI
+}
+
+func (client *MockRdsClient) DescribeDBInstances(params *rds.DescribeDBInstancesInput) (*rds.DescribeDBInstancesOutput, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*rds.DescribeDBInstancesOutput), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
+func (client *MockRdsClient) ModifyDBInstance(params *rds.ModifyDBInstanceInput) (*rds.ModifyDBInstanceOutput, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*rds.ModifyDBInstanceOutput), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
+func (client *MockRdsClient) DescribeDBClusters(params *rds.DescribeDBClustersInput) (*rds.DescribeDBClustersOutput, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*rds.DescribeDBClustersOutput), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
+func (client *MockRdsClient) ModifyDBCluster(params *rds.ModifyDBClusterInput) (*rds.ModifyDBClusterOutput, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*rds.ModifyDBClusterOutput), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
+type MockDynamoDBClient struct {
+	mock.Mock
+	dynamodbiface.DynamoDBAPI
+}
+
+func (client *MockDynamoDBClient) DescribeTable(params *dynamodb.DescribeTableInput) (*dynamodb.DescribeTableOutput, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*dynamodb.DescribeTableOutput), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
+func (client *MockDynamoDBClient) UpdateTable(params *dynamodb.UpdateTableInput) (*dynamodb.UpdateTableOutput, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*dynamodb.UpdateTableOutput), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
+type MockElbv2Client struct {
+	mock.Mock
+	elbv2iface.ELBV2API
+}
+
+func (client *MockElbv2Client) DescribeLoadBalancerAttributes(params *elbv2.DescribeLoadBalancerAttributesInput) (*elbv2.DescribeLoadBalancerAttributesOutput, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*elbv2.DescribeLoadBalancerAttributesOutput), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
+func (client *MockElbv2Client) ModifyLoadBalancerAttributes(params *elbv2.ModifyLoadBalancerAttributesInput) (*elbv2.ModifyLoadBalancerAttributesOutput, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*elbv2.ModifyLoadBalancerAttributesOutput), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
+type MockSecretsManagerClient struct {
+	mock.Mock
+	secretsmanageriface.SecretsManagerAPI
+}
+
+func (client *MockSecretsManagerClient) DescribeSecret(params *secretsmanager.DescribeSecretInput) (*secretsmanager.DescribeSecretOutput, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*secretsmanager.DescribeSecretOutput), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
+func (client *MockSecretsManagerClient) DeleteSecret(params *secretsmanager.DeleteSecretInput) (*secretsmanager.DeleteSecretOutput, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*secretsmanager.DeleteSecretOutput), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
+type MockBackupClient struct {
+	mock.Mock
+	backupiface.BackupAPI
+}
+
+func (client *MockBackupClient) ListRecoveryPointsByBackupVault(params *backup.ListRecoveryPointsByBackupVaultInput) (*backup.ListRecoveryPointsByBackupVaultOutput, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*backup.ListRecoveryPointsByBackupVaultOutput), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
+func (client *MockBackupClient) DeleteRecoveryPoint(params *backup.DeleteRecoveryPointInput) (*backup.DeleteRecoveryPointOutput, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*backup.DeleteRecoveryPointOutput), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
+type MockRoute53Client struct {
+	mock.Mock
+	route53iface.Route53API
+}
+
+func (client *MockRoute53Client) GetHostedZone(params *route53.GetHostedZoneInput) (*route53.GetHostedZoneOutput, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*route53.GetHostedZoneOutput), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
+func (client *MockRoute53Client) ListResourceRecordSets(params *route53.ListResourceRecordSetsInput) (*route53.ListResourceRecordSetsOutput, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*route53.ListResourceRecordSetsOutput), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
+func (client *MockRoute53Client) ChangeResourceRecordSets(params *route53.ChangeResourceRecordSetsInput) (*route53.ChangeResourceRecordSetsOutput, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*route53.ChangeResourceRecordSetsOutput), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
+type MockS3Client struct {
+	mock.Mock
+	s3iface.S3API
+}
+
+func (client *MockS3Client) ListObjectVersions(params *s3.ListObjectVersionsInput) (*s3.ListObjectVersionsOutput, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*s3.ListObjectVersionsOutput), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
+func (client *MockS3Client) DeleteObjects(params *s3.DeleteObjectsInput) (*s3.DeleteObjectsOutput, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*s3.DeleteObjectsOutput), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
+func (client *MockS3Client) GetObject(params *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*s3.GetObjectOutput), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
+type MockStsClient struct {
+	mock.Mock
+	stsiface.STSAPI
+}
+
+func (client *MockStsClient) GetCallerIdentity(params *sts.GetCallerIdentityInput) (*sts.GetCallerIdentityOutput, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*sts.GetCallerIdentityOutput), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
 func SetMockDefaultBehaviour(cm *MockCfnClient, em *MockEcrClient) {
 	stackName := "foo"
 	cm.On("ListStackResources", &cloudformation.ListStackResourcesInput{
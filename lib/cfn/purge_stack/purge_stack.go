@@ -3,21 +3,37 @@ package purge_stack
 import (
 	"errors"
 	"fmt"
+	"io"
+	"strings"
 
 	"github.com/Blue-Pix/abc/lib/util"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudcontrolapi"
 	"github.com/aws/aws-sdk-go/service/cloudformation"
 	"github.com/aws/aws-sdk-go/service/cloudformation/cloudformationiface"
-	"github.com/aws/aws-sdk-go/service/ecr"
-	"github.com/aws/aws-sdk-go/service/ecr/ecriface"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/sts"
 	"github.com/spf13/cobra"
 )
 
 var CfnClient cloudformationiface.CloudFormationAPI
-var EcrClient ecriface.ECRAPI
 
 var (
-	stackName string
+	stackName          string
+	stackNamePattern   string
+	tags               []string
+	handlers           []string
+	skipHandlers       []string
+	dryRun             bool
+	cleanLeftovers     bool
+	deleteRetained     bool
+	forceUnprotect     bool
+	forceDeleteSecrets bool
+	drainBackupVaults  bool
+	emptyBuckets       bool
+	reportFile         string
+	archiveDir         string
+	archiveTar         bool
 )
 
 func NewCmd() *cobra.Command {
@@ -29,20 +45,81 @@ func NewCmd() *cobra.Command {
 This command delete CloudFormation's stack,
 which delete-stack api provided by AWS officcially cannot to perform.
 For example, a stack which includes non-empty ECR repository.
+Nested stacks are also cleaned up before the root stack is deleted.
+
+Multiple stacks can be selected with --stack-name-pattern and --tag.
+They are deleted in the order that stacks importing other's exports come first,
+and stacks independent of each other are deleted concurrently.
+
+With --clean-leftovers, it waits for the deletion and then deletes what the stack leaves behind,
+which are log groups (/aws/lambda/<function>) created by Lambda functions at runtime,
+and with --delete-retained, resources with DeletionPolicy: Retain.
+
+Cleanup is performed by handlers for each resource type.
+- ecr: delete all images in AWS::ECR::Repository
+  (image indexes first, then 100 images per request in parallel)
+- eni: release Lambda ENIs attached to AWS::EC2::SecurityGroup and AWS::EC2::Subnet
+  (wait for them to be detached during deletion, and report other blockers by owner)
+- route53: delete record sets in AWS::Route53::HostedZone except SOA and NS of the zone apex
+  (100 changes per request)
+- unprotect: disable deletion protection of AWS::RDS::DBInstance, AWS::RDS::DBCluster,
+  AWS::DynamoDB::Table and AWS::ElasticLoadBalancingV2::LoadBalancer (only with --force-unprotect)
+- secret: delete AWS::SecretsManager::Secret without recovery window,
+  so that the same name can be reused immediately (only with --force-delete-secrets)
+- backup: delete all recovery points in AWS::Backup::BackupVault (only with --drain-backup-vaults)
+- s3: delete all objects including versions in AWS::S3::Bucket (only with --empty-buckets)
+
+With --report, what was deleted (ECR image digests and tags, S3 keys and versions, and so on)
+is written to the file as JSON, with timestamps and the caller identity.
+With --archive-dir, current objects of buckets are downloaded there before they are deleted.
+
+With --force-unprotect, termination protection of the stack is also disabled.
 
 Internally it uses aws cloudformation api.
 Please configure your aws credentials with following policies.
 - cloudformation:DeleteStack
+- cloudformation:DescribeStacks (with --stack-name-pattern or --tag)
+- cloudformation:ListImports (with --stack-name-pattern or --tag)
 - cloudformation:ListStackResources
+- cloudformation:GetTemplate (with --clean-leftovers)
+- logs:DeleteLogGroup (with --clean-leftovers)
+- cloudformation:DeleteResource, cloudformation:GetResourceRequestStatus and permissions to delete each resource (with --delete-retained)
 - ecr:BatchDeleteImages
-- ecr:DescribeImages`,
+- ecr:DescribeImages
+- ec2:DescribeNetworkInterfaces
+- ec2:DeleteNetworkInterface
+- route53:GetHostedZone
+- route53:ListResourceRecordSets
+- route53:ChangeResourceRecordSets
+- cloudformation:DescribeStacks, cloudformation:UpdateTerminationProtection (with --force-unprotect)
+- rds:DescribeDBInstances, rds:ModifyDBInstance, rds:DescribeDBClusters, rds:ModifyDBCluster (with --force-unprotect)
+- dynamodb:DescribeTable, dynamodb:UpdateTable (with --force-unprotect)
+- elasticloadbalancing:DescribeLoadBalancerAttributes, elasticloadbalancing:ModifyLoadBalancerAttributes (with --force-unprotect)
+- secretsmanager:DescribeSecret, secretsmanager:DeleteSecret (with --force-delete-secrets)
+- backup:ListRecoveryPointsByBackupVault, backup:DeleteRecoveryPoint (with --drain-backup-vaults)
+- s3:ListBucketVersions, s3:DeleteObject, s3:DeleteObjectVersion (with --empty-buckets)
+- s3:GetObject, s3:GetObjectVersion (with --archive-dir)
+- sts:GetCallerIdentity (with --report)`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			err := run(cmd, args)
 			return err
 		},
 	}
 	cmd.Flags().StringVar(&stackName, "stack-name", "", "stack name to delete")
-	cmd.MarkFlagRequired("stack-name")
+	cmd.Flags().StringVar(&stackNamePattern, "stack-name-pattern", "", "(optional) delete all stacks matched with glob, or regular expression surrounded by slash (e.g. /^dev-/)")
+	cmd.Flags().StringArrayVar(&tags, "tag", []string{}, "(optional) delete all stacks with the tag (key=value), can be repeated")
+	cmd.Flags().StringSliceVar(&handlers, "handlers", []string{}, fmt.Sprintf("(optional) run only these cleanup handlers, comma separated %v", handlerNames()))
+	cmd.Flags().StringSliceVar(&skipHandlers, "skip-handlers", []string{}, "(optional) skip these cleanup handlers, comma separated")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "(optional) show plan without deleting anything")
+	cmd.Flags().BoolVar(&forceUnprotect, "force-unprotect", false, "(optional) disable termination protection and deletion protection of resources just before deletion")
+	cmd.Flags().BoolVar(&forceDeleteSecrets, "force-delete-secrets", false, "(optional) delete secrets immediately without recovery window")
+	cmd.Flags().BoolVar(&drainBackupVaults, "drain-backup-vaults", false, "(optional) delete all recovery points in backup vaults")
+	cmd.Flags().BoolVar(&emptyBuckets, "empty-buckets", false, "(optional) delete all objects and versions in buckets")
+	cmd.Flags().StringVar(&archiveDir, "archive-dir", "", "(optional) with --empty-buckets, download objects into <dir>/<bucket>/ before deletion")
+	cmd.Flags().BoolVar(&archiveTar, "archive-tar", false, "(optional) with --archive-dir, archive objects into <dir>/<bucket>.tar.gz instead")
+	cmd.Flags().StringVar(&reportFile, "report", "", "(optional) write JSON report of deleted items to the file")
+	cmd.Flags().BoolVar(&cleanLeftovers, "clean-leftovers", false, "(optional) wait for deletion, and then delete log groups of Lambda functions in the stack")
+	cmd.Flags().BoolVar(&deleteRetained, "delete-retained", false, "(optional) with --clean-leftovers, also delete resources with DeletionPolicy: Retain")
 	return cmd
 }
 
@@ -50,113 +127,333 @@ func run(cmd *cobra.Command, args []string) error {
 	if err := ExecPurgeStack(cmd, args); err != nil {
 		return err
 	}
+	if dryRun || stackName == "" || cleanLeftovers {
+		return nil
+	}
 	cmd.Println("Perform delete-stack is in progress asynchronously.\nPlease check deletion status by yourself.")
 	return nil
 }
 
 func ExecPurgeStack(cmd *cobra.Command, args []string) error {
+	if stackName == "" && stackNamePattern == "" && len(tags) == 0 {
+		return errors.New("either --stack-name, --stack-name-pattern or --tag is required")
+	}
+	if stackName != "" && (stackNamePattern != "" || len(tags) > 0) {
+		return errors.New("--stack-name cannot be used with --stack-name-pattern or --tag")
+	}
+	if deleteRetained && !cleanLeftovers {
+		return errors.New("--delete-retained requires --clean-leftovers")
+	}
+	if archiveTar && archiveDir == "" {
+		return errors.New("--archive-tar requires --archive-dir")
+	}
 	initClient(cmd)
-	resources, err := listEcrResources(nil, []*cloudformation.StackResourceSummary{})
+	enabled, err := enabledHandlers(handlers, skipHandlers, optInHandlers())
 	if err != nil {
 		return err
 	}
-	for _, resource := range resources {
-		repositoryName := resource.PhysicalResourceId
-		images, err := listImageDigests(nil, repositoryName, []*ecr.ImageIdentifier{})
-		if err != nil {
+	if archiveDir != "" && !isEnabled(enabled, "s3") {
+		return errors.New("--archive-dir requires --empty-buckets")
+	}
+	audit = nil
+	if reportFile != "" {
+		if audit, err = newAuditReport(); err != nil {
 			return err
 		}
-		if len(images) == 0 {
-			continue
+	}
+	err = execPurge(cmd, enabled)
+	if audit != nil {
+		if werr := audit.write(reportFile, err); werr != nil && err == nil {
+			err = werr
 		}
-		failures, err := deleteImages(images, repositoryName)
-		if err != nil {
+	}
+	return err
+}
+
+func execPurge(cmd *cobra.Command, enabled map[string]Handler) error {
+	if stackName != "" {
+		return purgeStack(stackName, enabled, cmd.OutOrStdout(), false)
+	}
+
+	selector, err := newStackSelector(stackNamePattern, tags)
+	if err != nil {
+		return err
+	}
+	stacks, err := selectStacks(selector)
+	if err != nil {
+		return err
+	}
+	if len(stacks) == 0 {
+		cmd.Println("no stack found.")
+		return nil
+	}
+	results, err := purgeStacks(stacks, enabled, cmd.OutOrStdout())
+	if err != nil {
+		return err
+	}
+	cmd.Print(summaryString(results))
+	if n := failedCount(results); n > 0 {
+		return errFailedStacks(n)
+	}
+	return nil
+}
+
+// purgeStack cleans up the stack including nested stacks, and then deletes it.
+// If wait is true, it waits until the deletion completes.
+func purgeStack(name string, enabled map[string]Handler, out io.Writer, wait bool) error {
+	tree, err := buildStackTree(name)
+	if err != nil {
+		return err
+	}
+	fmt.Fprint(out, tree.String())
+
+	tasks, err := plan(tree, enabled)
+	if err != nil {
+		return err
+	}
+	fmt.Fprint(out, planString(tasks))
+	protected := false
+	if forceUnprotect {
+		if protected, err = isTerminationProtected(name); err != nil {
 			return err
 		}
-		if len(failures) > 0 {
-			cmd.Println(failures)
-			return errors.New(fmt.Sprintf("failed to delete images of %s", aws.StringValue(repositoryName)))
+		if protected {
+			fmt.Fprintf(out, "Termination protection of %s will be disabled.\n", tree.name)
 		}
-		cmd.Println(fmt.Sprintf("All images in %s successfully deleted.", aws.StringValue(repositoryName)))
+	}
+	var l *leftovers
+	if cleanLeftovers {
+		if l, err = collectLeftovers(tree); err != nil {
+			return err
+		}
+		fmt.Fprint(out, l.String())
+		// leftovers can be cleaned up only after deletion completes.
+		wait = true
+	}
+	if dryRun {
+		return nil
+	}
+	for _, task := range tasks {
+		if err := task.Handler.Clean(task, out); err != nil {
+			return err
+		}
+		fmt.Fprintln(out, task.Handler.Report(task))
 	}
 
-	if err = deleteStack(stackName); err != nil {
+	if protected {
+		if err = disableTerminationProtection(name); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Termination protection of %s disabled.\n", tree.name)
+	}
+	if err = deleteStack(name); err != nil {
 		return err
 	}
+	for _, task := range tasks {
+		if h, ok := task.Handler.(AfterDeleter); ok {
+			if err := h.AfterDelete(task, out); err != nil {
+				return err
+			}
+		}
+	}
+	if wait {
+		fmt.Fprintln(out, "Waiting for delete-stack to complete...")
+		if err = CfnClient.WaitUntilStackDeleteComplete(&cloudformation.DescribeStacksInput{StackName: aws.String(name)}); err != nil {
+			return err
+		}
+		fmt.Fprintln(out, "Stack successfully deleted.")
+	}
+	if l != nil {
+		if err = l.clean(out); err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
 
+// optInHandlers returns names of opt-in handlers enabled by flags.
+func optInHandlers() []string {
+	var names []string
+	if forceUnprotect {
+		names = append(names, "unprotect")
+	}
+	if forceDeleteSecrets {
+		names = append(names, "secret")
+	}
+	if drainBackupVaults {
+		names = append(names, "backup")
+	}
+	if emptyBuckets {
+		names = append(names, "s3")
+	}
+	return names
+}
+
+func isEnabled(enabled map[string]Handler, name string) bool {
+	for _, h := range enabled {
+		if h.Name() == name {
+			return true
+		}
+	}
+	return false
+}
+
 func initClient(cmd *cobra.Command) {
 	profile, _ := cmd.Flags().GetString("profile")
 	region, _ := cmd.Flags().GetString("region")
+	sess := util.CreateSession(profile, region)
 	if CfnClient == nil {
-		sess := util.CreateSession(profile, region)
 		CfnClient = cloudformation.New(sess)
 	}
-	if EcrClient == nil {
-		sess := util.CreateSession(profile, region)
-		EcrClient = ecr.New(sess)
+	if LogsClient == nil {
+		LogsClient = cloudwatchlogs.New(sess)
+	}
+	if CloudControlClient == nil {
+		CloudControlClient = cloudcontrolapi.New(sess)
+	}
+	if StsClient == nil {
+		StsClient = sts.New(sess)
+	}
+	for _, h := range registry {
+		h.InitClient(sess)
 	}
 }
 
-func listEcrResources(token *string, ecrs []*cloudformation.StackResourceSummary) ([]*cloudformation.StackResourceSummary, error) {
-	params := &cloudformation.ListStackResourcesInput{
-		NextToken: token,
-		StackName: aws.String(stackName),
+// plan collects tasks of each stack. Nested stacks come first, then their parent.
+func plan(tree *stackNode, enabled map[string]Handler) ([]*Task, error) {
+	var tasks []*Task
+	for _, node := range tree.postOrder() {
+		for _, resource := range node.resources {
+			h, ok := enabled[aws.StringValue(resource.ResourceType)]
+			if !ok {
+				continue
+			}
+			task, err := h.Plan(node.name, resource)
+			if err != nil {
+				return nil, err
+			}
+			if task != nil {
+				tasks = append(tasks, task)
+			}
+		}
 	}
-	resp, err := CfnClient.ListStackResources(params)
+	return tasks, nil
+}
+
+func planString(tasks []*Task) string {
+	if len(tasks) == 0 {
+		return "Nothing to clean up.\n"
+	}
+	b := &strings.Builder{}
+	b.WriteString("Plan:\n")
+	for _, task := range tasks {
+		b.WriteString(fmt.Sprintf("- [%s] %s %s (%s): %s\n",
+			task.Handler.Name(),
+			task.Stack,
+			aws.StringValue(task.Resource.PhysicalResourceId),
+			aws.StringValue(task.Resource.ResourceType),
+			task.Summary,
+		))
+	}
+	return b.String()
+}
+
+// stackNode is a stack with its resources and nested stacks.
+type stackNode struct {
+	id        string
+	name      string
+	resources []*cloudformation.StackResourceSummary
+	children  []*stackNode
+}
+
+// buildStackTree walks AWS::CloudFormation::Stack resources recursively.
+// stackId is either stack name or stack id (arn).
+func buildStackTree(stackId string) (*stackNode, error) {
+	resources, err := listStackResources(nil, stackId, []*cloudformation.StackResourceSummary{})
 	if err != nil {
 		return nil, err
 	}
-	for _, r := range resp.StackResourceSummaries {
-		if aws.StringValue(r.ResourceType) == "AWS::ECR::Repository" {
-			ecrs = append(ecrs, r)
-		}
+	node := &stackNode{
+		id:        stackId,
+		name:      stackNameFromId(stackId),
+		resources: resources,
 	}
-	if resp.NextToken != nil {
-		ecrs, err = listEcrResources(resp.NextToken, ecrs)
+	for _, r := range resources {
+		if aws.StringValue(r.ResourceType) != "AWS::CloudFormation::Stack" {
+			continue
+		}
+		// nested stack which failed to create has no physical id.
+		if aws.StringValue(r.PhysicalResourceId) == "" {
+			continue
+		}
+		child, err := buildStackTree(aws.StringValue(r.PhysicalResourceId))
 		if err != nil {
 			return nil, err
 		}
+		node.children = append(node.children, child)
 	}
-	return ecrs, nil
+	return node, nil
 }
 
-func listImageDigests(token *string, repositoryName *string, images []*ecr.ImageIdentifier) ([]*ecr.ImageIdentifier, error) {
-	params := &ecr.DescribeImagesInput{
-		NextToken:      token,
-		MaxResults:     aws.Int64(1000),
-		RepositoryName: repositoryName,
+// stackNameFromId extracts stack name from stack id.
+// e.g. arn:aws:cloudformation:ap-northeast-1:123456789012:stack/foo-Nested-XXXX/guid => foo-Nested-XXXX
+func stackNameFromId(stackId string) string {
+	if !strings.HasPrefix(stackId, "arn:") {
+		return stackId
 	}
-	resp, err := EcrClient.DescribeImages(params)
-	if err != nil {
-		return nil, err
+	parts := strings.Split(stackId, "/")
+	if len(parts) < 2 {
+		return stackId
 	}
-	for _, i := range resp.ImageDetails {
-		images = append(images, &ecr.ImageIdentifier{
-			ImageDigest: i.ImageDigest,
-		})
+	return parts[1]
+}
+
+// postOrder returns nodes in the order nested stacks come before its parent.
+func (node *stackNode) postOrder() []*stackNode {
+	var nodes []*stackNode
+	for _, child := range node.children {
+		nodes = append(nodes, child.postOrder()...)
 	}
-	if resp.NextToken != nil {
-		images, err = listImageDigests(resp.NextToken, repositoryName, images)
-		if err != nil {
-			return nil, err
+	return append(nodes, node)
+}
+
+func (node *stackNode) String() string {
+	b := &strings.Builder{}
+	b.WriteString(node.name + "\n")
+	node.writeChildren(b, "")
+	return b.String()
+}
+
+func (node *stackNode) writeChildren(b *strings.Builder, indent string) {
+	for i, child := range node.children {
+		if i == len(node.children)-1 {
+			b.WriteString(indent + "└── " + child.name + "\n")
+			child.writeChildren(b, indent+"    ")
+		} else {
+			b.WriteString(indent + "├── " + child.name + "\n")
+			child.writeChildren(b, indent+"│   ")
 		}
 	}
-	return images, nil
 }
 
-func deleteImages(images []*ecr.ImageIdentifier, repositoryName *string) ([]*ecr.ImageFailure, error) {
-	params := &ecr.BatchDeleteImageInput{
-		ImageIds:       images,
-		RepositoryName: repositoryName,
+func listStackResources(token *string, stackId string, resources []*cloudformation.StackResourceSummary) ([]*cloudformation.StackResourceSummary, error) {
+	params := &cloudformation.ListStackResourcesInput{
+		NextToken: token,
+		StackName: aws.String(stackId),
 	}
-	resp, err := EcrClient.BatchDeleteImage(params)
+	resp, err := CfnClient.ListStackResources(params)
 	if err != nil {
 		return nil, err
 	}
-	return resp.Failures, nil
+	resources = append(resources, resp.StackResourceSummaries...)
+	if resp.NextToken != nil {
+		resources, err = listStackResources(resp.NextToken, stackId, resources)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return resources, nil
 }
 
 func deleteStack(stackName string) error {
@@ -167,5 +464,6 @@ func deleteStack(stackName string) error {
 	if err != nil {
 		return err
 	}
+	record(&deletedItem{Stack: stackName, ResourceType: "AWS::CloudFormation::Stack", Kind: deletedStack, Id: stackName})
 	return nil
 }
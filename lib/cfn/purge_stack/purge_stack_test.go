@@ -1,16 +1,36 @@
 package purge_stack_test
 
 import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/Blue-Pix/abc/lib/cfn/purge_stack"
+	"github.com/Blue-Pix/abc/lib/util/testutil"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/backup"
+	"github.com/aws/aws-sdk-go/service/cloudcontrolapi"
 	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/sts"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -18,7 +38,15 @@ import (
 func initMockClient(cm *purge_stack.MockCfnClient, em *purge_stack.MockEcrClient) {
 	purge_stack.SetMockDefaultBehaviour(cm, em)
 	purge_stack.CfnClient = cm
-	purge_stack.EcrClient = em
+	purge_stack.Register(&purge_stack.EcrHandler{Client: em})
+}
+
+// execute runs ExecPurgeStack with args, without the message of run about asynchronous deletion.
+func execute(args ...string) (string, error) {
+	cmd := purge_stack.NewCmd()
+	cmd.RunE = purge_stack.ExecPurgeStack
+	out, _, err := testutil.Execute(cmd, args...)
+	return out, err
 }
 
 func TestMain(m *testing.M) {
@@ -34,10 +62,7 @@ func TestExecPurgeStack(t *testing.T) {
 		em := &purge_stack.MockEcrClient{}
 		initMockClient(cm, em)
 
-		cmd := purge_stack.NewCmd()
-		cmd.Flags().Set("stack-name", stackName)
-		var args []string
-		err := purge_stack.ExecPurgeStack(cmd, args)
+		_, err := execute("--stack-name", stackName)
 
 		assert.Nil(t, err)
 		cm.AssertNumberOfCalls(t, "ListStackResources", 2)
@@ -63,10 +88,7 @@ func TestExecPurgeStack(t *testing.T) {
 		em := &purge_stack.MockEcrClient{}
 		initMockClient(cm, em)
 
-		cmd := purge_stack.NewCmd()
-		cmd.Flags().Set("stack-name", stackName)
-		var args []string
-		err := purge_stack.ExecPurgeStack(cmd, args)
+		_, err := execute("--stack-name", stackName)
 
 		assert.Nil(t, err)
 		cm.AssertNumberOfCalls(t, "ListStackResources", 1)
@@ -75,6 +97,126 @@ func TestExecPurgeStack(t *testing.T) {
 		cm.AssertNumberOfCalls(t, "DeleteStack", 1)
 	})
 
+	t.Run("nested stacks", func(t *testing.T) {
+		stackName := "parent"
+		childId := "arn:aws:cloudformation:ap-northeast-1:123456789012:stack/parent-Child-AAA/guid1"
+		grandChildId := "arn:aws:cloudformation:ap-northeast-1:123456789012:stack/parent-Child-AAA-GrandChild-BBB/guid2"
+		cm := &purge_stack.MockCfnClient{}
+		cm.On("ListStackResources", &cloudformation.ListStackResourcesInput{
+			StackName: aws.String(stackName),
+		}).Return(
+			&cloudformation.ListStackResourcesOutput{
+				StackResourceSummaries: []*cloudformation.StackResourceSummary{
+					{PhysicalResourceId: aws.String(childId), ResourceType: aws.String("AWS::CloudFormation::Stack")},
+					{PhysicalResourceId: aws.String("ecr2"), ResourceType: aws.String("AWS::ECR::Repository")},
+				},
+			},
+			nil,
+		)
+		cm.On("ListStackResources", &cloudformation.ListStackResourcesInput{
+			StackName: aws.String(childId),
+		}).Return(
+			&cloudformation.ListStackResourcesOutput{
+				StackResourceSummaries: []*cloudformation.StackResourceSummary{
+					{PhysicalResourceId: aws.String("ecr1"), ResourceType: aws.String("AWS::ECR::Repository")},
+					{PhysicalResourceId: aws.String(grandChildId), ResourceType: aws.String("AWS::CloudFormation::Stack")},
+				},
+			},
+			nil,
+		)
+		cm.On("ListStackResources", &cloudformation.ListStackResourcesInput{
+			StackName: aws.String(grandChildId),
+		}).Return(
+			&cloudformation.ListStackResourcesOutput{
+				StackResourceSummaries: []*cloudformation.StackResourceSummary{
+					{PhysicalResourceId: aws.String("queue"), ResourceType: aws.String("AWS::SQS::Queue")},
+				},
+			},
+			nil,
+		)
+		cm.On("DeleteStack", &cloudformation.DeleteStackInput{StackName: aws.String(stackName)}).Return(&cloudformation.DeleteStackOutput{}, nil)
+		em := &purge_stack.MockEcrClient{}
+		initMockClient(cm, em)
+
+		out, err := execute("--stack-name", stackName)
+
+		expected := "parent\n"
+		expected += "└── parent-Child-AAA\n"
+		expected += "    └── parent-Child-AAA-GrandChild-BBB\n"
+		expected += "Plan:\n"
+		expected += "- [ecr] parent-Child-AAA ecr1 (AWS::ECR::Repository): delete 4 images\n"
+		expected += "ecr1: 4/4 images deleted.\n"
+		expected += "All images in ecr1 successfully deleted.\n"
+		assert.Nil(t, err)
+		assert.Equal(t, expected, out)
+		cm.AssertNumberOfCalls(t, "ListStackResources", 3)
+		em.AssertNumberOfCalls(t, "DescribeImages", 3)
+		em.AssertNumberOfCalls(t, "BatchDeleteImage", 1)
+		cm.AssertNumberOfCalls(t, "DeleteStack", 1)
+		cm.AssertCalled(t, "DeleteStack", &cloudformation.DeleteStackInput{StackName: aws.String(stackName)})
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		stackName := "foo"
+		cm := &purge_stack.MockCfnClient{}
+		em := &purge_stack.MockEcrClient{}
+		initMockClient(cm, em)
+
+		out, err := execute("--stack-name", stackName, "--dry-run")
+
+		expected := "foo\n"
+		expected += "Plan:\n"
+		expected += "- [ecr] foo ecr1 (AWS::ECR::Repository): delete 4 images\n"
+		assert.Nil(t, err)
+		assert.Equal(t, expected, out)
+		cm.AssertNumberOfCalls(t, "ListStackResources", 2)
+		em.AssertNumberOfCalls(t, "DescribeImages", 3)
+		em.AssertNumberOfCalls(t, "BatchDeleteImage", 0)
+		cm.AssertNumberOfCalls(t, "DeleteStack", 0)
+	})
+
+	t.Run("skip handlers", func(t *testing.T) {
+		stackName := "foo"
+		cm := &purge_stack.MockCfnClient{}
+		em := &purge_stack.MockEcrClient{}
+		initMockClient(cm, em)
+
+		_, err := execute("--stack-name", stackName, "--skip-handlers", "ecr")
+
+		assert.Nil(t, err)
+		cm.AssertNumberOfCalls(t, "ListStackResources", 2)
+		em.AssertNumberOfCalls(t, "DescribeImages", 0)
+		em.AssertNumberOfCalls(t, "BatchDeleteImage", 0)
+		cm.AssertNumberOfCalls(t, "DeleteStack", 1)
+	})
+
+	t.Run("only specified handlers", func(t *testing.T) {
+		stackName := "foo"
+		cm := &purge_stack.MockCfnClient{}
+		em := &purge_stack.MockEcrClient{}
+		initMockClient(cm, em)
+
+		_, err := execute("--stack-name", stackName, "--handlers", "ecr")
+
+		assert.Nil(t, err)
+		em.AssertNumberOfCalls(t, "DescribeImages", 3)
+		em.AssertNumberOfCalls(t, "BatchDeleteImage", 1)
+		cm.AssertNumberOfCalls(t, "DeleteStack", 1)
+	})
+
+	t.Run("unknown handler", func(t *testing.T) {
+		stackName := "foo"
+		cm := &purge_stack.MockCfnClient{}
+		initMockClient(cm, &purge_stack.MockEcrClient{})
+
+		_, err := execute("--stack-name", stackName, "--handlers", "hoge")
+
+		assert.True(t, strings.HasPrefix(err.Error(), "unknown handler: hoge (available: ["))
+		assert.Contains(t, err.Error(), " ecr ")
+		cm.AssertNumberOfCalls(t, "ListStackResources", 0)
+		cm.AssertNumberOfCalls(t, "DeleteStack", 0)
+	})
+
 	/************************************
 		Authorization Error
 	************************************/
@@ -88,10 +230,7 @@ func TestExecPurgeStack(t *testing.T) {
 		em := &purge_stack.MockEcrClient{}
 		initMockClient(cm, em)
 
-		cmd := purge_stack.NewCmd()
-		cmd.Flags().Set("stack-name", stackName)
-		var args []string
-		err := purge_stack.ExecPurgeStack(cmd, args)
+		_, err := execute("--stack-name", stackName)
 
 		assert.Equal(t, errorCode, err.(awserr.Error).Code())
 		assert.Equal(t, errorMsg, err.(awserr.Error).Message())
@@ -110,10 +249,7 @@ func TestExecPurgeStack(t *testing.T) {
 		em.On("DescribeImages", &ecr.DescribeImagesInput{NextToken: nil, MaxResults: aws.Int64(1000), RepositoryName: aws.String("ecr1")}).Return(nil, awserr.New(errorCode, errorMsg, errors.New("hoge")))
 		initMockClient(cm, em)
 
-		cmd := purge_stack.NewCmd()
-		cmd.Flags().Set("stack-name", stackName)
-		var args []string
-		err := purge_stack.ExecPurgeStack(cmd, args)
+		_, err := execute("--stack-name", stackName)
 
 		assert.Equal(t, errorCode, err.(awserr.Error).Code())
 		assert.Equal(t, errorMsg, err.(awserr.Error).Message())
@@ -140,15 +276,12 @@ func TestExecPurgeStack(t *testing.T) {
 		}).Return(nil, awserr.New(errorCode, errorMsg, errors.New("hoge")))
 		initMockClient(cm, em)
 
-		cmd := purge_stack.NewCmd()
-		cmd.Flags().Set("stack-name", stackName)
-		var args []string
-		err := purge_stack.ExecPurgeStack(cmd, args)
+		_, err := execute("--stack-name", stackName)
 
 		assert.Equal(t, errorCode, err.(awserr.Error).Code())
 		assert.Equal(t, errorMsg, err.(awserr.Error).Message())
 		cm.AssertNumberOfCalls(t, "ListStackResources", 2)
-		em.AssertNumberOfCalls(t, "DescribeImages", 2)
+		em.AssertNumberOfCalls(t, "DescribeImages", 3)
 		em.AssertNumberOfCalls(t, "BatchDeleteImage", 1)
 		cm.AssertNumberOfCalls(t, "DeleteStack", 0)
 	})
@@ -162,10 +295,7 @@ func TestExecPurgeStack(t *testing.T) {
 		em := &purge_stack.MockEcrClient{}
 		initMockClient(cm, em)
 
-		cmd := purge_stack.NewCmd()
-		cmd.Flags().Set("stack-name", stackName)
-		var args []string
-		err := purge_stack.ExecPurgeStack(cmd, args)
+		_, err := execute("--stack-name", stackName)
 
 		assert.Equal(t, errorCode, err.(awserr.Error).Code())
 		assert.Equal(t, errorMsg, err.(awserr.Error).Message())
@@ -184,10 +314,7 @@ func TestExecPurgeStack(t *testing.T) {
 		em := &purge_stack.MockEcrClient{}
 		initMockClient(cm, em)
 
-		cmd := purge_stack.NewCmd()
-		cmd.Flags().Set("stack-name", stackName)
-		var args []string
-		err := purge_stack.ExecPurgeStack(cmd, args)
+		_, err := execute("--stack-name", stackName)
 
 		assert.Equal(t, errorCode, err.(awserr.Error).Code())
 		assert.Equal(t, errorMsg, err.(awserr.Error).Message())
@@ -210,10 +337,7 @@ func TestExecPurgeStack(t *testing.T) {
 		em := &purge_stack.MockEcrClient{}
 		initMockClient(cm, em)
 
-		cmd := purge_stack.NewCmd()
-		cmd.Flags().Set("stack-name", stackName)
-		var args []string
-		err := purge_stack.ExecPurgeStack(cmd, args)
+		_, err := execute("--stack-name", stackName)
 
 		assert.Equal(t, errorCode, err.(awserr.Error).Code())
 		assert.Equal(t, errorMsg, err.(awserr.Error).Message())
@@ -242,15 +366,1085 @@ func TestExecPurgeStack(t *testing.T) {
 		)
 		initMockClient(cm, em)
 
-		cmd := purge_stack.NewCmd()
-		cmd.Flags().Set("stack-name", stackName)
-		var args []string
-		err := purge_stack.ExecPurgeStack(cmd, args)
+		_, err := execute("--stack-name", stackName)
 
 		assert.Equal(t, fmt.Sprintf("failed to delete images of %s", "ecr1"), err.Error())
 		cm.AssertNumberOfCalls(t, "ListStackResources", 2)
-		em.AssertNumberOfCalls(t, "DescribeImages", 2)
+		em.AssertNumberOfCalls(t, "DescribeImages", 3)
 		em.AssertNumberOfCalls(t, "BatchDeleteImage", 1)
 		cm.AssertNumberOfCalls(t, "DeleteStack", 0)
 	})
 }
+
+func TestEcrHandler(t *testing.T) {
+	stackName := "foo"
+	mockStack := func(cm *purge_stack.MockCfnClient) {
+		cm.On("ListStackResources", &cloudformation.ListStackResourcesInput{
+			StackName: aws.String(stackName),
+		}).Return(
+			&cloudformation.ListStackResourcesOutput{
+				StackResourceSummaries: []*cloudformation.StackResourceSummary{
+					{PhysicalResourceId: aws.String("ecr-big"), ResourceType: aws.String("AWS::ECR::Repository")},
+				},
+			},
+			nil,
+		)
+	}
+
+	t.Run("delete in chunks, image indexes first", func(t *testing.T) {
+		cm := &purge_stack.MockCfnClient{}
+		mockStack(cm)
+		em := &purge_stack.MockEcrClient{}
+		var details []*ecr.ImageDetail
+		for i := 0; i < 248; i++ {
+			details = append(details, &ecr.ImageDetail{
+				ImageDigest:            aws.String(fmt.Sprintf("image%d", i)),
+				ImageManifestMediaType: aws.String("application/vnd.docker.distribution.manifest.v2+json"),
+			})
+		}
+		details = append(details,
+			&ecr.ImageDetail{ImageDigest: aws.String("index1"), ImageManifestMediaType: aws.String("application/vnd.docker.distribution.manifest.list.v2+json")},
+			&ecr.ImageDetail{ImageDigest: aws.String("index2"), ImageManifestMediaType: aws.String("application/vnd.oci.image.index.v1+json")},
+		)
+		em.On("DescribeImages", &ecr.DescribeImagesInput{MaxResults: aws.Int64(1000), RepositoryName: aws.String("ecr-big")}).Return(
+			&ecr.DescribeImagesOutput{ImageDetails: details},
+			nil,
+		)
+		em.On("BatchDeleteImage", mock.AnythingOfType("*ecr.BatchDeleteImageInput")).Return(
+			&ecr.BatchDeleteImageOutput{Failures: []*ecr.ImageFailure{}},
+			nil,
+		)
+		purge_stack.SetMockDefaultBehaviour(cm, em)
+		purge_stack.CfnClient = cm
+		purge_stack.Register(&purge_stack.EcrHandler{Client: em, Concurrency: 2})
+
+		out, err := execute("--stack-name", stackName)
+
+		assert.Nil(t, err)
+		em.AssertNumberOfCalls(t, "BatchDeleteImage", 4)
+		first := em.Calls[1].Arguments.Get(0).(*ecr.BatchDeleteImageInput)
+		assert.Equal(t, []*ecr.ImageIdentifier{{ImageDigest: aws.String("index1")}, {ImageDigest: aws.String("index2")}}, first.ImageIds)
+		var sizes []int
+		for _, c := range em.Calls[2:] {
+			sizes = append(sizes, len(c.Arguments.Get(0).(*ecr.BatchDeleteImageInput).ImageIds))
+		}
+		assert.ElementsMatch(t, []int{100, 100, 48}, sizes)
+		assert.Contains(t, out, "- [ecr] foo ecr-big (AWS::ECR::Repository): delete 250 images (including 2 image indexes)\n")
+		assert.Contains(t, out, "ecr-big: 2/250 images deleted.\n")
+		assert.Contains(t, out, "ecr-big: 250/250 images deleted.\n")
+		cm.AssertNumberOfCalls(t, "DeleteStack", 1)
+	})
+
+	t.Run("retry on partial failure", func(t *testing.T) {
+		cm := &purge_stack.MockCfnClient{}
+		mockStack(cm)
+		em := &purge_stack.MockEcrClient{}
+		em.On("DescribeImages", &ecr.DescribeImagesInput{MaxResults: aws.Int64(1000), RepositoryName: aws.String("ecr-big")}).Return(
+			&ecr.DescribeImagesOutput{ImageDetails: []*ecr.ImageDetail{
+				{ImageDigest: aws.String("foo")},
+				{ImageDigest: aws.String("bar")},
+			}},
+			nil,
+		)
+		em.On("BatchDeleteImage", mock.AnythingOfType("*ecr.BatchDeleteImageInput")).Return(
+			&ecr.BatchDeleteImageOutput{Failures: []*ecr.ImageFailure{
+				{FailureCode: aws.String(ecr.ImageFailureCodeImageReferencedByManifestList), ImageId: &ecr.ImageIdentifier{ImageDigest: aws.String("bar")}},
+			}},
+			nil,
+		).Once()
+		em.On("BatchDeleteImage", &ecr.BatchDeleteImageInput{
+			ImageIds:       []*ecr.ImageIdentifier{{ImageDigest: aws.String("bar")}},
+			RepositoryName: aws.String("ecr-big"),
+		}).Return(
+			&ecr.BatchDeleteImageOutput{Failures: []*ecr.ImageFailure{}},
+			nil,
+		)
+		purge_stack.SetMockDefaultBehaviour(cm, em)
+		purge_stack.CfnClient = cm
+		purge_stack.Register(&purge_stack.EcrHandler{Client: em, RetryWait: time.Millisecond})
+
+		out, err := execute("--stack-name", stackName)
+
+		assert.Nil(t, err)
+		em.AssertNumberOfCalls(t, "BatchDeleteImage", 2)
+		assert.Contains(t, out, "ecr-big: 1/2 images deleted.\necr-big: 2/2 images deleted.\n")
+		cm.AssertNumberOfCalls(t, "DeleteStack", 1)
+	})
+
+	t.Run("progress after partial failure of earlier chunk", func(t *testing.T) {
+		cm := &purge_stack.MockCfnClient{}
+		mockStack(cm)
+		em := &purge_stack.MockEcrClient{}
+		var details []*ecr.ImageDetail
+		for i := 0; i < 150; i++ {
+			details = append(details, &ecr.ImageDetail{ImageDigest: aws.String(fmt.Sprintf("image%d", i))})
+		}
+		em.On("DescribeImages", &ecr.DescribeImagesInput{MaxResults: aws.Int64(1000), RepositoryName: aws.String("ecr-big")}).Return(
+			&ecr.DescribeImagesOutput{ImageDetails: details},
+			nil,
+		)
+		em.On("BatchDeleteImage", mock.AnythingOfType("*ecr.BatchDeleteImageInput")).Return(
+			&ecr.BatchDeleteImageOutput{Failures: []*ecr.ImageFailure{
+				{FailureCode: aws.String(ecr.ImageFailureCodeKmsError), ImageId: &ecr.ImageIdentifier{ImageDigest: aws.String("image0")}},
+			}},
+			nil,
+		).Once()
+		em.On("BatchDeleteImage", mock.AnythingOfType("*ecr.BatchDeleteImageInput")).Return(
+			&ecr.BatchDeleteImageOutput{Failures: []*ecr.ImageFailure{}},
+			nil,
+		)
+		purge_stack.SetMockDefaultBehaviour(cm, em)
+		purge_stack.CfnClient = cm
+		purge_stack.Register(&purge_stack.EcrHandler{Client: em, Concurrency: 1, RetryWait: time.Millisecond})
+
+		out, err := execute("--stack-name", stackName)
+
+		assert.Nil(t, err)
+		em.AssertNumberOfCalls(t, "BatchDeleteImage", 3)
+		assert.Contains(t, out, "ecr-big: 99/150 images deleted.\necr-big: 149/150 images deleted.\necr-big: 150/150 images deleted.\n")
+	})
+
+	t.Run("give up after retries", func(t *testing.T) {
+		cm := &purge_stack.MockCfnClient{}
+		mockStack(cm)
+		em := &purge_stack.MockEcrClient{}
+		em.On("DescribeImages", &ecr.DescribeImagesInput{MaxResults: aws.Int64(1000), RepositoryName: aws.String("ecr-big")}).Return(
+			&ecr.DescribeImagesOutput{ImageDetails: []*ecr.ImageDetail{{ImageDigest: aws.String("foo")}}},
+			nil,
+		)
+		em.On("BatchDeleteImage", mock.AnythingOfType("*ecr.BatchDeleteImageInput")).Return(
+			&ecr.BatchDeleteImageOutput{Failures: []*ecr.ImageFailure{
+				{FailureCode: aws.String(ecr.ImageFailureCodeKmsError), ImageId: &ecr.ImageIdentifier{ImageDigest: aws.String("foo")}},
+			}},
+			nil,
+		)
+		purge_stack.SetMockDefaultBehaviour(cm, em)
+		purge_stack.CfnClient = cm
+		purge_stack.Register(&purge_stack.EcrHandler{Client: em, RetryWait: time.Millisecond})
+
+		_, err := execute("--stack-name", stackName)
+
+		assert.EqualError(t, err, "failed to delete images of ecr-big")
+		em.AssertNumberOfCalls(t, "BatchDeleteImage", 4)
+		cm.AssertNumberOfCalls(t, "DeleteStack", 0)
+	})
+}
+
+func TestExecPurgeStackMultiple(t *testing.T) {
+	mockStacks := func(cm *purge_stack.MockCfnClient) {
+		cm.On("DescribeStacks", &cloudformation.DescribeStacksInput{}).Return(
+			&cloudformation.DescribeStacksOutput{
+				NextToken: aws.String("next_token"),
+				Stacks: []*cloudformation.Stack{
+					{
+						StackName: aws.String("dev-network"),
+						Tags:      []*cloudformation.Tag{{Key: aws.String("env"), Value: aws.String("dev")}},
+						Outputs:   []*cloudformation.Output{{OutputKey: aws.String("VpcId"), ExportName: aws.String("dev-vpc-id")}},
+					},
+					{
+						StackName: aws.String("dev-app"),
+						Tags:      []*cloudformation.Tag{{Key: aws.String("env"), Value: aws.String("dev")}},
+						Outputs:   []*cloudformation.Output{{OutputKey: aws.String("Url")}},
+					},
+					{
+						StackName: aws.String("dev-app-Nested-AAA"),
+						ParentId:  aws.String("arn:aws:cloudformation:ap-northeast-1:123456789012:stack/dev-app/guid"),
+						Tags:      []*cloudformation.Tag{{Key: aws.String("env"), Value: aws.String("dev")}},
+					},
+				},
+			},
+			nil,
+		)
+		cm.On("DescribeStacks", &cloudformation.DescribeStacksInput{NextToken: aws.String("next_token")}).Return(
+			&cloudformation.DescribeStacksOutput{
+				Stacks: []*cloudformation.Stack{
+					{
+						StackName: aws.String("dev-db"),
+						Tags:      []*cloudformation.Tag{{Key: aws.String("env"), Value: aws.String("dev")}},
+						Outputs:   []*cloudformation.Output{{OutputKey: aws.String("Endpoint"), ExportName: aws.String("dev-db-endpoint")}},
+					},
+					{
+						StackName: aws.String("prod-app"),
+						Tags:      []*cloudformation.Tag{{Key: aws.String("env"), Value: aws.String("prod")}},
+					},
+				},
+			},
+			nil,
+		)
+		cm.On("ListImports", &cloudformation.ListImportsInput{ExportName: aws.String("dev-vpc-id")}).Return(
+			&cloudformation.ListImportsOutput{Imports: []*string{aws.String("dev-app"), aws.String("dev-db")}},
+			nil,
+		)
+		cm.On("ListStackResources", mock.AnythingOfType("*cloudformation.ListStackResourcesInput")).Return(
+			&cloudformation.ListStackResourcesOutput{StackResourceSummaries: []*cloudformation.StackResourceSummary{}},
+			nil,
+		)
+		cm.On("DeleteStack", mock.AnythingOfType("*cloudformation.DeleteStackInput")).Return(&cloudformation.DeleteStackOutput{}, nil)
+		cm.On("WaitUntilStackDeleteComplete", mock.AnythingOfType("*cloudformation.DescribeStacksInput")).Return(nil)
+	}
+	deletedStacks := func(cm *purge_stack.MockCfnClient) []string {
+		var names []string
+		for _, c := range cm.Calls {
+			if c.Method == "DeleteStack" {
+				names = append(names, aws.StringValue(c.Arguments.Get(0).(*cloudformation.DeleteStackInput).StackName))
+			}
+		}
+		return names
+	}
+
+	t.Run("by glob pattern", func(t *testing.T) {
+		cm := &purge_stack.MockCfnClient{}
+		cm.On("ListImports", &cloudformation.ListImportsInput{ExportName: aws.String("dev-db-endpoint")}).Return(
+			nil,
+			awserr.New("ValidationError", "Export 'dev-db-endpoint' is not imported by any stack.", errors.New("hoge")),
+		)
+		mockStacks(cm)
+		initMockClient(cm, &purge_stack.MockEcrClient{})
+
+		out, err := execute("--stack-name-pattern", "dev-*")
+
+		assert.Nil(t, err)
+		deleted := deletedStacks(cm)
+		assert.ElementsMatch(t, []string{"dev-app", "dev-db"}, deleted[:2])
+		assert.Equal(t, "dev-network", deleted[2])
+		cm.AssertNumberOfCalls(t, "WaitUntilStackDeleteComplete", 3)
+		assert.Contains(t, out, "Deletion order:\n- dev-app, dev-db\n- dev-network\n")
+		assert.Contains(t, out, "[dev-network] Stack successfully deleted.\n")
+		expected := "|    STACK    | RESULT  | DETAIL |\n"
+		expected += "|-------------|---------|--------|\n"
+		expected += "| dev-app     | deleted |        |\n"
+		expected += "| dev-db      | deleted |        |\n"
+		expected += "| dev-network | deleted |        |\n"
+		assert.Contains(t, out, expected)
+	})
+
+	t.Run("by regular expression and tag", func(t *testing.T) {
+		cm := &purge_stack.MockCfnClient{}
+		mockStacks(cm)
+		initMockClient(cm, &purge_stack.MockEcrClient{})
+
+		_, err := execute("--stack-name-pattern", "/app$/", "--tag", "env=dev")
+
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"dev-app"}, deletedStacks(cm))
+		cm.AssertNumberOfCalls(t, "ListImports", 0)
+	})
+
+	t.Run("exports imported by unselected stack", func(t *testing.T) {
+		cm := &purge_stack.MockCfnClient{}
+		cm.On("ListImports", &cloudformation.ListImportsInput{ExportName: aws.String("dev-db-endpoint")}).Return(
+			&cloudformation.ListImportsOutput{Imports: []*string{aws.String("prod-app")}},
+			nil,
+		)
+		mockStacks(cm)
+		initMockClient(cm, &purge_stack.MockEcrClient{})
+
+		out, err := execute("--tag", "env=dev")
+
+		assert.EqualError(t, err, "2 stacks were not purged")
+		assert.Equal(t, []string{"dev-app"}, deletedStacks(cm))
+		assert.Contains(t, out, "| dev-db      | skipped | exports imported by unselected stacks: prod-app |\n")
+		assert.Contains(t, out, "| dev-network | skipped | importing stack dev-db was not deleted          |\n")
+	})
+
+	t.Run("invalid tag", func(t *testing.T) {
+		cm := &purge_stack.MockCfnClient{}
+		initMockClient(cm, &purge_stack.MockEcrClient{})
+
+		_, err := execute("--tag", "env")
+
+		assert.EqualError(t, err, "invalid tag: env (expected key=value)")
+		cm.AssertNumberOfCalls(t, "DescribeStacks", 0)
+	})
+
+	t.Run("no stack specified", func(t *testing.T) {
+		_, err := execute()
+
+		assert.EqualError(t, err, "either --stack-name, --stack-name-pattern or --tag is required")
+	})
+}
+
+func TestCleanLeftovers(t *testing.T) {
+	stackName := "foo"
+	template := `AWSTemplateFormatVersion: "2010-09-09"
+Resources:
+  Func1:
+    Type: AWS::Lambda::Function
+  Func2:
+    Type: AWS::Lambda::Function
+  Bucket:
+    Type: AWS::S3::Bucket
+    DeletionPolicy: Retain
+    Properties:
+      BucketName: !Sub ${AWS::StackName}-bucket
+  Queue:
+    Type: AWS::SQS::Queue
+    DeletionPolicy: Delete
+`
+	initLeftoverMocks := func(cm *purge_stack.MockCfnClient, lm *purge_stack.MockLogsClient, ccm *purge_stack.MockCloudControlClient) {
+		cm.On("ListStackResources", &cloudformation.ListStackResourcesInput{StackName: aws.String(stackName)}).Return(
+			&cloudformation.ListStackResourcesOutput{
+				StackResourceSummaries: []*cloudformation.StackResourceSummary{
+					{LogicalResourceId: aws.String("Func1"), PhysicalResourceId: aws.String("func1"), ResourceType: aws.String("AWS::Lambda::Function")},
+					{LogicalResourceId: aws.String("Func2"), PhysicalResourceId: aws.String("func2"), ResourceType: aws.String("AWS::Lambda::Function")},
+					{LogicalResourceId: aws.String("Bucket"), PhysicalResourceId: aws.String("foo-bucket"), ResourceType: aws.String("AWS::S3::Bucket")},
+					{LogicalResourceId: aws.String("Queue"), PhysicalResourceId: aws.String("https://sqs/queue"), ResourceType: aws.String("AWS::SQS::Queue")},
+				},
+			},
+			nil,
+		)
+		cm.On("GetTemplate", &cloudformation.GetTemplateInput{StackName: aws.String(stackName), TemplateStage: aws.String("Processed")}).Return(
+			&cloudformation.GetTemplateOutput{TemplateBody: aws.String(template)},
+			nil,
+		)
+		cm.On("WaitUntilStackDeleteComplete", &cloudformation.DescribeStacksInput{StackName: aws.String(stackName)}).Return(nil)
+		lm.On("DeleteLogGroup", &cloudwatchlogs.DeleteLogGroupInput{LogGroupName: aws.String("/aws/lambda/func1")}).Return(&cloudwatchlogs.DeleteLogGroupOutput{}, nil)
+		lm.On("DeleteLogGroup", &cloudwatchlogs.DeleteLogGroupInput{LogGroupName: aws.String("/aws/lambda/func2")}).Return(
+			nil,
+			awserr.New(cloudwatchlogs.ErrCodeResourceNotFoundException, "The specified log group does not exist.", errors.New("hoge")),
+		)
+		ccm.On("DeleteResource", &cloudcontrolapi.DeleteResourceInput{TypeName: aws.String("AWS::S3::Bucket"), Identifier: aws.String("foo-bucket")}).Return(
+			&cloudcontrolapi.DeleteResourceOutput{ProgressEvent: &cloudcontrolapi.ProgressEvent{RequestToken: aws.String("token")}},
+			nil,
+		)
+		ccm.On("WaitUntilResourceRequestSuccess", &cloudcontrolapi.GetResourceRequestStatusInput{RequestToken: aws.String("token")}).Return(nil)
+		purge_stack.LogsClient = lm
+		purge_stack.CloudControlClient = ccm
+	}
+
+	t.Run("clean log groups", func(t *testing.T) {
+		cm := &purge_stack.MockCfnClient{}
+		lm := &purge_stack.MockLogsClient{}
+		ccm := &purge_stack.MockCloudControlClient{}
+		initLeftoverMocks(cm, lm, ccm)
+		initMockClient(cm, &purge_stack.MockEcrClient{})
+
+		out, err := execute("--stack-name", stackName, "--clean-leftovers")
+
+		expected := "foo\n"
+		expected += "Nothing to clean up.\n"
+		expected += "Leftovers to clean up after deletion:\n"
+		expected += "- log group /aws/lambda/func1\n"
+		expected += "- log group /aws/lambda/func2\n"
+		expected += "- retained foo Bucket foo-bucket (AWS::S3::Bucket): keep\n"
+		expected += "Waiting for delete-stack to complete...\n"
+		expected += "Stack successfully deleted.\n"
+		expected += "Log group /aws/lambda/func1 successfully deleted.\n"
+		expected += "Log group /aws/lambda/func2 does not exist.\n"
+		assert.Nil(t, err)
+		assert.Equal(t, expected, out)
+		cm.AssertNumberOfCalls(t, "DeleteStack", 1)
+		cm.AssertNumberOfCalls(t, "WaitUntilStackDeleteComplete", 1)
+		lm.AssertNumberOfCalls(t, "DeleteLogGroup", 2)
+		ccm.AssertNumberOfCalls(t, "DeleteResource", 0)
+	})
+
+	t.Run("delete retained resources", func(t *testing.T) {
+		cm := &purge_stack.MockCfnClient{}
+		lm := &purge_stack.MockLogsClient{}
+		ccm := &purge_stack.MockCloudControlClient{}
+		initLeftoverMocks(cm, lm, ccm)
+		initMockClient(cm, &purge_stack.MockEcrClient{})
+
+		out, err := execute("--stack-name", stackName, "--clean-leftovers", "--delete-retained")
+
+		assert.Nil(t, err)
+		assert.Contains(t, out, "- retained foo Bucket foo-bucket (AWS::S3::Bucket): delete\n")
+		assert.Contains(t, out, "Retained foo-bucket (AWS::S3::Bucket) successfully deleted.\n")
+		lm.AssertNumberOfCalls(t, "DeleteLogGroup", 2)
+		ccm.AssertNumberOfCalls(t, "DeleteResource", 1)
+		ccm.AssertNumberOfCalls(t, "WaitUntilResourceRequestSuccess", 1)
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		cm := &purge_stack.MockCfnClient{}
+		lm := &purge_stack.MockLogsClient{}
+		ccm := &purge_stack.MockCloudControlClient{}
+		initLeftoverMocks(cm, lm, ccm)
+		initMockClient(cm, &purge_stack.MockEcrClient{})
+
+		_, err := execute("--stack-name", stackName, "--clean-leftovers", "--dry-run")
+
+		assert.Nil(t, err)
+		cm.AssertNumberOfCalls(t, "GetTemplate", 1)
+		cm.AssertNumberOfCalls(t, "DeleteStack", 0)
+		lm.AssertNumberOfCalls(t, "DeleteLogGroup", 0)
+	})
+
+	t.Run("delete retained without clean leftovers", func(t *testing.T) {
+		_, err := execute("--stack-name", stackName, "--delete-retained")
+
+		assert.EqualError(t, err, "--delete-retained requires --clean-leftovers")
+	})
+}
+
+func TestEniHandler(t *testing.T) {
+	stackName := "foo"
+	describeInput := &ec2.DescribeNetworkInterfacesInput{
+		Filters: []*ec2.Filter{{Name: aws.String("group-id"), Values: []*string{aws.String("sg-1")}}},
+	}
+	lambdaEni := func(status string) *ec2.NetworkInterface {
+		return &ec2.NetworkInterface{
+			NetworkInterfaceId: aws.String("eni-lambda"),
+			InterfaceType:      aws.String("lambda"),
+			Description:        aws.String("AWS Lambda VPC ENI-func1"),
+			Status:             aws.String(status),
+		}
+	}
+	elbEni := &ec2.NetworkInterface{
+		NetworkInterfaceId: aws.String("eni-elb"),
+		InterfaceType:      aws.String("interface"),
+		RequesterId:        aws.String("amazon-elb"),
+		Status:             aws.String("in-use"),
+	}
+	initEniMocks := func(cm *purge_stack.MockCfnClient, ec *purge_stack.MockEc2Client) {
+		cm.On("ListStackResources", &cloudformation.ListStackResourcesInput{StackName: aws.String(stackName)}).Return(
+			&cloudformation.ListStackResourcesOutput{
+				StackResourceSummaries: []*cloudformation.StackResourceSummary{
+					{LogicalResourceId: aws.String("SecurityGroup"), PhysicalResourceId: aws.String("sg-1"), ResourceType: aws.String("AWS::EC2::SecurityGroup")},
+				},
+			},
+			nil,
+		)
+		ec.On("DeleteNetworkInterface", &ec2.DeleteNetworkInterfaceInput{NetworkInterfaceId: aws.String("eni-lambda")}).Return(&ec2.DeleteNetworkInterfaceOutput{}, nil)
+		purge_stack.Register(&purge_stack.EniHandler{Client: ec, PollInterval: time.Millisecond, Timeout: 50 * time.Millisecond})
+	}
+
+	t.Run("wait for lambda eni to be detached", func(t *testing.T) {
+		cm := &purge_stack.MockCfnClient{}
+		ec := &purge_stack.MockEc2Client{}
+		// plan, clean, and first poll after delete-stack
+		ec.On("DescribeNetworkInterfaces", describeInput).Return(
+			&ec2.DescribeNetworkInterfacesOutput{NetworkInterfaces: []*ec2.NetworkInterface{lambdaEni("in-use"), elbEni}},
+			nil,
+		).Times(3)
+		ec.On("DescribeNetworkInterfaces", describeInput).Return(
+			&ec2.DescribeNetworkInterfacesOutput{NetworkInterfaces: []*ec2.NetworkInterface{lambdaEni("available"), elbEni}},
+			nil,
+		).Once()
+		initEniMocks(cm, ec)
+		initMockClient(cm, &purge_stack.MockEcrClient{})
+
+		out, err := execute("--stack-name", stackName)
+
+		expected := "foo\n"
+		expected += "Plan:\n"
+		expected += "- [eni] foo sg-1 (AWS::EC2::SecurityGroup): release 1 Lambda ENIs, 1 ENIs of others block deletion (amazon-elb: eni-elb)\n"
+		expected += "Detached Lambda ENIs of sg-1 deleted.\n"
+		expected += "Waiting for 1 Lambda ENIs of sg-1 to be detached...\n"
+		expected += "Lambda ENI eni-lambda successfully deleted.\n"
+		expected += "sg-1 is still blocked by ENIs of others: amazon-elb: eni-elb\n"
+		assert.Nil(t, err)
+		assert.Equal(t, expected, out)
+		ec.AssertNumberOfCalls(t, "DescribeNetworkInterfaces", 4)
+		ec.AssertNumberOfCalls(t, "DeleteNetworkInterface", 1)
+		cm.AssertNumberOfCalls(t, "DeleteStack", 1)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		cm := &purge_stack.MockCfnClient{}
+		ec := &purge_stack.MockEc2Client{}
+		ec.On("DescribeNetworkInterfaces", describeInput).Return(
+			&ec2.DescribeNetworkInterfacesOutput{NetworkInterfaces: []*ec2.NetworkInterface{lambdaEni("in-use")}},
+			nil,
+		)
+		initEniMocks(cm, ec)
+		initMockClient(cm, &purge_stack.MockEcrClient{})
+
+		out, err := execute("--stack-name", stackName)
+
+		assert.EqualError(t, err, "timed out waiting for Lambda ENIs of sg-1 to be detached")
+		assert.Contains(t, out, "sg-1 is still blocked by ENIs: lambda: eni-lambda\n")
+		ec.AssertNumberOfCalls(t, "DeleteNetworkInterface", 0)
+	})
+}
+
+func TestForceUnprotect(t *testing.T) {
+	stackName := "foo"
+	lbArn := "arn:aws:elasticloadbalancing:ap-northeast-1:123456789012:loadbalancer/app/foo-lb/abc"
+	initUnprotectMocks := func(cm *purge_stack.MockCfnClient, rm *purge_stack.MockRdsClient, dm *purge_stack.MockDynamoDBClient, lm *purge_stack.MockElbv2Client) {
+		cm.On("ListStackResources", &cloudformation.ListStackResourcesInput{StackName: aws.String(stackName)}).Return(
+			&cloudformation.ListStackResourcesOutput{
+				StackResourceSummaries: []*cloudformation.StackResourceSummary{
+					{PhysicalResourceId: aws.String("foo-db"), ResourceType: aws.String("AWS::RDS::DBInstance")},
+					{PhysicalResourceId: aws.String("foo-cluster"), ResourceType: aws.String("AWS::RDS::DBCluster")},
+					{PhysicalResourceId: aws.String("foo-table"), ResourceType: aws.String("AWS::DynamoDB::Table")},
+					{PhysicalResourceId: aws.String("foo-unprotected-table"), ResourceType: aws.String("AWS::DynamoDB::Table")},
+					{PhysicalResourceId: aws.String(lbArn), ResourceType: aws.String("AWS::ElasticLoadBalancingV2::LoadBalancer")},
+				},
+			},
+			nil,
+		)
+		cm.On("DescribeStacks", &cloudformation.DescribeStacksInput{StackName: aws.String(stackName)}).Return(
+			&cloudformation.DescribeStacksOutput{Stacks: []*cloudformation.Stack{{StackName: aws.String(stackName), EnableTerminationProtection: aws.Bool(true)}}},
+			nil,
+		)
+		cm.On("UpdateTerminationProtection", &cloudformation.UpdateTerminationProtectionInput{StackName: aws.String(stackName), EnableTerminationProtection: aws.Bool(false)}).Return(
+			&cloudformation.UpdateTerminationProtectionOutput{},
+			nil,
+		)
+		rm.On("DescribeDBInstances", &rds.DescribeDBInstancesInput{DBInstanceIdentifier: aws.String("foo-db")}).Return(
+			&rds.DescribeDBInstancesOutput{DBInstances: []*rds.DBInstance{{DeletionProtection: aws.Bool(true)}}},
+			nil,
+		)
+		rm.On("ModifyDBInstance", &rds.ModifyDBInstanceInput{DBInstanceIdentifier: aws.String("foo-db"), DeletionProtection: aws.Bool(false), ApplyImmediately: aws.Bool(true)}).Return(
+			&rds.ModifyDBInstanceOutput{},
+			nil,
+		)
+		rm.On("DescribeDBClusters", &rds.DescribeDBClustersInput{DBClusterIdentifier: aws.String("foo-cluster")}).Return(
+			&rds.DescribeDBClustersOutput{DBClusters: []*rds.DBCluster{{DeletionProtection: aws.Bool(true)}}},
+			nil,
+		)
+		rm.On("ModifyDBCluster", &rds.ModifyDBClusterInput{DBClusterIdentifier: aws.String("foo-cluster"), DeletionProtection: aws.Bool(false), ApplyImmediately: aws.Bool(true)}).Return(
+			&rds.ModifyDBClusterOutput{},
+			nil,
+		)
+		dm.On("DescribeTable", &dynamodb.DescribeTableInput{TableName: aws.String("foo-table")}).Return(
+			&dynamodb.DescribeTableOutput{Table: &dynamodb.TableDescription{DeletionProtectionEnabled: aws.Bool(true)}},
+			nil,
+		)
+		dm.On("DescribeTable", &dynamodb.DescribeTableInput{TableName: aws.String("foo-unprotected-table")}).Return(
+			&dynamodb.DescribeTableOutput{Table: &dynamodb.TableDescription{DeletionProtectionEnabled: aws.Bool(false)}},
+			nil,
+		)
+		dm.On("UpdateTable", &dynamodb.UpdateTableInput{TableName: aws.String("foo-table"), DeletionProtectionEnabled: aws.Bool(false)}).Return(
+			&dynamodb.UpdateTableOutput{},
+			nil,
+		)
+		lm.On("DescribeLoadBalancerAttributes", &elbv2.DescribeLoadBalancerAttributesInput{LoadBalancerArn: aws.String(lbArn)}).Return(
+			&elbv2.DescribeLoadBalancerAttributesOutput{Attributes: []*elbv2.LoadBalancerAttribute{
+				{Key: aws.String("idle_timeout.timeout_seconds"), Value: aws.String("60")},
+				{Key: aws.String("deletion_protection.enabled"), Value: aws.String("true")},
+			}},
+			nil,
+		)
+		lm.On("ModifyLoadBalancerAttributes", &elbv2.ModifyLoadBalancerAttributesInput{
+			LoadBalancerArn: aws.String(lbArn),
+			Attributes:      []*elbv2.LoadBalancerAttribute{{Key: aws.String("deletion_protection.enabled"), Value: aws.String("false")}},
+		}).Return(
+			&elbv2.ModifyLoadBalancerAttributesOutput{},
+			nil,
+		)
+		purge_stack.Register(&purge_stack.UnprotectHandler{RdsClient: rm, DynamoDBClient: dm, Elbv2Client: lm})
+	}
+
+	t.Run("disable protections", func(t *testing.T) {
+		cm := &purge_stack.MockCfnClient{}
+		rm := &purge_stack.MockRdsClient{}
+		dm := &purge_stack.MockDynamoDBClient{}
+		lm := &purge_stack.MockElbv2Client{}
+		initUnprotectMocks(cm, rm, dm, lm)
+		initMockClient(cm, &purge_stack.MockEcrClient{})
+
+		out, err := execute("--stack-name", stackName, "--force-unprotect")
+
+		expected := "foo\n"
+		expected += "Plan:\n"
+		expected += "- [unprotect] foo foo-db (AWS::RDS::DBInstance): disable deletion protection\n"
+		expected += "- [unprotect] foo foo-cluster (AWS::RDS::DBCluster): disable deletion protection\n"
+		expected += "- [unprotect] foo foo-table (AWS::DynamoDB::Table): disable deletion protection\n"
+		expected += "- [unprotect] foo " + lbArn + " (AWS::ElasticLoadBalancingV2::LoadBalancer): disable deletion protection\n"
+		expected += "Termination protection of foo will be disabled.\n"
+		expected += "Deletion protection of foo-db (AWS::RDS::DBInstance) disabled.\n"
+		expected += "Deletion protection of foo-cluster (AWS::RDS::DBCluster) disabled.\n"
+		expected += "Deletion protection of foo-table (AWS::DynamoDB::Table) disabled.\n"
+		expected += "Deletion protection of " + lbArn + " (AWS::ElasticLoadBalancingV2::LoadBalancer) disabled.\n"
+		expected += "Termination protection of foo disabled.\n"
+		assert.Nil(t, err)
+		assert.Equal(t, expected, out)
+		rm.AssertNumberOfCalls(t, "ModifyDBInstance", 1)
+		rm.AssertNumberOfCalls(t, "ModifyDBCluster", 1)
+		dm.AssertNumberOfCalls(t, "UpdateTable", 1)
+		lm.AssertNumberOfCalls(t, "ModifyLoadBalancerAttributes", 1)
+		cm.AssertNumberOfCalls(t, "UpdateTerminationProtection", 1)
+		cm.AssertNumberOfCalls(t, "DeleteStack", 1)
+	})
+
+	t.Run("without force-unprotect", func(t *testing.T) {
+		cm := &purge_stack.MockCfnClient{}
+		rm := &purge_stack.MockRdsClient{}
+		dm := &purge_stack.MockDynamoDBClient{}
+		lm := &purge_stack.MockElbv2Client{}
+		initUnprotectMocks(cm, rm, dm, lm)
+		initMockClient(cm, &purge_stack.MockEcrClient{})
+
+		_, err := execute("--stack-name", stackName)
+
+		assert.Nil(t, err)
+		rm.AssertNumberOfCalls(t, "DescribeDBInstances", 0)
+		dm.AssertNumberOfCalls(t, "DescribeTable", 0)
+		lm.AssertNumberOfCalls(t, "DescribeLoadBalancerAttributes", 0)
+		cm.AssertNumberOfCalls(t, "DescribeStacks", 0)
+		cm.AssertNumberOfCalls(t, "UpdateTerminationProtection", 0)
+	})
+}
+
+func TestSecretAndBackupHandlers(t *testing.T) {
+	stackName := "foo"
+	secretArn := "arn:aws:secretsmanager:ap-northeast-1:123456789012:secret:foo-secret-AbCdEf"
+	goneArn := "arn:aws:secretsmanager:ap-northeast-1:123456789012:secret:foo-gone-AbCdEf"
+	pointArn := func(id string) string {
+		return "arn:aws:backup:ap-northeast-1:123456789012:recovery-point:" + id
+	}
+	initMocks := func(cm *purge_stack.MockCfnClient, sm *purge_stack.MockSecretsManagerClient, bm *purge_stack.MockBackupClient) {
+		cm.On("ListStackResources", &cloudformation.ListStackResourcesInput{StackName: aws.String(stackName)}).Return(
+			&cloudformation.ListStackResourcesOutput{
+				StackResourceSummaries: []*cloudformation.StackResourceSummary{
+					{PhysicalResourceId: aws.String(secretArn), ResourceType: aws.String("AWS::SecretsManager::Secret")},
+					{PhysicalResourceId: aws.String(goneArn), ResourceType: aws.String("AWS::SecretsManager::Secret")},
+					{PhysicalResourceId: aws.String("foo-vault"), ResourceType: aws.String("AWS::Backup::BackupVault")},
+					{PhysicalResourceId: aws.String("foo-empty-vault"), ResourceType: aws.String("AWS::Backup::BackupVault")},
+				},
+			},
+			nil,
+		)
+		sm.On("DescribeSecret", &secretsmanager.DescribeSecretInput{SecretId: aws.String(secretArn)}).Return(
+			&secretsmanager.DescribeSecretOutput{ARN: aws.String(secretArn)},
+			nil,
+		)
+		sm.On("DescribeSecret", &secretsmanager.DescribeSecretInput{SecretId: aws.String(goneArn)}).Return(
+			nil,
+			awserr.New(secretsmanager.ErrCodeResourceNotFoundException, "Secrets Manager can't find the specified secret.", nil),
+		)
+		sm.On("DeleteSecret", &secretsmanager.DeleteSecretInput{SecretId: aws.String(secretArn), ForceDeleteWithoutRecovery: aws.Bool(true)}).Return(
+			&secretsmanager.DeleteSecretOutput{},
+			nil,
+		)
+		bm.On("ListRecoveryPointsByBackupVault", &backup.ListRecoveryPointsByBackupVaultInput{BackupVaultName: aws.String("foo-vault")}).Return(
+			&backup.ListRecoveryPointsByBackupVaultOutput{
+				NextToken: aws.String("next_token"),
+				RecoveryPoints: []*backup.RecoveryPointByBackupVault{
+					{RecoveryPointArn: aws.String(pointArn("parent")), IsParent: aws.Bool(true)},
+				},
+			},
+			nil,
+		)
+		bm.On("ListRecoveryPointsByBackupVault", &backup.ListRecoveryPointsByBackupVaultInput{BackupVaultName: aws.String("foo-vault"), NextToken: aws.String("next_token")}).Return(
+			&backup.ListRecoveryPointsByBackupVaultOutput{
+				RecoveryPoints: []*backup.RecoveryPointByBackupVault{
+					{RecoveryPointArn: aws.String(pointArn("child")), ParentRecoveryPointArn: aws.String(pointArn("parent"))},
+				},
+			},
+			nil,
+		)
+		bm.On("ListRecoveryPointsByBackupVault", &backup.ListRecoveryPointsByBackupVaultInput{BackupVaultName: aws.String("foo-empty-vault")}).Return(
+			&backup.ListRecoveryPointsByBackupVaultOutput{},
+			nil,
+		)
+		cm.On("DeleteStack", &cloudformation.DeleteStackInput{StackName: aws.String(stackName)}).Return(
+			&cloudformation.DeleteStackOutput{},
+			nil,
+		)
+		purge_stack.CfnClient = cm
+		purge_stack.Register(&purge_stack.SecretHandler{Client: sm})
+		purge_stack.Register(&purge_stack.BackupVaultHandler{Client: bm})
+	}
+
+	t.Run("force delete secrets and drain backup vaults", func(t *testing.T) {
+		cm := &purge_stack.MockCfnClient{}
+		sm := &purge_stack.MockSecretsManagerClient{}
+		bm := &purge_stack.MockBackupClient{}
+		initMocks(cm, sm, bm)
+		bm.On("DeleteRecoveryPoint", mock.Anything).Return(&backup.DeleteRecoveryPointOutput{}, nil)
+
+		out, err := execute("--stack-name", stackName, "--force-delete-secrets", "--drain-backup-vaults")
+
+		expected := "foo\n"
+		expected += "Plan:\n"
+		expected += "- [secret] foo " + secretArn + " (AWS::SecretsManager::Secret): force delete without recovery window\n"
+		expected += "- [backup] foo foo-vault (AWS::Backup::BackupVault): delete 2 recovery points\n"
+		expected += "Secret " + secretArn + " deleted without recovery window.\n"
+		expected += "foo-vault: 1/2 recovery points deleted.\n"
+		expected += "foo-vault: 2/2 recovery points deleted.\n"
+		expected += "All recovery points in foo-vault successfully deleted.\n"
+		assert.Nil(t, err)
+		assert.Equal(t, expected, out)
+		sm.AssertNumberOfCalls(t, "DeleteSecret", 1)
+		// child recovery point is deleted before its parent.
+		bm.AssertNumberOfCalls(t, "DeleteRecoveryPoint", 2)
+		assert.Equal(t, pointArn("child"), aws.StringValue(bm.Calls[3].Arguments.Get(0).(*backup.DeleteRecoveryPointInput).RecoveryPointArn))
+		assert.Equal(t, pointArn("parent"), aws.StringValue(bm.Calls[4].Arguments.Get(0).(*backup.DeleteRecoveryPointInput).RecoveryPointArn))
+		cm.AssertNumberOfCalls(t, "DeleteStack", 1)
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		cm := &purge_stack.MockCfnClient{}
+		sm := &purge_stack.MockSecretsManagerClient{}
+		bm := &purge_stack.MockBackupClient{}
+		initMocks(cm, sm, bm)
+
+		out, err := execute("--stack-name", stackName, "--handlers", "secret,backup", "--dry-run")
+
+		assert.Nil(t, err)
+		assert.Contains(t, out, "- [secret] foo "+secretArn)
+		assert.Contains(t, out, "- [backup] foo foo-vault")
+		sm.AssertNumberOfCalls(t, "DeleteSecret", 0)
+		bm.AssertNumberOfCalls(t, "DeleteRecoveryPoint", 0)
+		cm.AssertNumberOfCalls(t, "DeleteStack", 0)
+	})
+
+	t.Run("failed to delete recovery point", func(t *testing.T) {
+		cm := &purge_stack.MockCfnClient{}
+		sm := &purge_stack.MockSecretsManagerClient{}
+		bm := &purge_stack.MockBackupClient{}
+		initMocks(cm, sm, bm)
+		bm.On("DeleteRecoveryPoint", &backup.DeleteRecoveryPointInput{BackupVaultName: aws.String("foo-vault"), RecoveryPointArn: aws.String(pointArn("child"))}).Return(
+			nil,
+			awserr.New(backup.ErrCodeInvalidResourceStateException, "Recovery point is being created.", nil),
+		)
+		bm.On("DeleteRecoveryPoint", mock.Anything).Return(&backup.DeleteRecoveryPointOutput{}, nil)
+
+		_, err := execute("--stack-name", stackName, "--drain-backup-vaults")
+
+		assert.EqualError(t, err, "failed to delete recovery points of foo-vault")
+		sm.AssertNumberOfCalls(t, "DescribeSecret", 0)
+		cm.AssertNumberOfCalls(t, "DeleteStack", 0)
+	})
+
+	t.Run("not run by default", func(t *testing.T) {
+		cm := &purge_stack.MockCfnClient{}
+		sm := &purge_stack.MockSecretsManagerClient{}
+		bm := &purge_stack.MockBackupClient{}
+		initMocks(cm, sm, bm)
+
+		_, err := execute("--stack-name", stackName)
+
+		assert.Nil(t, err)
+		sm.AssertNumberOfCalls(t, "DescribeSecret", 0)
+		bm.AssertNumberOfCalls(t, "ListRecoveryPointsByBackupVault", 0)
+		cm.AssertNumberOfCalls(t, "DeleteStack", 1)
+	})
+}
+
+func TestRoute53Handler(t *testing.T) {
+	stackName := "foo"
+	zoneId := "Z0123456789ABC"
+	initMocks := func(cm *purge_stack.MockCfnClient, rm *purge_stack.MockRoute53Client) {
+		cm.On("ListStackResources", &cloudformation.ListStackResourcesInput{StackName: aws.String(stackName)}).Return(
+			&cloudformation.ListStackResourcesOutput{
+				StackResourceSummaries: []*cloudformation.StackResourceSummary{
+					{PhysicalResourceId: aws.String(zoneId), ResourceType: aws.String("AWS::Route53::HostedZone")},
+				},
+			},
+			nil,
+		)
+		cm.On("DeleteStack", &cloudformation.DeleteStackInput{StackName: aws.String(stackName)}).Return(
+			&cloudformation.DeleteStackOutput{},
+			nil,
+		)
+		rm.On("GetHostedZone", &route53.GetHostedZoneInput{Id: aws.String(zoneId)}).Return(
+			&route53.GetHostedZoneOutput{HostedZone: &route53.HostedZone{Id: aws.String("/hostedzone/" + zoneId), Name: aws.String("example.com.")}},
+			nil,
+		)
+		purge_stack.CfnClient = cm
+		purge_stack.Register(&purge_stack.Route53Handler{Client: rm})
+	}
+	record := func(name string, t string) *route53.ResourceRecordSet {
+		return &route53.ResourceRecordSet{
+			Name:            aws.String(name),
+			Type:            aws.String(t),
+			TTL:             aws.Int64(300),
+			ResourceRecords: []*route53.ResourceRecord{{Value: aws.String("value")}},
+		}
+	}
+
+	t.Run("delete record sets except apex SOA and NS", func(t *testing.T) {
+		cm := &purge_stack.MockCfnClient{}
+		rm := &purge_stack.MockRoute53Client{}
+		initMocks(cm, rm)
+		rm.On("ListResourceRecordSets", &route53.ListResourceRecordSetsInput{HostedZoneId: aws.String(zoneId)}).Return(
+			&route53.ListResourceRecordSetsOutput{
+				ResourceRecordSets: []*route53.ResourceRecordSet{
+					record("example.com.", "NS"),
+					record("example.com.", "SOA"),
+					record("_abc.example.com.", "CNAME"),
+				},
+				IsTruncated:    aws.Bool(true),
+				NextRecordName: aws.String("sub.example.com."),
+				NextRecordType: aws.String("NS"),
+			},
+			nil,
+		)
+		var records []*route53.ResourceRecordSet
+		records = append(records, record("sub.example.com.", "NS"))
+		for i := 0; i < 100; i++ {
+			records = append(records, record(fmt.Sprintf("www%d.example.com.", i), "A"))
+		}
+		rm.On("ListResourceRecordSets", &route53.ListResourceRecordSetsInput{
+			HostedZoneId:    aws.String(zoneId),
+			StartRecordName: aws.String("sub.example.com."),
+			StartRecordType: aws.String("NS"),
+		}).Return(
+			&route53.ListResourceRecordSetsOutput{ResourceRecordSets: records, IsTruncated: aws.Bool(false)},
+			nil,
+		)
+		rm.On("ChangeResourceRecordSets", mock.Anything).Return(&route53.ChangeResourceRecordSetsOutput{}, nil)
+
+		out, err := execute("--stack-name", stackName)
+
+		expected := "foo\n"
+		expected += "Plan:\n"
+		expected += "- [route53] foo " + zoneId + " (AWS::Route53::HostedZone): delete 102 record sets (_abc.example.com. CNAME, sub.example.com. NS, "
+		expected += "www0.example.com. A, www1.example.com. A, www2.example.com. A, www3.example.com. A, www4.example.com. A, "
+		expected += "www5.example.com. A, www6.example.com. A, www7.example.com. A, and 92 more)\n"
+		expected += zoneId + ": 100/102 record sets deleted.\n"
+		expected += zoneId + ": 102/102 record sets deleted.\n"
+		expected += "All record sets in " + zoneId + " successfully deleted.\n"
+		assert.Nil(t, err)
+		assert.Equal(t, expected, out)
+		rm.AssertNumberOfCalls(t, "ChangeResourceRecordSets", 2)
+		first := rm.Calls[3].Arguments.Get(0).(*route53.ChangeResourceRecordSetsInput)
+		assert.Equal(t, 100, len(first.ChangeBatch.Changes))
+		assert.Equal(t, "DELETE", aws.StringValue(first.ChangeBatch.Changes[0].Action))
+		assert.Equal(t, "_abc.example.com.", aws.StringValue(first.ChangeBatch.Changes[0].ResourceRecordSet.Name))
+		cm.AssertNumberOfCalls(t, "DeleteStack", 1)
+	})
+
+	t.Run("only default records", func(t *testing.T) {
+		cm := &purge_stack.MockCfnClient{}
+		rm := &purge_stack.MockRoute53Client{}
+		initMocks(cm, rm)
+		rm.On("ListResourceRecordSets", &route53.ListResourceRecordSetsInput{HostedZoneId: aws.String(zoneId)}).Return(
+			&route53.ListResourceRecordSetsOutput{
+				ResourceRecordSets: []*route53.ResourceRecordSet{
+					record("example.com.", "NS"),
+					record("example.com.", "SOA"),
+				},
+				IsTruncated: aws.Bool(false),
+			},
+			nil,
+		)
+
+		out, err := execute("--stack-name", stackName)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "foo\nNothing to clean up.\n", out)
+		rm.AssertNumberOfCalls(t, "ChangeResourceRecordSets", 0)
+		cm.AssertNumberOfCalls(t, "DeleteStack", 1)
+	})
+
+	t.Run("failed to change record sets", func(t *testing.T) {
+		cm := &purge_stack.MockCfnClient{}
+		rm := &purge_stack.MockRoute53Client{}
+		initMocks(cm, rm)
+		rm.On("ListResourceRecordSets", &route53.ListResourceRecordSetsInput{HostedZoneId: aws.String(zoneId)}).Return(
+			&route53.ListResourceRecordSetsOutput{
+				ResourceRecordSets: []*route53.ResourceRecordSet{record("www.example.com.", "A")},
+				IsTruncated:        aws.Bool(false),
+			},
+			nil,
+		)
+		rm.On("ChangeResourceRecordSets", mock.Anything).Return(
+			nil,
+			awserr.New(route53.ErrCodeInvalidChangeBatch, "Tried to delete resource record set but it was not found", nil),
+		)
+
+		_, err := execute("--stack-name", stackName)
+
+		assert.NotNil(t, err)
+		cm.AssertNumberOfCalls(t, "DeleteStack", 0)
+	})
+}
+
+func TestReportAndArchive(t *testing.T) {
+	stackName := "foo"
+	initMocks := func(cm *purge_stack.MockCfnClient, em *purge_stack.MockEcrClient, sm *purge_stack.MockS3Client, tm *purge_stack.MockStsClient) {
+		cm.On("ListStackResources", &cloudformation.ListStackResourcesInput{StackName: aws.String(stackName)}).Return(
+			&cloudformation.ListStackResourcesOutput{
+				StackResourceSummaries: []*cloudformation.StackResourceSummary{
+					{PhysicalResourceId: aws.String("ecr1"), ResourceType: aws.String("AWS::ECR::Repository")},
+					{PhysicalResourceId: aws.String("foo-bucket"), ResourceType: aws.String("AWS::S3::Bucket")},
+				},
+			},
+			nil,
+		)
+		sm.On("ListObjectVersions", &s3.ListObjectVersionsInput{Bucket: aws.String("foo-bucket")}).Return(
+			&s3.ListObjectVersionsOutput{
+				Versions: []*s3.ObjectVersion{
+					{Key: aws.String("a.txt"), VersionId: aws.String("v2"), IsLatest: aws.Bool(true), Size: aws.Int64(1)},
+					{Key: aws.String("a.txt"), VersionId: aws.String("v1"), IsLatest: aws.Bool(false), Size: aws.Int64(3)},
+					{Key: aws.String("dir/b.txt"), VersionId: aws.String("null"), IsLatest: aws.Bool(true), Size: aws.Int64(2)},
+				},
+				DeleteMarkers: []*s3.DeleteMarkerEntry{
+					{Key: aws.String("c.txt"), VersionId: aws.String("v3"), IsLatest: aws.Bool(true)},
+				},
+				IsTruncated: aws.Bool(false),
+			},
+			nil,
+		)
+		sm.On("GetObject", &s3.GetObjectInput{Bucket: aws.String("foo-bucket"), Key: aws.String("a.txt"), VersionId: aws.String("v2")}).Return(
+			&s3.GetObjectOutput{Body: ioutil.NopCloser(strings.NewReader("a")), ContentLength: aws.Int64(1)},
+			nil,
+		)
+		sm.On("GetObject", &s3.GetObjectInput{Bucket: aws.String("foo-bucket"), Key: aws.String("dir/b.txt")}).Return(
+			&s3.GetObjectOutput{Body: ioutil.NopCloser(strings.NewReader("bb")), ContentLength: aws.Int64(2)},
+			nil,
+		)
+		sm.On("DeleteObjects", mock.Anything).Return(
+			&s3.DeleteObjectsOutput{
+				Deleted: []*s3.DeletedObject{
+					{Key: aws.String("a.txt"), VersionId: aws.String("v2")},
+					{Key: aws.String("a.txt"), VersionId: aws.String("v1")},
+					{Key: aws.String("dir/b.txt"), VersionId: aws.String("null")},
+					{Key: aws.String("c.txt"), VersionId: aws.String("v3"), DeleteMarker: aws.Bool(true)},
+				},
+			},
+			nil,
+		)
+		tm.On("GetCallerIdentity", &sts.GetCallerIdentityInput{}).Return(
+			&sts.GetCallerIdentityOutput{
+				Account: aws.String("123456789012"),
+				Arn:     aws.String("arn:aws:iam::123456789012:user/alice"),
+				UserId:  aws.String("AIDAXXXXXXXXXXXXXXXXX"),
+			},
+			nil,
+		)
+		purge_stack.StsClient = tm
+		purge_stack.Register(&purge_stack.S3Handler{Client: sm})
+		initMockClient(cm, em)
+	}
+	type item struct {
+		Stack     string   `json:"stack"`
+		Handler   string   `json:"handler"`
+		Resource  string   `json:"resource"`
+		Kind      string   `json:"kind"`
+		Id        string   `json:"id"`
+		Tags      []string `json:"tags"`
+		VersionId string   `json:"version_id"`
+		Time      string   `json:"time"`
+	}
+	type report struct {
+		Caller struct {
+			Account string `json:"account"`
+			Arn     string `json:"arn"`
+		} `json:"caller"`
+		StartedAt  string  `json:"started_at"`
+		FinishedAt string  `json:"finished_at"`
+		DryRun     bool    `json:"dry_run"`
+		Error      string  `json:"error"`
+		Deleted    []*item `json:"deleted"`
+	}
+	readReport := func(t *testing.T, file string) *report {
+		b, err := ioutil.ReadFile(file)
+		assert.Nil(t, err)
+		r := &report{}
+		assert.Nil(t, json.Unmarshal(b, r))
+		return r
+	}
+
+	t.Run("report and archive into directory", func(t *testing.T) {
+		cm := &purge_stack.MockCfnClient{}
+		em := &purge_stack.MockEcrClient{}
+		sm := &purge_stack.MockS3Client{}
+		tm := &purge_stack.MockStsClient{}
+		initMocks(cm, em, sm, tm)
+		dir, err := ioutil.TempDir("", "purge-stack")
+		assert.Nil(t, err)
+		defer os.RemoveAll(dir)
+		reportFile := filepath.Join(dir, "report.json")
+		archiveDir := filepath.Join(dir, "archive")
+
+		out, err := execute("--stack-name", stackName, "--empty-buckets", "--report", reportFile, "--archive-dir", archiveDir)
+
+		expected := "foo\n"
+		expected += "Plan:\n"
+		expected += "- [ecr] foo ecr1 (AWS::ECR::Repository): delete 4 images\n"
+		expected += "- [s3] foo foo-bucket (AWS::S3::Bucket): delete 4 objects (including 1 noncurrent versions and 1 delete markers), after archiving current objects\n"
+		expected += "ecr1: 4/4 images deleted.\n"
+		expected += "All images in ecr1 successfully deleted.\n"
+		expected += "Archived 2 objects of foo-bucket to " + filepath.Join(archiveDir, "foo-bucket") + ".\n"
+		expected += "foo-bucket: 4/4 objects deleted.\n"
+		expected += "All objects in foo-bucket successfully deleted.\n"
+		assert.Nil(t, err)
+		assert.Equal(t, expected, out)
+
+		a, err := ioutil.ReadFile(filepath.Join(archiveDir, "foo-bucket", "a.txt"))
+		assert.Nil(t, err)
+		assert.Equal(t, "a", string(a))
+		bb, err := ioutil.ReadFile(filepath.Join(archiveDir, "foo-bucket", "dir", "b.txt"))
+		assert.Nil(t, err)
+		assert.Equal(t, "bb", string(bb))
+
+		r := readReport(t, reportFile)
+		assert.Equal(t, "123456789012", r.Caller.Account)
+		assert.Equal(t, "arn:aws:iam::123456789012:user/alice", r.Caller.Arn)
+		assert.NotEmpty(t, r.StartedAt)
+		assert.NotEmpty(t, r.FinishedAt)
+		assert.False(t, r.DryRun)
+		assert.Equal(t, 9, len(r.Deleted))
+		assert.Equal(t, &item{Stack: "foo", Handler: "ecr", Resource: "ecr1", Kind: "ecr-image", Id: "foofoofoo", Tags: []string{"foo"}, Time: r.Deleted[0].Time}, r.Deleted[0])
+		assert.NotEmpty(t, r.Deleted[0].Time)
+		assert.Equal(t, &item{Stack: "foo", Handler: "s3", Resource: "foo-bucket", Kind: "s3-object", Id: "a.txt", VersionId: "v1", Time: r.Deleted[5].Time}, r.Deleted[5])
+		assert.Equal(t, &item{Stack: "foo", Kind: "stack", Id: "foo", Time: r.Deleted[8].Time}, r.Deleted[8])
+	})
+
+	t.Run("archive into tarball", func(t *testing.T) {
+		cm := &purge_stack.MockCfnClient{}
+		em := &purge_stack.MockEcrClient{}
+		sm := &purge_stack.MockS3Client{}
+		tm := &purge_stack.MockStsClient{}
+		initMocks(cm, em, sm, tm)
+		dir, err := ioutil.TempDir("", "purge-stack")
+		assert.Nil(t, err)
+		defer os.RemoveAll(dir)
+
+		_, err = execute("--stack-name", stackName, "--empty-buckets", "--archive-dir", dir, "--archive-tar")
+		assert.Nil(t, err)
+
+		f, err := os.Open(filepath.Join(dir, "foo-bucket.tar.gz"))
+		assert.Nil(t, err)
+		defer f.Close()
+		gr, err := gzip.NewReader(f)
+		assert.Nil(t, err)
+		tr := tar.NewReader(gr)
+		files := make(map[string]string)
+		for {
+			h, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			assert.Nil(t, err)
+			b, _ := ioutil.ReadAll(tr)
+			files[h.Name] = string(b)
+		}
+		assert.Equal(t, map[string]string{"a.txt": "a", "dir/b.txt": "bb"}, files)
+		tm.AssertNumberOfCalls(t, "GetCallerIdentity", 0)
+	})
+
+	t.Run("report on failure", func(t *testing.T) {
+		cm := &purge_stack.MockCfnClient{}
+		cm.On("DeleteStack", &cloudformation.DeleteStackInput{StackName: aws.String(stackName)}).Return(
+			nil,
+			awserr.New("ValidationError", "Stack [foo] cannot be deleted while TerminationProtection is enabled", nil),
+		)
+		em := &purge_stack.MockEcrClient{}
+		sm := &purge_stack.MockS3Client{}
+		tm := &purge_stack.MockStsClient{}
+		initMocks(cm, em, sm, tm)
+		dir, err := ioutil.TempDir("", "purge-stack")
+		assert.Nil(t, err)
+		defer os.RemoveAll(dir)
+		reportFile := filepath.Join(dir, "report.json")
+
+		_, err = execute("--stack-name", stackName, "--report", reportFile)
+
+		assert.NotNil(t, err)
+		r := readReport(t, reportFile)
+		assert.Equal(t, err.Error(), r.Error)
+		assert.Equal(t, 4, len(r.Deleted))
+		sm.AssertNumberOfCalls(t, "ListObjectVersions", 0)
+	})
+
+	t.Run("archive-dir without empty-buckets", func(t *testing.T) {
+		cm := &purge_stack.MockCfnClient{}
+		em := &purge_stack.MockEcrClient{}
+		sm := &purge_stack.MockS3Client{}
+		tm := &purge_stack.MockStsClient{}
+		initMocks(cm, em, sm, tm)
+
+		_, err := execute("--stack-name", stackName, "--archive-dir", "archive")
+
+		assert.EqualError(t, err, "--archive-dir requires --empty-buckets")
+		cm.AssertNumberOfCalls(t, "ListStackResources", 0)
+	})
+}
@@ -0,0 +1,114 @@
+package purge_stack
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/aws/aws-sdk-go/service/sts/stsiface"
+)
+
+var StsClient stsiface.STSAPI
+
+// kinds of deleted items in report.
+const (
+	deletedStack            = "stack"
+	deletedEcrImage         = "ecr-image"
+	deletedS3Object         = "s3-object"
+	deletedSecret           = "secret"
+	deletedRecoveryPoint    = "recovery-point"
+	deletedRecordSet        = "record-set"
+	deletedNetworkInterface = "network-interface"
+	deletedLogGroup         = "log-group"
+	deletedRetainedResource = "retained-resource"
+)
+
+// auditReport is what purge-stack destroyed, written to the file of --report.
+type auditReport struct {
+	Caller     *auditCaller   `json:"caller"`
+	StartedAt  time.Time      `json:"started_at"`
+	FinishedAt time.Time      `json:"finished_at"`
+	DryRun     bool           `json:"dry_run"`
+	Error      string         `json:"error,omitempty"`
+	Deleted    []*deletedItem `json:"deleted"`
+	mu         sync.Mutex
+}
+
+type auditCaller struct {
+	Account string `json:"account"`
+	Arn     string `json:"arn"`
+	UserId  string `json:"user_id"`
+}
+
+type deletedItem struct {
+	Time         time.Time `json:"time"`
+	Stack        string    `json:"stack,omitempty"`
+	Handler      string    `json:"handler,omitempty"`
+	ResourceType string    `json:"resource_type,omitempty"`
+	Resource     string    `json:"resource,omitempty"`
+	Kind         string    `json:"kind"`
+	Id           string    `json:"id"`
+	// image tags of ECR image.
+	Tags []string `json:"tags,omitempty"`
+	// version id of S3 object.
+	VersionId string `json:"version_id,omitempty"`
+}
+
+// audit is nil unless --report is specified.
+var audit *auditReport
+
+// newAuditReport starts report with caller identity.
+func newAuditReport() (*auditReport, error) {
+	resp, err := StsClient.GetCallerIdentity(&sts.GetCallerIdentityInput{})
+	if err != nil {
+		return nil, err
+	}
+	return &auditReport{
+		Caller: &auditCaller{
+			Account: aws.StringValue(resp.Account),
+			Arn:     aws.StringValue(resp.Arn),
+			UserId:  aws.StringValue(resp.UserId),
+		},
+		StartedAt: time.Now(),
+		DryRun:    dryRun,
+		Deleted:   []*deletedItem{},
+	}, nil
+}
+
+// record adds the item to report. It is safe to call concurrently.
+func record(item *deletedItem) {
+	if audit == nil {
+		return
+	}
+	item.Time = time.Now()
+	audit.mu.Lock()
+	defer audit.mu.Unlock()
+	audit.Deleted = append(audit.Deleted, item)
+}
+
+// recordTask adds the item deleted by the task to report.
+func recordTask(task *Task, item *deletedItem) {
+	item.Stack = task.Stack
+	item.Handler = task.Handler.Name()
+	item.ResourceType = aws.StringValue(task.Resource.ResourceType)
+	item.Resource = aws.StringValue(task.Resource.PhysicalResourceId)
+	record(item)
+}
+
+// write saves report as JSON. err is the result of purge-stack, if failed.
+func (r *auditReport) write(file string, err error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.FinishedAt = time.Now()
+	if err != nil {
+		r.Error = err.Error()
+	}
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, append(b, '\n'), 0644)
+}
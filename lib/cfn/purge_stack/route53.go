@@ -0,0 +1,149 @@
+package purge_stack
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/aws/aws-sdk-go/service/route53/route53iface"
+)
+
+const (
+	// ChangeResourceRecordSets accepts up to 1000 changes, but also limits total characters of values.
+	route53BatchSize = 100
+	// max number of record sets shown in plan.
+	route53PlanRecords = 10
+)
+
+// Route53Handler deletes record sets other than SOA and NS of the zone apex,
+// which are often created outside CloudFormation (e.g. certificate validation, external-dns)
+// and prevent the hosted zone from being deleted.
+type Route53Handler struct {
+	Client route53iface.Route53API
+}
+
+func init() {
+	Register(&Route53Handler{})
+}
+
+func (h *Route53Handler) Name() string {
+	return "route53"
+}
+
+func (h *Route53Handler) ResourceTypes() []string {
+	return []string{"AWS::Route53::HostedZone"}
+}
+
+func (h *Route53Handler) InitClient(sess *session.Session) {
+	if h.Client == nil {
+		h.Client = route53.New(sess)
+	}
+}
+
+func (h *Route53Handler) Plan(stack string, resource *cloudformation.StackResourceSummary) (*Task, error) {
+	records, err := h.listExtraRecordSets(aws.StringValue(resource.PhysicalResourceId))
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	var names []string
+	for i, r := range records {
+		if i == route53PlanRecords {
+			names = append(names, fmt.Sprintf("and %d more", len(records)-i))
+			break
+		}
+		names = append(names, recordSetString(r))
+	}
+	return &Task{
+		Handler:  h,
+		Stack:    stack,
+		Resource: resource,
+		Summary:  fmt.Sprintf("delete %d record sets (%s)", len(records), strings.Join(names, ", ")),
+		Data:     records,
+	}, nil
+}
+
+// Clean deletes record sets planned, in batches.
+func (h *Route53Handler) Clean(task *Task, out io.Writer) error {
+	zoneId := aws.StringValue(task.Resource.PhysicalResourceId)
+	records := task.Data.([]*route53.ResourceRecordSet)
+	for i := 0; i < len(records); i += route53BatchSize {
+		end := i + route53BatchSize
+		if end > len(records) {
+			end = len(records)
+		}
+		var changes []*route53.Change
+		for _, r := range records[i:end] {
+			changes = append(changes, &route53.Change{
+				Action:            aws.String(route53.ChangeActionDelete),
+				ResourceRecordSet: r,
+			})
+		}
+		_, err := h.Client.ChangeResourceRecordSets(&route53.ChangeResourceRecordSetsInput{
+			HostedZoneId: aws.String(zoneId),
+			ChangeBatch:  &route53.ChangeBatch{Changes: changes},
+		})
+		if err != nil {
+			return err
+		}
+		for _, r := range records[i:end] {
+			recordTask(task, &deletedItem{Kind: deletedRecordSet, Id: recordSetString(r)})
+		}
+		fmt.Fprintf(out, "%s: %d/%d record sets deleted.\n", zoneId, end, len(records))
+	}
+	return nil
+}
+
+func (h *Route53Handler) Report(task *Task) string {
+	return fmt.Sprintf("All record sets in %s successfully deleted.", aws.StringValue(task.Resource.PhysicalResourceId))
+}
+
+// listExtraRecordSets returns record sets except SOA and NS of the zone apex,
+// which are managed by Route 53 and deleted with the hosted zone.
+func (h *Route53Handler) listExtraRecordSets(zoneId string) ([]*route53.ResourceRecordSet, error) {
+	zone, err := h.Client.GetHostedZone(&route53.GetHostedZoneInput{Id: aws.String(zoneId)})
+	if err != nil {
+		return nil, err
+	}
+	apex := aws.StringValue(zone.HostedZone.Name)
+	var records []*route53.ResourceRecordSet
+	params := &route53.ListResourceRecordSetsInput{HostedZoneId: aws.String(zoneId)}
+	for {
+		resp, err := h.Client.ListResourceRecordSets(params)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range resp.ResourceRecordSets {
+			t := aws.StringValue(r.Type)
+			if aws.StringValue(r.Name) == apex && (t == route53.RRTypeSoa || t == route53.RRTypeNs) {
+				continue
+			}
+			records = append(records, r)
+		}
+		if !aws.BoolValue(resp.IsTruncated) {
+			break
+		}
+		params = &route53.ListResourceRecordSetsInput{
+			HostedZoneId:          aws.String(zoneId),
+			StartRecordName:       resp.NextRecordName,
+			StartRecordType:       resp.NextRecordType,
+			StartRecordIdentifier: resp.NextRecordIdentifier,
+		}
+	}
+	return records, nil
+}
+
+// recordSetString describes the record set. e.g. www.example.com. A
+func recordSetString(r *route53.ResourceRecordSet) string {
+	s := fmt.Sprintf("%s %s", aws.StringValue(r.Name), aws.StringValue(r.Type))
+	if r.SetIdentifier != nil {
+		s += fmt.Sprintf(" [%s]", aws.StringValue(r.SetIdentifier))
+	}
+	return s
+}
@@ -0,0 +1,179 @@
+package purge_stack
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+)
+
+// DeleteObjects accepts at most 1000 keys at once.
+const s3DeleteLimit = 1000
+
+// S3Handler deletes all objects in S3 bucket, including noncurrent versions and delete markers.
+// With --archive-dir, current objects are downloaded before deletion.
+// It is opt-in, enabled by --empty-buckets.
+type S3Handler struct {
+	Client s3iface.S3API
+}
+
+// s3Object is an object version or a delete marker.
+type s3Object struct {
+	key          string
+	versionId    string
+	latest       bool
+	deleteMarker bool
+	size         int64
+}
+
+func init() {
+	Register(&S3Handler{})
+}
+
+func (h *S3Handler) Name() string {
+	return "s3"
+}
+
+func (h *S3Handler) ResourceTypes() []string {
+	return []string{"AWS::S3::Bucket"}
+}
+
+func (h *S3Handler) OptIn() bool {
+	return true
+}
+
+func (h *S3Handler) InitClient(sess *session.Session) {
+	if h.Client == nil {
+		h.Client = s3.New(sess)
+	}
+}
+
+func (h *S3Handler) Plan(stack string, resource *cloudformation.StackResourceSummary) (*Task, error) {
+	objects, err := h.listObjectVersions(aws.StringValue(resource.PhysicalResourceId))
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == s3.ErrCodeNoSuchBucket {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if len(objects) == 0 {
+		return nil, nil
+	}
+	noncurrents, markers := 0, 0
+	for _, o := range objects {
+		if o.deleteMarker {
+			markers++
+		} else if !o.latest {
+			noncurrents++
+		}
+	}
+	summary := fmt.Sprintf("delete %d objects", len(objects))
+	var details []string
+	if noncurrents > 0 {
+		details = append(details, fmt.Sprintf("%d noncurrent versions", noncurrents))
+	}
+	if markers > 0 {
+		details = append(details, fmt.Sprintf("%d delete markers", markers))
+	}
+	if len(details) > 0 {
+		summary += fmt.Sprintf(" (including %s)", strings.Join(details, " and "))
+	}
+	if archiveDir != "" {
+		summary += ", after archiving current objects"
+	}
+	return &Task{
+		Handler:  h,
+		Stack:    stack,
+		Resource: resource,
+		Summary:  summary,
+		Data:     objects,
+	}, nil
+}
+
+func (h *S3Handler) Clean(task *Task, out io.Writer) error {
+	bucket := aws.StringValue(task.Resource.PhysicalResourceId)
+	objects := task.Data.([]*s3Object)
+	if archiveDir != "" {
+		if err := h.archive(bucket, objects, out); err != nil {
+			return err
+		}
+	}
+	deleted, failed := 0, 0
+	for i := 0; i < len(objects); i += s3DeleteLimit {
+		end := i + s3DeleteLimit
+		if end > len(objects) {
+			end = len(objects)
+		}
+		var ids []*s3.ObjectIdentifier
+		for _, o := range objects[i:end] {
+			ids = append(ids, &s3.ObjectIdentifier{Key: aws.String(o.key), VersionId: aws.String(o.versionId)})
+		}
+		resp, err := h.Client.DeleteObjects(&s3.DeleteObjectsInput{
+			Bucket: aws.String(bucket),
+			Delete: &s3.Delete{Objects: ids},
+		})
+		if err != nil {
+			return err
+		}
+		for _, d := range resp.Deleted {
+			recordTask(task, &deletedItem{Kind: deletedS3Object, Id: aws.StringValue(d.Key), VersionId: aws.StringValue(d.VersionId)})
+		}
+		for _, e := range resp.Errors {
+			fmt.Fprintf(out, "Failed to delete %s (%s): %s\n", aws.StringValue(e.Key), aws.StringValue(e.VersionId), aws.StringValue(e.Message))
+		}
+		deleted += len(resp.Deleted)
+		failed += len(resp.Errors)
+		fmt.Fprintf(out, "%s: %d/%d objects deleted.\n", bucket, deleted, len(objects))
+	}
+	if failed > 0 {
+		return errors.New(fmt.Sprintf("failed to delete objects of %s", bucket))
+	}
+	return nil
+}
+
+func (h *S3Handler) Report(task *Task) string {
+	return fmt.Sprintf("All objects in %s successfully deleted.", aws.StringValue(task.Resource.PhysicalResourceId))
+}
+
+func (h *S3Handler) listObjectVersions(bucket string) ([]*s3Object, error) {
+	var objects []*s3Object
+	params := &s3.ListObjectVersionsInput{Bucket: aws.String(bucket)}
+	for {
+		resp, err := h.Client.ListObjectVersions(params)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range resp.Versions {
+			objects = append(objects, &s3Object{
+				key:       aws.StringValue(v.Key),
+				versionId: aws.StringValue(v.VersionId),
+				latest:    aws.BoolValue(v.IsLatest),
+				size:      aws.Int64Value(v.Size),
+			})
+		}
+		for _, m := range resp.DeleteMarkers {
+			objects = append(objects, &s3Object{
+				key:          aws.StringValue(m.Key),
+				versionId:    aws.StringValue(m.VersionId),
+				latest:       aws.BoolValue(m.IsLatest),
+				deleteMarker: true,
+			})
+		}
+		if !aws.BoolValue(resp.IsTruncated) {
+			break
+		}
+		params = &s3.ListObjectVersionsInput{
+			Bucket:          aws.String(bucket),
+			KeyMarker:       resp.NextKeyMarker,
+			VersionIdMarker: resp.NextVersionIdMarker,
+		}
+	}
+	return objects, nil
+}
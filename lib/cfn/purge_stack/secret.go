@@ -0,0 +1,75 @@
+package purge_stack
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
+)
+
+// SecretHandler deletes secrets without recovery window.
+// Secrets deleted by CloudFormation remain scheduled for deletion for 7 to 30 days,
+// and a secret with the same name cannot be created in the meantime.
+// It is opt-in, enabled by --force-delete-secrets.
+type SecretHandler struct {
+	Client secretsmanageriface.SecretsManagerAPI
+}
+
+func init() {
+	Register(&SecretHandler{})
+}
+
+func (h *SecretHandler) Name() string {
+	return "secret"
+}
+
+func (h *SecretHandler) ResourceTypes() []string {
+	return []string{"AWS::SecretsManager::Secret"}
+}
+
+func (h *SecretHandler) OptIn() bool {
+	return true
+}
+
+func (h *SecretHandler) InitClient(sess *session.Session) {
+	if h.Client == nil {
+		h.Client = secretsmanager.New(sess)
+	}
+}
+
+func (h *SecretHandler) Plan(stack string, resource *cloudformation.StackResourceSummary) (*Task, error) {
+	_, err := h.Client.DescribeSecret(&secretsmanager.DescribeSecretInput{SecretId: resource.PhysicalResourceId})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == secretsmanager.ErrCodeResourceNotFoundException {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &Task{
+		Handler:  h,
+		Stack:    stack,
+		Resource: resource,
+		Summary:  "force delete without recovery window",
+	}, nil
+}
+
+func (h *SecretHandler) Clean(task *Task, out io.Writer) error {
+	_, err := h.Client.DeleteSecret(&secretsmanager.DeleteSecretInput{
+		SecretId:                   task.Resource.PhysicalResourceId,
+		ForceDeleteWithoutRecovery: aws.Bool(true),
+	})
+	if err != nil {
+		return err
+	}
+	recordTask(task, &deletedItem{Kind: deletedSecret, Id: aws.StringValue(task.Resource.PhysicalResourceId)})
+	return nil
+}
+
+func (h *SecretHandler) Report(task *Task) string {
+	return fmt.Sprintf("Secret %s deleted without recovery window.", aws.StringValue(task.Resource.PhysicalResourceId))
+}
@@ -0,0 +1,374 @@
+package purge_stack
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/olekukonko/tablewriter"
+)
+
+// max number of stacks purged at the same time.
+const maxConcurrentStacks = 4
+
+// stackSelector selects stacks by --stack-name-pattern and --tag.
+type stackSelector struct {
+	glob  string
+	regex *regexp.Regexp
+	tags  map[string]string
+}
+
+// newStackSelector parses pattern and tags.
+// Pattern surrounded by slash (e.g. /^dev-.*$/) is treated as regular expression, otherwise glob.
+// Each tag is in key=value format.
+func newStackSelector(pattern string, tags []string) (*stackSelector, error) {
+	s := &stackSelector{tags: make(map[string]string)}
+	if len(pattern) > 1 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		r, err := regexp.Compile(pattern[1 : len(pattern)-1])
+		if err != nil {
+			return nil, err
+		}
+		s.regex = r
+	} else {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid stack name pattern: %s", pattern)
+		}
+		s.glob = pattern
+	}
+	for _, tag := range tags {
+		kv := strings.SplitN(tag, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("invalid tag: %s (expected key=value)", tag)
+		}
+		s.tags[kv[0]] = kv[1]
+	}
+	return s, nil
+}
+
+func (s *stackSelector) match(stack *cloudformation.Stack) bool {
+	name := aws.StringValue(stack.StackName)
+	if s.regex != nil && !s.regex.MatchString(name) {
+		return false
+	}
+	if s.glob != "" {
+		if ok, _ := path.Match(s.glob, name); !ok {
+			return false
+		}
+	}
+	for k, v := range s.tags {
+		found := false
+		for _, tag := range stack.Tags {
+			if aws.StringValue(tag.Key) == k && aws.StringValue(tag.Value) == v {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// selectStacks returns root stacks matched with selector.
+// Nested stacks are excluded, as they are purged with their root stack.
+func selectStacks(selector *stackSelector) ([]*cloudformation.Stack, error) {
+	var stacks []*cloudformation.Stack
+	var token *string
+	for {
+		resp, err := CfnClient.DescribeStacks(&cloudformation.DescribeStacksInput{NextToken: token})
+		if err != nil {
+			return nil, err
+		}
+		for _, stack := range resp.Stacks {
+			if stack.ParentId != nil {
+				continue
+			}
+			if aws.StringValue(stack.StackStatus) == cloudformation.StackStatusDeleteComplete {
+				continue
+			}
+			if selector.match(stack) {
+				stacks = append(stacks, stack)
+			}
+		}
+		if resp.NextToken == nil {
+			break
+		}
+		token = resp.NextToken
+	}
+	sort.Slice(stacks, func(i, j int) bool {
+		return aws.StringValue(stacks[i].StackName) < aws.StringValue(stacks[j].StackName)
+	})
+	return stacks, nil
+}
+
+func listImports(exportName string, token *string, result []string) ([]string, error) {
+	params := &cloudformation.ListImportsInput{
+		NextToken:  token,
+		ExportName: aws.String(exportName),
+	}
+	resp, err := CfnClient.ListImports(params)
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok {
+			if aerr.Code() == "ValidationError" && strings.Contains(aerr.Message(), "is not imported by any stack") {
+				return result, nil
+			}
+		}
+		return nil, err
+	}
+	for _, _import := range resp.Imports {
+		result = append(result, aws.StringValue(_import))
+	}
+	if resp.NextToken != nil {
+		return listImports(exportName, resp.NextToken, result)
+	}
+	return result, nil
+}
+
+// stackDependency holds which stacks import exports of the stack.
+type stackDependency struct {
+	name      string
+	importers []string
+	// importers which are not selected. The stack cannot be deleted while they exist.
+	externals []string
+}
+
+// resolveDependencies looks up importing stacks of each stack's exports.
+func resolveDependencies(stacks []*cloudformation.Stack) (map[string]*stackDependency, error) {
+	selected := make(map[string]bool)
+	for _, stack := range stacks {
+		selected[aws.StringValue(stack.StackName)] = true
+	}
+	deps := make(map[string]*stackDependency)
+	for _, stack := range stacks {
+		name := aws.StringValue(stack.StackName)
+		dep := &stackDependency{name: name}
+		for _, output := range stack.Outputs {
+			if output.ExportName == nil {
+				continue
+			}
+			importers, err := listImports(aws.StringValue(output.ExportName), nil, []string{})
+			if err != nil {
+				return nil, err
+			}
+			for _, importer := range importers {
+				if importer == name {
+					continue
+				}
+				if selected[importer] {
+					dep.importers = appendUnique(dep.importers, importer)
+				} else {
+					dep.externals = appendUnique(dep.externals, importer)
+				}
+			}
+		}
+		deps[name] = dep
+	}
+	return deps, nil
+}
+
+func appendUnique(list []string, s string) []string {
+	for _, l := range list {
+		if l == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
+
+// deletionLayers orders stacks so that importing stacks are deleted before exporting stacks.
+// Stacks in the same layer do not depend on each other.
+func deletionLayers(deps map[string]*stackDependency) ([][]string, error) {
+	remaining := make(map[string]int)
+	for name, dep := range deps {
+		remaining[name] = len(dep.importers)
+	}
+	var layers [][]string
+	for len(remaining) > 0 {
+		var layer []string
+		for name, n := range remaining {
+			if n == 0 {
+				layer = append(layer, name)
+			}
+		}
+		if len(layer) == 0 {
+			var names []string
+			for name := range remaining {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return nil, fmt.Errorf("circular imports among stacks: %s", strings.Join(names, ", "))
+		}
+		sort.Strings(layer)
+		for _, name := range layer {
+			delete(remaining, name)
+		}
+		for name := range remaining {
+			for _, importer := range deps[name].importers {
+				for _, deleted := range layer {
+					if importer == deleted {
+						remaining[name]--
+					}
+				}
+			}
+		}
+		layers = append(layers, layer)
+	}
+	return layers, nil
+}
+
+// stackResult is the outcome of purging a stack, shown in summary.
+type stackResult struct {
+	name   string
+	status string
+	detail string
+}
+
+const (
+	stackStatusDeleted = "deleted"
+	stackStatusPlanned = "planned"
+	stackStatusFailed  = "failed"
+	stackStatusSkipped = "skipped"
+)
+
+// purgeStacks purges selected stacks layer by layer.
+// Stacks in the same layer are purged concurrently,
+// and the next layer starts after deletion of the previous layer completes.
+func purgeStacks(stacks []*cloudformation.Stack, enabled map[string]Handler, out io.Writer) ([]*stackResult, error) {
+	deps, err := resolveDependencies(stacks)
+	if err != nil {
+		return nil, err
+	}
+	layers, err := deletionLayers(deps)
+	if err != nil {
+		return nil, err
+	}
+	var order []string
+	for _, layer := range layers {
+		order = append(order, strings.Join(layer, ", "))
+	}
+	fmt.Fprintf(out, "Deletion order:\n- %s\n", strings.Join(order, "\n- "))
+
+	results := make(map[string]*stackResult)
+	var mu sync.Mutex
+	for _, layer := range layers {
+		var wg sync.WaitGroup
+		sem := make(chan struct{}, maxConcurrentStacks)
+		for _, name := range layer {
+			mu.Lock()
+			reason := blockedReason(deps[name], results)
+			if reason != "" {
+				results[name] = &stackResult{name: name, status: stackStatusSkipped, detail: reason}
+			}
+			mu.Unlock()
+			if reason != "" {
+				continue
+			}
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(name string) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				result := &stackResult{name: name, status: stackStatusDeleted}
+				if dryRun {
+					result.status = stackStatusPlanned
+				}
+				if err := purgeStack(name, enabled, newPrefixWriter(out, name, &mu), !dryRun); err != nil {
+					result.status = stackStatusFailed
+					result.detail = err.Error()
+				}
+				mu.Lock()
+				results[name] = result
+				mu.Unlock()
+			}(name)
+		}
+		wg.Wait()
+	}
+
+	var list []*stackResult
+	for _, layer := range layers {
+		for _, name := range layer {
+			list = append(list, results[name])
+		}
+	}
+	return list, nil
+}
+
+// blockedReason returns why the stack cannot be deleted, or empty string.
+func blockedReason(dep *stackDependency, results map[string]*stackResult) string {
+	if len(dep.externals) > 0 {
+		return fmt.Sprintf("exports imported by unselected stacks: %s", strings.Join(dep.externals, ", "))
+	}
+	for _, importer := range dep.importers {
+		if r := results[importer]; r.status == stackStatusFailed || r.status == stackStatusSkipped {
+			return fmt.Sprintf("importing stack %s was not deleted", importer)
+		}
+	}
+	return ""
+}
+
+func summaryString(results []*stackResult) string {
+	tableString := &strings.Builder{}
+	table := tablewriter.NewWriter(tableString)
+	table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
+	table.SetCenterSeparator("|")
+	table.SetAutoWrapText(false)
+	table.SetHeader([]string{"Stack", "Result", "Detail"})
+	for _, r := range results {
+		table.Append([]string{r.name, r.status, r.detail})
+	}
+	table.Render()
+	return tableString.String()
+}
+
+func failedCount(results []*stackResult) int {
+	n := 0
+	for _, r := range results {
+		if r.status == stackStatusFailed || r.status == stackStatusSkipped {
+			n++
+		}
+	}
+	return n
+}
+
+func errFailedStacks(n int) error {
+	return errors.New(fmt.Sprintf("%d stacks were not purged", n))
+}
+
+// prefixWriter writes each line with stack name prefix.
+// Writers share the lock, so that lines from concurrent stacks are not mixed.
+type prefixWriter struct {
+	out    io.Writer
+	prefix string
+	mu     *sync.Mutex
+	buf    []byte
+}
+
+func newPrefixWriter(out io.Writer, stack string, mu *sync.Mutex) *prefixWriter {
+	return &prefixWriter{out: out, prefix: fmt.Sprintf("[%s] ", stack), mu: mu}
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf = append(w.buf, p...)
+	for {
+		i := strings.IndexByte(string(w.buf), '\n')
+		if i < 0 {
+			break
+		}
+		if _, err := fmt.Fprintf(w.out, "%s%s\n", w.prefix, w.buf[:i]); err != nil {
+			return 0, err
+		}
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
@@ -0,0 +1,179 @@
+package purge_stack
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/elbv2/elbv2iface"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
+)
+
+const (
+	rdsDBInstance        = "AWS::RDS::DBInstance"
+	rdsDBCluster         = "AWS::RDS::DBCluster"
+	dynamoDBTable        = "AWS::DynamoDB::Table"
+	elbv2LoadBalancer    = "AWS::ElasticLoadBalancingV2::LoadBalancer"
+	elbv2DeletionProtKey = "deletion_protection.enabled"
+)
+
+// UnprotectHandler disables deletion protection of
+// RDS instances and clusters, DynamoDB tables and ELBv2 load balancers.
+// It is opt-in, enabled by --force-unprotect.
+type UnprotectHandler struct {
+	RdsClient      rdsiface.RDSAPI
+	DynamoDBClient dynamodbiface.DynamoDBAPI
+	Elbv2Client    elbv2iface.ELBV2API
+}
+
+func init() {
+	Register(&UnprotectHandler{})
+}
+
+func (h *UnprotectHandler) Name() string {
+	return "unprotect"
+}
+
+func (h *UnprotectHandler) ResourceTypes() []string {
+	return []string{rdsDBInstance, rdsDBCluster, dynamoDBTable, elbv2LoadBalancer}
+}
+
+func (h *UnprotectHandler) OptIn() bool {
+	return true
+}
+
+func (h *UnprotectHandler) InitClient(sess *session.Session) {
+	if h.RdsClient == nil {
+		h.RdsClient = rds.New(sess)
+	}
+	if h.DynamoDBClient == nil {
+		h.DynamoDBClient = dynamodb.New(sess)
+	}
+	if h.Elbv2Client == nil {
+		h.Elbv2Client = elbv2.New(sess)
+	}
+}
+
+func (h *UnprotectHandler) Plan(stack string, resource *cloudformation.StackResourceSummary) (*Task, error) {
+	protected, err := h.isProtected(resource)
+	if err != nil {
+		return nil, err
+	}
+	if !protected {
+		return nil, nil
+	}
+	return &Task{
+		Handler:  h,
+		Stack:    stack,
+		Resource: resource,
+		Summary:  "disable deletion protection",
+	}, nil
+}
+
+func (h *UnprotectHandler) Clean(task *Task, out io.Writer) error {
+	id := task.Resource.PhysicalResourceId
+	var err error
+	switch aws.StringValue(task.Resource.ResourceType) {
+	case rdsDBInstance:
+		_, err = h.RdsClient.ModifyDBInstance(&rds.ModifyDBInstanceInput{
+			DBInstanceIdentifier: id,
+			DeletionProtection:   aws.Bool(false),
+			ApplyImmediately:     aws.Bool(true),
+		})
+	case rdsDBCluster:
+		_, err = h.RdsClient.ModifyDBCluster(&rds.ModifyDBClusterInput{
+			DBClusterIdentifier: id,
+			DeletionProtection:  aws.Bool(false),
+			ApplyImmediately:    aws.Bool(true),
+		})
+	case dynamoDBTable:
+		_, err = h.DynamoDBClient.UpdateTable(&dynamodb.UpdateTableInput{
+			TableName:                 id,
+			DeletionProtectionEnabled: aws.Bool(false),
+		})
+	case elbv2LoadBalancer:
+		_, err = h.Elbv2Client.ModifyLoadBalancerAttributes(&elbv2.ModifyLoadBalancerAttributesInput{
+			LoadBalancerArn: id,
+			Attributes: []*elbv2.LoadBalancerAttribute{
+				{Key: aws.String(elbv2DeletionProtKey), Value: aws.String("false")},
+			},
+		})
+	}
+	return err
+}
+
+func (h *UnprotectHandler) Report(task *Task) string {
+	return fmt.Sprintf("Deletion protection of %s (%s) disabled.", aws.StringValue(task.Resource.PhysicalResourceId), aws.StringValue(task.Resource.ResourceType))
+}
+
+func (h *UnprotectHandler) isProtected(resource *cloudformation.StackResourceSummary) (bool, error) {
+	id := resource.PhysicalResourceId
+	switch aws.StringValue(resource.ResourceType) {
+	case rdsDBInstance:
+		resp, err := h.RdsClient.DescribeDBInstances(&rds.DescribeDBInstancesInput{DBInstanceIdentifier: id})
+		if err != nil {
+			return false, err
+		}
+		for _, i := range resp.DBInstances {
+			if aws.BoolValue(i.DeletionProtection) {
+				return true, nil
+			}
+		}
+	case rdsDBCluster:
+		resp, err := h.RdsClient.DescribeDBClusters(&rds.DescribeDBClustersInput{DBClusterIdentifier: id})
+		if err != nil {
+			return false, err
+		}
+		for _, c := range resp.DBClusters {
+			if aws.BoolValue(c.DeletionProtection) {
+				return true, nil
+			}
+		}
+	case dynamoDBTable:
+		resp, err := h.DynamoDBClient.DescribeTable(&dynamodb.DescribeTableInput{TableName: id})
+		if err != nil {
+			return false, err
+		}
+		return aws.BoolValue(resp.Table.DeletionProtectionEnabled), nil
+	case elbv2LoadBalancer:
+		resp, err := h.Elbv2Client.DescribeLoadBalancerAttributes(&elbv2.DescribeLoadBalancerAttributesInput{LoadBalancerArn: id})
+		if err != nil {
+			return false, err
+		}
+		for _, a := range resp.Attributes {
+			if aws.StringValue(a.Key) == elbv2DeletionProtKey {
+				return aws.StringValue(a.Value) == "true", nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// isTerminationProtected returns whether termination protection of the root stack is enabled.
+// Nested stacks follow the setting of its root stack.
+func isTerminationProtected(stackId string) (bool, error) {
+	resp, err := CfnClient.DescribeStacks(&cloudformation.DescribeStacksInput{StackName: aws.String(stackId)})
+	if err != nil {
+		return false, err
+	}
+	for _, stack := range resp.Stacks {
+		if aws.BoolValue(stack.EnableTerminationProtection) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func disableTerminationProtection(stackId string) error {
+	_, err := CfnClient.UpdateTerminationProtection(&cloudformation.UpdateTerminationProtectionInput{
+		StackName:                   aws.String(stackId),
+		EnableTerminationProtection: aws.Bool(false),
+	})
+	return err
+}
@@ -0,0 +1,292 @@
+package concurrency
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Blue-Pix/abc/lib/lambda/stats"
+	"github.com/Blue-Pix/abc/lib/util"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+var LambdaClient lambdaiface.LambdaAPI
+var CloudWatchClient cloudwatchiface.CloudWatchAPI
+
+// maxDays is the retention of CloudWatch metrics (15 months).
+const maxDays = 455
+
+var (
+	format         string
+	days           int
+	minUtilization int
+	maxPoolShare   int
+)
+
+func NewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "concurrency",
+		Short: "Show concurrency of Lambda functions against the account limit",
+		Long: `
+[abc lambda concurrency]
+This command shows the concurrency limit of the account and unreserved concurrency,
+reserved concurrency of each function, and provisioned concurrency of each alias or version
+with its peak utilization in the last --days days.
+Only functions with reserved or provisioned concurrency, or any warning are listed.
+
+Warnings are shown for
+- provisioned concurrency whose peak utilization is below --min-utilization percent (over-provisioned)
+- functions without reserved concurrency, whose peak concurrency is over --max-pool-share percent
+  of unreserved concurrency, which may starve other functions sharing the pool
+- functions with reserved concurrency 0, which are always throttled
+
+Internally it uses aws lambda api and cloudwatch api.
+Please configure your aws credentials with following policies.
+- lambda:GetAccountSettings
+- lambda:ListFunctions
+- lambda:GetFunctionConcurrency
+- lambda:ListProvisionedConcurrencyConfigs
+- cloudwatch:GetMetricData`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := run(cmd, args)
+			return err
+		},
+	}
+	cmd.Flags().StringVarP(&format, "format", "f", "table", "output format (table or json)")
+	cmd.Flags().IntVar(&days, "days", 7, "number of days to look back for peak concurrency and utilization")
+	cmd.Flags().IntVar(&minUtilization, "min-utilization", 50, "(optional) peak utilization in percent below which provisioned concurrency is over-provisioned")
+	cmd.Flags().IntVar(&maxPoolShare, "max-pool-share", 50, "(optional) share of unreserved concurrency in percent a function may use at peak")
+	return cmd
+}
+
+func run(cmd *cobra.Command, args []string) error {
+	report, err := FetchData(cmd, args)
+	if err != nil {
+		return err
+	}
+	str, err := Output(report)
+	if err != nil {
+		return err
+	}
+	cmd.Println(str)
+	return nil
+}
+
+// Function is concurrency of a function.
+type Function struct {
+	Function string `json:"function"`
+	// Reserved is nil if reserved concurrency is not configured.
+	Reserved        *int64   `json:"reserved"`
+	PeakConcurrency int64    `json:"peak_concurrency"`
+	Provisioned     int64    `json:"provisioned"`
+	Warnings        []string `json:"warnings"`
+}
+
+// Provisioned is provisioned concurrency of an alias or version.
+type Provisioned struct {
+	Function  string `json:"function"`
+	Qualifier string `json:"qualifier"`
+	Requested int64  `json:"requested"`
+	Allocated int64  `json:"allocated"`
+	Status    string `json:"status"`
+	// PeakUtilization is the maximum of ProvisionedConcurrencyUtilization in percent.
+	PeakUtilization float64  `json:"peak_utilization"`
+	Warnings        []string `json:"warnings"`
+}
+
+// Report is concurrency of the account.
+type Report struct {
+	Limit       int64          `json:"limit"`
+	Unreserved  int64          `json:"unreserved"`
+	Reserved    int64          `json:"reserved"`
+	Functions   []*Function    `json:"functions"`
+	Provisioned []*Provisioned `json:"provisioned"`
+}
+
+func FetchData(cmd *cobra.Command, args []string) (*Report, error) {
+	if days < 1 || days > maxDays {
+		return nil, fmt.Errorf("--days must be between 1 and %d", maxDays)
+	}
+	initClient(cmd)
+	settings, err := LambdaClient.GetAccountSettings(&lambda.GetAccountSettingsInput{})
+	if err != nil {
+		return nil, err
+	}
+	report := &Report{Functions: []*Function{}, Provisioned: []*Provisioned{}}
+	if settings.AccountLimit != nil {
+		report.Limit = aws.Int64Value(settings.AccountLimit.ConcurrentExecutions)
+		report.Unreserved = aws.Int64Value(settings.AccountLimit.UnreservedConcurrentExecutions)
+		report.Reserved = report.Limit - report.Unreserved
+	}
+	functions, err := stats.ListFunctions(LambdaClient)
+	if err != nil {
+		return nil, err
+	}
+	var all []*Function
+	// ConcurrentExecutions of functions, followed by ProvisionedConcurrencyUtilization of configs.
+	var queries, utilizationQueries []*stats.MetricQuery
+	for _, f := range functions {
+		name := aws.StringValue(f.FunctionName)
+		resp, err := LambdaClient.GetFunctionConcurrency(&lambda.GetFunctionConcurrencyInput{FunctionName: f.FunctionName})
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, &Function{Function: name, Reserved: resp.ReservedConcurrentExecutions, Warnings: []string{}})
+		queries = append(queries, &stats.MetricQuery{Function: name, Metric: "ConcurrentExecutions", Stat: cloudwatch.StatisticMaximum})
+		configs, err := listProvisionedConcurrencyConfigs(name)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range configs {
+			arn := aws.StringValue(c.FunctionArn)
+			p := &Provisioned{
+				Function:  name,
+				Qualifier: arn[strings.LastIndex(arn, ":")+1:],
+				Requested: aws.Int64Value(c.RequestedProvisionedConcurrentExecutions),
+				Allocated: aws.Int64Value(c.AllocatedProvisionedConcurrentExecutions),
+				Status:    aws.StringValue(c.Status),
+				Warnings:  []string{},
+			}
+			report.Provisioned = append(report.Provisioned, p)
+			utilizationQueries = append(utilizationQueries, &stats.MetricQuery{Function: name, Resource: name + ":" + p.Qualifier, Metric: "ProvisionedConcurrencyUtilization", Stat: cloudwatch.StatisticMaximum})
+		}
+	}
+	end := stats.Now()
+	start := end.Add(-time.Duration(days) * 24 * time.Hour)
+	values, err := stats.GetMetrics(CloudWatchClient, append(queries, utilizationQueries...), start, end)
+	if err != nil {
+		return nil, err
+	}
+	for i, f := range all {
+		f.PeakConcurrency = int64(values[i])
+	}
+	provisioned := make(map[string]int64)
+	for i, p := range report.Provisioned {
+		p.PeakUtilization = values[len(all)+i] * 100
+		provisioned[p.Function] += p.Requested
+		if p.PeakUtilization < float64(minUtilization) {
+			p.Warnings = append(p.Warnings, fmt.Sprintf("over-provisioned (peak %.0f%%)", p.PeakUtilization))
+		}
+	}
+	for _, f := range all {
+		f.Provisioned = provisioned[f.Function]
+		if f.Reserved != nil && aws.Int64Value(f.Reserved) == 0 {
+			f.Warnings = append(f.Warnings, "always throttled (reserved 0)")
+		}
+		if f.Reserved == nil && report.Unreserved > 0 && f.PeakConcurrency*100 >= report.Unreserved*int64(maxPoolShare) {
+			f.Warnings = append(f.Warnings, fmt.Sprintf("may starve unreserved pool (peak %d of %d)", f.PeakConcurrency, report.Unreserved))
+		}
+		if f.Reserved != nil || f.Provisioned > 0 || len(f.Warnings) > 0 {
+			report.Functions = append(report.Functions, f)
+		}
+	}
+	sort.SliceStable(report.Functions, func(i, j int) bool { return report.Functions[i].Function < report.Functions[j].Function })
+	sort.SliceStable(report.Provisioned, func(i, j int) bool { return report.Provisioned[i].Function < report.Provisioned[j].Function })
+	return report, nil
+}
+
+func initClient(cmd *cobra.Command) {
+	profile, _ := cmd.Flags().GetString("profile")
+	region, _ := cmd.Flags().GetString("region")
+	if LambdaClient == nil {
+		sess := util.CreateSession(profile, region)
+		LambdaClient = lambda.New(sess)
+	}
+	if CloudWatchClient == nil {
+		sess := util.CreateSession(profile, region)
+		CloudWatchClient = cloudwatch.New(sess)
+	}
+}
+
+func listProvisionedConcurrencyConfigs(name string) ([]*lambda.ProvisionedConcurrencyConfigListItem, error) {
+	var result []*lambda.ProvisionedConcurrencyConfigListItem
+	var nextMarker *string
+	for {
+		params := &lambda.ListProvisionedConcurrencyConfigsInput{
+			FunctionName: aws.String(name),
+			MaxItems:     aws.Int64(50),
+			Marker:       nextMarker,
+		}
+		resp, err := LambdaClient.ListProvisionedConcurrencyConfigs(params)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, resp.ProvisionedConcurrencyConfigs...)
+		if resp.NextMarker == nil {
+			break
+		}
+		nextMarker = resp.NextMarker
+	}
+	return result, nil
+}
+
+func Output(report *Report) (string, error) {
+	if format == "json" {
+		jsonBytes, err := json.Marshal(report)
+		return string(jsonBytes), err
+	} else if format == "table" {
+		return tableOutput(report), nil
+	}
+	return "", errors.New("invalid format.")
+}
+
+func warnings(w []string) string {
+	if len(w) == 0 {
+		return "-"
+	}
+	return strings.Join(w, ", ")
+}
+
+func tableOutput(report *Report) string {
+	tableString := &strings.Builder{}
+	fmt.Fprintf(tableString, "Account limit: %d, Reserved: %d, Unreserved: %d\n", report.Limit, report.Reserved, report.Unreserved)
+	if len(report.Functions) > 0 {
+		tableString.WriteString("\n")
+		table := tablewriter.NewWriter(tableString)
+		table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
+		table.SetCenterSeparator("|")
+		table.SetAutoWrapText(false)
+		table.SetHeader([]string{"Function", "Reserved", "Provisioned", "Peak Concurrency", "Warning"})
+		table.SetColumnAlignment([]int{tablewriter.ALIGN_LEFT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_LEFT})
+		for _, f := range report.Functions {
+			reserved := "-"
+			if f.Reserved != nil {
+				reserved = strconv.FormatInt(aws.Int64Value(f.Reserved), 10)
+			}
+			table.Append([]string{f.Function, reserved, strconv.FormatInt(f.Provisioned, 10), strconv.FormatInt(f.PeakConcurrency, 10), warnings(f.Warnings)})
+		}
+		table.Render()
+	}
+	if len(report.Provisioned) > 0 {
+		tableString.WriteString("\n")
+		table := tablewriter.NewWriter(tableString)
+		table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
+		table.SetCenterSeparator("|")
+		table.SetAutoWrapText(false)
+		table.SetHeader([]string{"Function", "Qualifier", "Requested", "Allocated", "Status", "Peak Utilization", "Warning"})
+		table.SetColumnAlignment([]int{tablewriter.ALIGN_LEFT, tablewriter.ALIGN_LEFT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_LEFT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_LEFT})
+		for _, p := range report.Provisioned {
+			table.Append([]string{
+				p.Function,
+				p.Qualifier,
+				strconv.FormatInt(p.Requested, 10),
+				strconv.FormatInt(p.Allocated, 10),
+				p.Status,
+				fmt.Sprintf("%.0f%%", p.PeakUtilization),
+				warnings(p.Warnings),
+			})
+		}
+		table.Render()
+	}
+	return tableString.String()
+}
@@ -0,0 +1,84 @@
+package concurrency_test
+
+import (
+	"testing"
+
+	"github.com/Blue-Pix/abc/lib/lambda/concurrency"
+	"github.com/Blue-Pix/abc/lib/util/testutil"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/stretchr/testify/assert"
+)
+
+func initMockClient(lm *concurrency.MockLambdaClient, cm *concurrency.MockCloudWatchClient) {
+	concurrency.SetMockDefaultBehaviour(lm, cm)
+	concurrency.LambdaClient = lm
+	concurrency.CloudWatchClient = cm
+}
+
+func TestOutput(t *testing.T) {
+	t.Run("table format", func(t *testing.T) {
+		lm := &concurrency.MockLambdaClient{}
+		cm := &concurrency.MockCloudWatchClient{}
+		initMockClient(lm, cm)
+
+		expected := "Account limit: 1000, Reserved: 300, Unreserved: 700\n"
+		expected += "\n"
+		expected += "| FUNCTION | RESERVED | PROVISIONED | PEAK CONCURRENCY |                   WARNING                    |\n"
+		expected += "|----------|----------|-------------|------------------|----------------------------------------------|\n"
+		expected += "| api      |      200 |          50 |              180 | -                                            |\n"
+		expected += "| batch    |        - |           0 |              400 | may starve unreserved pool (peak 400 of 700) |\n"
+		expected += "| paused   |        0 |           0 |                0 | always throttled (reserved 0)                |\n"
+		expected += "| worker   |      100 |          20 |                8 | -                                            |\n"
+		expected += "\n"
+		expected += "| FUNCTION | QUALIFIER | REQUESTED | ALLOCATED |   STATUS    | PEAK UTILIZATION |           WARNING           |\n"
+		expected += "|----------|-----------|-----------|-----------|-------------|------------------|-----------------------------|\n"
+		expected += "| api      | live      |        50 |        30 | IN_PROGRESS |              90% | -                           |\n"
+		expected += "| worker   | 3         |        20 |        20 | READY       |              10% | over-provisioned (peak 10%) |\n"
+		expected += "\n"
+
+		out, _, err := testutil.Execute(concurrency.NewCmd())
+		assert.Nil(t, err)
+		assert.Equal(t, expected, out)
+		params := cm.Calls[0].Arguments.Get(0).(*cloudwatch.GetMetricDataInput)
+		stat := params.MetricDataQueries[6].MetricStat
+		assert.Equal(t, "ProvisionedConcurrencyUtilization", aws.StringValue(stat.Metric.MetricName))
+		assert.Equal(t, "api:live", aws.StringValue(stat.Metric.Dimensions[1].Value))
+		assert.Equal(t, "Maximum", aws.StringValue(stat.Stat))
+	})
+
+	t.Run("json format", func(t *testing.T) {
+		lm := &concurrency.MockLambdaClient{}
+		cm := &concurrency.MockCloudWatchClient{}
+		initMockClient(lm, cm)
+
+		expected := "{\"limit\":1000,\"unreserved\":700,\"reserved\":300,\"functions\":["
+		expected += "{\"function\":\"api\",\"reserved\":200,\"peak_concurrency\":180,\"provisioned\":50,\"warnings\":[]},"
+		expected += "{\"function\":\"batch\",\"reserved\":null,\"peak_concurrency\":400,\"provisioned\":0,\"warnings\":[\"may starve unreserved pool (peak 400 of 700)\"]},"
+		expected += "{\"function\":\"paused\",\"reserved\":0,\"peak_concurrency\":0,\"provisioned\":0,\"warnings\":[\"always throttled (reserved 0)\"]},"
+		expected += "{\"function\":\"worker\",\"reserved\":100,\"peak_concurrency\":8,\"provisioned\":20,\"warnings\":[]}],"
+		expected += "\"provisioned\":["
+		expected += "{\"function\":\"api\",\"qualifier\":\"live\",\"requested\":50,\"allocated\":30,\"status\":\"IN_PROGRESS\",\"peak_utilization\":90,\"warnings\":[]},"
+		expected += "{\"function\":\"worker\",\"qualifier\":\"3\",\"requested\":20,\"allocated\":20,\"status\":\"READY\",\"peak_utilization\":10,\"warnings\":[\"over-provisioned (peak 10%)\"]}]}\n"
+
+		out, _, err := testutil.Execute(concurrency.NewCmd(), "--format", "json")
+		assert.Nil(t, err)
+		assert.Equal(t, expected, out)
+	})
+
+	t.Run("thresholds", func(t *testing.T) {
+		lm := &concurrency.MockLambdaClient{}
+		cm := &concurrency.MockCloudWatchClient{}
+		initMockClient(lm, cm)
+
+		cmd := concurrency.NewCmd()
+		cmd.Flags().Set("min-utilization", "5")
+		cmd.Flags().Set("max-pool-share", "60")
+		report, err := concurrency.FetchData(cmd, []string{})
+		assert.Nil(t, err)
+		assert.Equal(t, []string{}, report.Provisioned[1].Warnings)
+		for _, f := range report.Functions {
+			assert.NotEqual(t, "batch", f.Function)
+		}
+	})
+}
@@ -0,0 +1,139 @@
+package concurrency
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockLambdaClient struct {
+	mock.Mock
+	lambdaiface.LambdaAPI
+}
+
+type MockCloudWatchClient struct {
+	mock.Mock
+	cloudwatchiface.CloudWatchAPI
+}
+
+func (client *MockLambdaClient) GetAccountSettings(params *lambda.GetAccountSettingsInput) (*lambda.GetAccountSettingsOutput, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*lambda.GetAccountSettingsOutput), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
+func (client *MockLambdaClient) ListFunctions(params *lambda.ListFunctionsInput) (*lambda.ListFunctionsOutput, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*lambda.ListFunctionsOutput), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
+func (client *MockLambdaClient) GetFunctionConcurrency(params *lambda.GetFunctionConcurrencyInput) (*lambda.GetFunctionConcurrencyOutput, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*lambda.GetFunctionConcurrencyOutput), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
+func (client *MockLambdaClient) ListProvisionedConcurrencyConfigs(params *lambda.ListProvisionedConcurrencyConfigsInput) (*lambda.ListProvisionedConcurrencyConfigsOutput, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*lambda.ListProvisionedConcurrencyConfigsOutput), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
+func (client *MockCloudWatchClient) GetMetricData(params *cloudwatch.GetMetricDataInput) (*cloudwatch.GetMetricDataOutput, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*cloudwatch.GetMetricDataOutput), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
+func metricResult(id string, values ...float64) *cloudwatch.MetricDataResult {
+	return &cloudwatch.MetricDataResult{Id: aws.String(id), Values: aws.Float64Slice(values)}
+}
+
+func provisionedConfig(name string, qualifier string, requested int64, allocated int64, status string) *lambda.ProvisionedConcurrencyConfigListItem {
+	return &lambda.ProvisionedConcurrencyConfigListItem{
+		FunctionArn:                              aws.String("arn:aws:lambda:ap-northeast-1:123456789012:function:" + name + ":" + qualifier),
+		RequestedProvisionedConcurrentExecutions: aws.Int64(requested),
+		AllocatedProvisionedConcurrentExecutions: aws.Int64(allocated),
+		Status:                                   aws.String(status),
+	}
+}
+
+func SetMockDefaultBehaviour(lm *MockLambdaClient, cm *MockCloudWatchClient) {
+	lm.On("GetAccountSettings", &lambda.GetAccountSettingsInput{}).Return(
+		&lambda.GetAccountSettingsOutput{
+			AccountLimit: &lambda.AccountLimit{ConcurrentExecutions: aws.Int64(1000), UnreservedConcurrentExecutions: aws.Int64(700)},
+		},
+		nil,
+	)
+	lm.On("ListFunctions", &lambda.ListFunctionsInput{MaxItems: aws.Int64(1000)}).Return(
+		&lambda.ListFunctionsOutput{
+			Functions: []*lambda.FunctionConfiguration{
+				{FunctionName: aws.String("worker")},
+				{FunctionName: aws.String("api")},
+				{FunctionName: aws.String("batch")},
+				{FunctionName: aws.String("paused")},
+				{FunctionName: aws.String("quiet")},
+			},
+		},
+		nil,
+	)
+	reserved := map[string]*int64{"api": aws.Int64(200), "worker": aws.Int64(100), "paused": aws.Int64(0), "batch": nil, "quiet": nil}
+	for name, r := range reserved {
+		lm.On("GetFunctionConcurrency", &lambda.GetFunctionConcurrencyInput{FunctionName: aws.String(name)}).Return(
+			&lambda.GetFunctionConcurrencyOutput{ReservedConcurrentExecutions: r},
+			nil,
+		)
+	}
+	lm.On("ListProvisionedConcurrencyConfigs", &lambda.ListProvisionedConcurrencyConfigsInput{FunctionName: aws.String("worker"), MaxItems: aws.Int64(50)}).Return(
+		&lambda.ListProvisionedConcurrencyConfigsOutput{
+			ProvisionedConcurrencyConfigs: []*lambda.ProvisionedConcurrencyConfigListItem{provisionedConfig("worker", "3", 20, 20, "READY")},
+		},
+		nil,
+	)
+	lm.On("ListProvisionedConcurrencyConfigs", &lambda.ListProvisionedConcurrencyConfigsInput{FunctionName: aws.String("api"), MaxItems: aws.Int64(50)}).Return(
+		&lambda.ListProvisionedConcurrencyConfigsOutput{
+			ProvisionedConcurrencyConfigs: []*lambda.ProvisionedConcurrencyConfigListItem{provisionedConfig("api", "live", 50, 30, "IN_PROGRESS")},
+		},
+		nil,
+	)
+	lm.On("ListProvisionedConcurrencyConfigs", mock.AnythingOfType("*lambda.ListProvisionedConcurrencyConfigsInput")).Return(
+		&lambda.ListProvisionedConcurrencyConfigsOutput{},
+		nil,
+	)
+
+	// q0-q4: ConcurrentExecutions of worker, api, batch, paused and quiet,
+	// q5-q6: ProvisionedConcurrencyUtilization of worker:3 and api:live.
+	cm.On("GetMetricData", mock.AnythingOfType("*cloudwatch.GetMetricDataInput")).Return(
+		&cloudwatch.GetMetricDataOutput{
+			MetricDataResults: []*cloudwatch.MetricDataResult{
+				metricResult("q0", 8),
+				metricResult("q1", 120, 180),
+				metricResult("q2", 400),
+				metricResult("q3"),
+				metricResult("q4", 5),
+				metricResult("q5", 0.1),
+				metricResult("q6", 0.9),
+			},
+		},
+		nil,
+	)
+}
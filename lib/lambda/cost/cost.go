@@ -0,0 +1,381 @@
+package cost
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Blue-Pix/abc/lib/lambda/stats"
+	"github.com/Blue-Pix/abc/lib/util"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+var LambdaClient lambdaiface.LambdaAPI
+var CloudWatchClient cloudwatchiface.CloudWatchAPI
+
+// stackNameTag is set to resources by CloudFormation.
+const stackNameTag = "aws:cloudformation:stack-name"
+
+// tagKeyPrefix is prefix of group key to group by the value of the tag.
+const tagKeyPrefix = "tag:"
+
+// noValue is shown as the group of functions without the value, like functions not managed by CloudFormation.
+const noValue = "-"
+
+// maxDays is the retention of CloudWatch metrics (15 months).
+const maxDays = 455
+
+// freeEphemeralStorage is ephemeral storage in MB included in the price of duration.
+const freeEphemeralStorage = 512
+
+// groupKeys are available for --group-by, in addition to tag:<key>.
+var groupKeys = []string{"runtime", "stack"}
+
+var (
+	format      string
+	days        int
+	groupBy     string
+	pricingFile string
+)
+
+func NewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cost",
+		Short: "Estimate cost of Lambda functions",
+		Long: `
+[abc lambda cost]
+This command estimates cost of each function in the last --days days,
+from memory size, architecture and ephemeral storage of the function,
+and Duration and Invocations metrics in CloudWatch.
+With --group-by, cost is rolled up by runtime, CloudFormation stack, or tag:<key>.
+
+Prices are on-demand prices of us-east-1, without free tier and tiered discounts.
+They can be overwritten by --pricing with JSON file of prices by region, like
+{"ap-east-1": {"x86_64": 0.0000166667, "arm64": 0.0000133334, "requests": 0.2, "ephemeral_storage": 0.0000000309}}
+where "default" is used for regions not in the file.
+
+Internally it uses aws lambda api and cloudwatch api.
+Please configure your aws credentials with following policies.
+- lambda:ListFunctions
+- lambda:ListTags (with --group-by stack or tag:<key>)
+- cloudwatch:GetMetricData`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := run(cmd, args)
+			return err
+		},
+	}
+	cmd.Flags().StringVarP(&format, "format", "f", "table", "output format (table, csv or json)")
+	cmd.Flags().IntVar(&days, "days", 30, "number of days to estimate")
+	cmd.Flags().StringVar(&groupBy, "group-by", "", "(optional) roll up cost by runtime, stack or tag:<key>")
+	cmd.Flags().StringVar(&pricingFile, "pricing", "", "(optional) JSON file to add or overwrite prices by region")
+	return cmd
+}
+
+func run(cmd *cobra.Command, args []string) error {
+	functions, err := FetchData(cmd, args)
+	if err != nil {
+		return err
+	}
+	if len(functions) == 0 && format == "table" {
+		cmd.Println("no function found.")
+		return nil
+	}
+	var str string
+	if groupBy == "" {
+		str, err = Output(functions)
+	} else {
+		str, err = OutputGroups(Group(functions))
+	}
+	if err != nil {
+		return err
+	}
+	cmd.Println(str)
+	return nil
+}
+
+// FunctionCost is estimated cost of a function.
+type FunctionCost struct {
+	Function     string  `json:"function"`
+	Runtime      string  `json:"runtime"`
+	Architecture string  `json:"architecture"`
+	Memory       int64   `json:"memory"`
+	Invocations  int64   `json:"invocations"`
+	GBSeconds    float64 `json:"gb_seconds"`
+	RequestCost  float64 `json:"request_cost"`
+	ComputeCost  float64 `json:"compute_cost"`
+	Cost         float64 `json:"cost"`
+	// Stack and Tags are set only with --group-by stack or tag:<key>.
+	Stack string            `json:"-"`
+	Tags  map[string]string `json:"-"`
+}
+
+// GroupCost is estimated cost of functions in a group.
+type GroupCost struct {
+	Group       string  `json:"group"`
+	Functions   int     `json:"functions"`
+	Invocations int64   `json:"invocations"`
+	GBSeconds   float64 `json:"gb_seconds"`
+	Cost        float64 `json:"cost"`
+}
+
+func validate() error {
+	if days < 1 || days > maxDays {
+		return fmt.Errorf("--days must be between 1 and %d", maxDays)
+	}
+	if groupBy == "" || (strings.HasPrefix(groupBy, tagKeyPrefix) && len(groupBy) > len(tagKeyPrefix)) {
+		return nil
+	}
+	for _, k := range groupKeys {
+		if groupBy == k {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid group key: %s (available: %s, tag:<key>)", groupBy, strings.Join(groupKeys, ", "))
+}
+
+// FetchData returns estimated cost of functions, in descending order of cost.
+func FetchData(cmd *cobra.Command, args []string) ([]*FunctionCost, error) {
+	if err := validate(); err != nil {
+		return nil, err
+	}
+	if pricingFile != "" {
+		if err := LoadPricing(pricingFile); err != nil {
+			return nil, err
+		}
+	}
+	initClient(cmd)
+	price := LookupPrice(regionOf(cmd))
+	functions, err := stats.ListFunctions(LambdaClient)
+	if err != nil {
+		return nil, err
+	}
+	var queries []*stats.MetricQuery
+	for _, f := range functions {
+		queries = append(queries,
+			&stats.MetricQuery{Function: aws.StringValue(f.FunctionName), Metric: "Duration", Stat: cloudwatch.StatisticSum},
+			&stats.MetricQuery{Function: aws.StringValue(f.FunctionName), Metric: "Invocations", Stat: cloudwatch.StatisticSum},
+		)
+	}
+	end := stats.Now()
+	start := end.Add(-time.Duration(days) * 24 * time.Hour)
+	values, err := stats.GetMetrics(CloudWatchClient, queries, start, end)
+	if err != nil {
+		return nil, err
+	}
+	result := []*FunctionCost{}
+	for i, f := range functions {
+		c := estimate(f, price, values[i*2]/1000, int64(values[i*2+1]))
+		if groupBy != "" && groupBy != "runtime" {
+			resp, err := LambdaClient.ListTags(&lambda.ListTagsInput{Resource: f.FunctionArn})
+			if err != nil {
+				return nil, err
+			}
+			c.Tags = aws.StringValueMap(resp.Tags)
+			c.Stack = c.Tags[stackNameTag]
+		}
+		result = append(result, c)
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].Cost > result[j].Cost })
+	return result, nil
+}
+
+func initClient(cmd *cobra.Command) {
+	profile, _ := cmd.Flags().GetString("profile")
+	region, _ := cmd.Flags().GetString("region")
+	if LambdaClient == nil {
+		sess := util.CreateSession(profile, region)
+		LambdaClient = lambda.New(sess)
+	}
+	if CloudWatchClient == nil {
+		sess := util.CreateSession(profile, region)
+		CloudWatchClient = cloudwatch.New(sess)
+	}
+}
+
+// regionOf returns the region to look up prices, from --region or the configuration of the client.
+func regionOf(cmd *cobra.Command) string {
+	region, _ := cmd.Flags().GetString("region")
+	if region == "" {
+		if c, ok := LambdaClient.(*lambda.Lambda); ok {
+			region = aws.StringValue(c.Config.Region)
+		}
+	}
+	return region
+}
+
+// estimate calculates cost of the function from total duration in seconds and invocations.
+func estimate(f *lambda.FunctionConfiguration, price *Price, seconds float64, invocations int64) *FunctionCost {
+	c := &FunctionCost{
+		Function:     aws.StringValue(f.FunctionName),
+		Runtime:      stats.RuntimeOf(f),
+		Architecture: lambda.ArchitectureX8664,
+		Memory:       aws.Int64Value(f.MemorySize),
+		Invocations:  invocations,
+	}
+	perGBSecond := price.X86
+	if len(f.Architectures) > 0 && aws.StringValue(f.Architectures[0]) == lambda.ArchitectureArm64 {
+		c.Architecture = lambda.ArchitectureArm64
+		perGBSecond = price.Arm
+	}
+	c.GBSeconds = seconds * float64(c.Memory) / 1024
+	c.ComputeCost = c.GBSeconds * perGBSecond
+	if f.EphemeralStorage != nil && aws.Int64Value(f.EphemeralStorage.Size) > freeEphemeralStorage {
+		c.ComputeCost += seconds * float64(aws.Int64Value(f.EphemeralStorage.Size)-freeEphemeralStorage) / 1024 * price.EphemeralStorage
+	}
+	c.RequestCost = float64(invocations) / 1000000 * price.Requests
+	c.Cost = c.ComputeCost + c.RequestCost
+	return c
+}
+
+// Group rolls up cost of functions by --group-by, in descending order of cost.
+func Group(functions []*FunctionCost) []*GroupCost {
+	groups := make(map[string]*GroupCost)
+	var names []string
+	for _, f := range functions {
+		name := groupOf(f)
+		g, ok := groups[name]
+		if !ok {
+			g = &GroupCost{Group: name}
+			groups[name] = g
+			names = append(names, name)
+		}
+		g.Functions++
+		g.Invocations += f.Invocations
+		g.GBSeconds += f.GBSeconds
+		g.Cost += f.Cost
+	}
+	result := []*GroupCost{}
+	for _, name := range names {
+		result = append(result, groups[name])
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].Cost > result[j].Cost })
+	return result
+}
+
+func groupOf(f *FunctionCost) string {
+	var v string
+	switch groupBy {
+	case "runtime":
+		v = f.Runtime
+	case "stack":
+		v = f.Stack
+	default:
+		v = f.Tags[strings.TrimPrefix(groupBy, tagKeyPrefix)]
+	}
+	if v == "" {
+		return noValue
+	}
+	return v
+}
+
+func totalCost(functions []*FunctionCost) float64 {
+	var n float64
+	for _, f := range functions {
+		n += f.Cost
+	}
+	return n
+}
+
+func Output(functions []*FunctionCost) (string, error) {
+	header := []string{"Function", "Runtime", "Architecture", "Memory", "Invocations", "GB-Seconds", "Cost"}
+	var rows [][]string
+	for _, f := range functions {
+		rows = append(rows, []string{
+			f.Function,
+			f.Runtime,
+			f.Architecture,
+			strconv.FormatInt(f.Memory, 10),
+			strconv.FormatInt(f.Invocations, 10),
+			fmt.Sprintf("%.1f", f.GBSeconds),
+			formatCost(f.Cost),
+		})
+	}
+	return output(functions, header, rows, totalCost(functions))
+}
+
+func OutputGroups(groups []*GroupCost) (string, error) {
+	header := []string{groupBy, "Functions", "Invocations", "GB-Seconds", "Cost"}
+	var rows [][]string
+	var total float64
+	for _, g := range groups {
+		rows = append(rows, []string{
+			g.Group,
+			strconv.Itoa(g.Functions),
+			strconv.FormatInt(g.Invocations, 10),
+			fmt.Sprintf("%.1f", g.GBSeconds),
+			formatCost(g.Cost),
+		})
+		total += g.Cost
+	}
+	return output(groups, header, rows, total)
+}
+
+func output(v interface{}, header []string, rows [][]string, total float64) (string, error) {
+	if format == "json" {
+		jsonBytes, err := json.Marshal(v)
+		return string(jsonBytes), err
+	} else if format == "csv" {
+		return csvOutput(header, rows)
+	} else if format == "table" {
+		return tableOutput(header, rows, total), nil
+	}
+	return "", errors.New("invalid format.")
+}
+
+// formatCost returns cost in dollars, or a plain number for csv.
+func formatCost(cost float64) string {
+	if format == "csv" {
+		return strconv.FormatFloat(cost, 'f', 4, 64)
+	}
+	return fmt.Sprintf("$%.2f", cost)
+}
+
+func csvOutput(header []string, rows [][]string) (string, error) {
+	csvString := &strings.Builder{}
+	w := csv.NewWriter(csvString)
+	columns := make([]string, len(header))
+	for i, h := range header {
+		columns[i] = strings.ToLower(h)
+	}
+	if err := w.Write(columns); err != nil {
+		return "", err
+	}
+	if err := w.WriteAll(rows); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(csvString.String(), "\n"), nil
+}
+
+func tableOutput(header []string, rows [][]string, total float64) string {
+	tableString := &strings.Builder{}
+	table := tablewriter.NewWriter(tableString)
+	table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
+	table.SetCenterSeparator("|")
+	table.SetHeader(header)
+	alignments := make([]int, len(header))
+	for i := range header {
+		alignments[i] = tablewriter.ALIGN_RIGHT
+	}
+	// group or function, and runtime and architecture without --group-by are text.
+	alignments[0] = tablewriter.ALIGN_LEFT
+	if groupBy == "" {
+		alignments[1] = tablewriter.ALIGN_LEFT
+		alignments[2] = tablewriter.ALIGN_LEFT
+	}
+	table.SetColumnAlignment(alignments)
+	table.AppendBulk(rows)
+	table.Render()
+	fmt.Fprintf(tableString, "\nTotal: %s in the last %d days (free tier is not considered).", formatCost(total), days)
+	return tableString.String()
+}
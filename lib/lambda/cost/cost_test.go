@@ -0,0 +1,150 @@
+package cost_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/Blue-Pix/abc/lib/lambda/cost"
+	"github.com/Blue-Pix/abc/lib/util/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func initMockClient(lm *cost.MockLambdaClient, cm *cost.MockCloudWatchClient) {
+	cost.SetMockDefaultBehaviour(lm, cm)
+	cost.LambdaClient = lm
+	cost.CloudWatchClient = cm
+}
+
+func TestOutput(t *testing.T) {
+	t.Run("table format", func(t *testing.T) {
+		lm := &cost.MockLambdaClient{}
+		cm := &cost.MockCloudWatchClient{}
+		initMockClient(lm, cm)
+
+		expected := "| FUNCTION |  RUNTIME   | ARCHITECTURE | MEMORY | INVOCATIONS | GB-SECONDS | COST  |\n"
+		expected += "|----------|------------|--------------|--------|-------------|------------|-------|\n"
+		expected += "| api      | nodejs20.x | x86_64       |   1024 |     2000000 |    36000.0 | $1.00 |\n"
+		expected += "| worker   | python3.12 | arm64        |   2048 |     1000000 |    36000.0 | $0.68 |\n"
+		expected += "| cron     | python3.12 | x86_64       |    128 |       10000 |      450.0 | $0.01 |\n"
+		expected += "\n"
+		expected += "Total: $1.69 in the last 30 days (free tier is not considered).\n"
+
+		out, _, err := testutil.Execute(cost.NewCmd())
+		assert.Nil(t, err)
+		assert.Equal(t, expected, out)
+		lm.AssertNumberOfCalls(t, "ListTags", 0)
+	})
+
+	t.Run("csv format", func(t *testing.T) {
+		lm := &cost.MockLambdaClient{}
+		cm := &cost.MockCloudWatchClient{}
+		initMockClient(lm, cm)
+
+		expected := "function,runtime,architecture,memory,invocations,gb-seconds,cost\n"
+		expected += "api,nodejs20.x,x86_64,1024,2000000,36000.0,1.0000\n"
+		expected += "worker,python3.12,arm64,2048,1000000,36000.0,0.6800\n"
+		expected += "cron,python3.12,x86_64,128,10000,450.0,0.0096\n"
+
+		out, _, err := testutil.Execute(cost.NewCmd(), "--format", "csv")
+		assert.Nil(t, err)
+		assert.Equal(t, expected, out)
+	})
+
+	t.Run("json format", func(t *testing.T) {
+		lm := &cost.MockLambdaClient{}
+		cm := &cost.MockCloudWatchClient{}
+		initMockClient(lm, cm)
+
+		expected := "[{\"function\":\"api\",\"runtime\":\"nodejs20.x\",\"architecture\":\"x86_64\",\"memory\":1024,\"invocations\":2000000,\"gb_seconds\":36000,\"request_cost\":0.4,\"compute_cost\":0.6000012,\"cost\":1.0000012},"
+		expected += "{\"function\":\"worker\",\"runtime\":\"python3.12\",\"architecture\":\"arm64\",\"memory\":2048,\"invocations\":1000000,\"gb_seconds\":36000,\"request_cost\":0.2,\"compute_cost\":0.4800024,\"cost\":0.6800024},"
+		expected += "{\"function\":\"cron\",\"runtime\":\"python3.12\",\"architecture\":\"x86_64\",\"memory\":128,\"invocations\":10000,\"gb_seconds\":450,\"request_cost\":0.002,\"compute_cost\":0.007555635000000001,\"cost\":0.009555635}]\n"
+
+		out, _, err := testutil.Execute(cost.NewCmd(), "--format", "json")
+		assert.Nil(t, err)
+		assert.Equal(t, expected, out)
+	})
+}
+
+func TestGroupBy(t *testing.T) {
+	t.Run("runtime", func(t *testing.T) {
+		lm := &cost.MockLambdaClient{}
+		cm := &cost.MockCloudWatchClient{}
+		initMockClient(lm, cm)
+
+		expected := "|  RUNTIME   | FUNCTIONS | INVOCATIONS | GB-SECONDS | COST  |\n"
+		expected += "|------------|-----------|-------------|------------|-------|\n"
+		expected += "| nodejs20.x |         1 |     2000000 |    36000.0 | $1.00 |\n"
+		expected += "| python3.12 |         2 |     1010000 |    36450.0 | $0.69 |\n"
+		expected += "\n"
+		expected += "Total: $1.69 in the last 30 days (free tier is not considered).\n"
+
+		out, _, err := testutil.Execute(cost.NewCmd(), "--group-by", "runtime")
+		assert.Nil(t, err)
+		assert.Equal(t, expected, out)
+		lm.AssertNumberOfCalls(t, "ListTags", 0)
+	})
+
+	t.Run("stack", func(t *testing.T) {
+		lm := &cost.MockLambdaClient{}
+		cm := &cost.MockCloudWatchClient{}
+		initMockClient(lm, cm)
+
+		expected := "stack,functions,invocations,gb-seconds,cost\n"
+		expected += "app,2,3000000,72000.0,1.6800\n"
+		expected += "-,1,10000,450.0,0.0096\n"
+
+		out, _, err := testutil.Execute(cost.NewCmd(), "--group-by", "stack", "--format", "csv")
+		assert.Nil(t, err)
+		assert.Equal(t, expected, out)
+	})
+
+	t.Run("tag", func(t *testing.T) {
+		lm := &cost.MockLambdaClient{}
+		cm := &cost.MockCloudWatchClient{}
+		initMockClient(lm, cm)
+
+		expected := "[{\"group\":\"-\",\"functions\":1,\"invocations\":2000000,\"gb_seconds\":36000,\"cost\":1.0000012},"
+		expected += "{\"group\":\"data\",\"functions\":1,\"invocations\":1000000,\"gb_seconds\":36000,\"cost\":0.6800024},"
+		expected += "{\"group\":\"ops\",\"functions\":1,\"invocations\":10000,\"gb_seconds\":450,\"cost\":0.009555635}]\n"
+
+		out, _, err := testutil.Execute(cost.NewCmd(), "--group-by", "tag:team", "--format", "json")
+		assert.Nil(t, err)
+		assert.Equal(t, expected, out)
+	})
+
+	t.Run("invalid key", func(t *testing.T) {
+		lm := &cost.MockLambdaClient{}
+		cm := &cost.MockCloudWatchClient{}
+		initMockClient(lm, cm)
+
+		_, _, err := testutil.Execute(cost.NewCmd(), "--group-by", "memory")
+		assert.EqualError(t, err, "invalid group key: memory (available: runtime, stack, tag:<key>)")
+	})
+}
+
+func TestPricing(t *testing.T) {
+	f, err := ioutil.TempFile("", "pricing*.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString(`{"test-region-1": {"x86_64": 0.00002, "arm64": 0.00001, "requests": 0.4, "ephemeral_storage": 0}}`)
+	f.Close()
+
+	lm := &cost.MockLambdaClient{}
+	cm := &cost.MockCloudWatchClient{}
+	initMockClient(lm, cm)
+
+	cmd := cost.NewCmd()
+	cmd.Flags().String("region", "", "")
+	cmd.Flags().Set("region", "test-region-1")
+	cmd.Flags().Set("pricing", f.Name())
+	functions, err := cost.FetchData(cmd, []string{})
+	assert.Nil(t, err)
+	assert.Equal(t, "api", functions[0].Function)
+	assert.InDelta(t, 36000*0.00002, functions[0].ComputeCost, 0.000001)
+	assert.InDelta(t, 0.8, functions[0].RequestCost, 0.000001)
+	assert.Equal(t, 0.4, cost.LookupPrice("test-region-1").Requests)
+	assert.Equal(t, 0.2, cost.LookupPrice("us-east-1").Requests)
+}
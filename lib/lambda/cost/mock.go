@@ -0,0 +1,95 @@
+package cost
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockLambdaClient struct {
+	mock.Mock
+	lambdaiface.LambdaAPI
+}
+
+type MockCloudWatchClient struct {
+	mock.Mock
+	cloudwatchiface.CloudWatchAPI
+}
+
+func (client *MockLambdaClient) ListFunctions(params *lambda.ListFunctionsInput) (*lambda.ListFunctionsOutput, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*lambda.ListFunctionsOutput), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
+func (client *MockLambdaClient) ListTags(params *lambda.ListTagsInput) (*lambda.ListTagsOutput, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*lambda.ListTagsOutput), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
+func (client *MockCloudWatchClient) GetMetricData(params *cloudwatch.GetMetricDataInput) (*cloudwatch.GetMetricDataOutput, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*cloudwatch.GetMetricDataOutput), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
+func functionArn(name string) *string {
+	return aws.String("arn:aws:lambda:ap-northeast-1:123456789012:function:" + name)
+}
+
+func metricResult(id string, values ...float64) *cloudwatch.MetricDataResult {
+	return &cloudwatch.MetricDataResult{Id: aws.String(id), Values: aws.Float64Slice(values)}
+}
+
+func SetMockDefaultBehaviour(lm *MockLambdaClient, cm *MockCloudWatchClient) {
+	lm.On("ListFunctions", &lambda.ListFunctionsInput{MaxItems: aws.Int64(1000)}).Return(
+		&lambda.ListFunctionsOutput{
+			Functions: []*lambda.FunctionConfiguration{
+				{FunctionName: aws.String("cron"), FunctionArn: functionArn("cron"), Runtime: aws.String("python3.12"), MemorySize: aws.Int64(128), EphemeralStorage: &lambda.EphemeralStorage{Size: aws.Int64(1024)}},
+				{FunctionName: aws.String("api"), FunctionArn: functionArn("api"), Runtime: aws.String("nodejs20.x"), MemorySize: aws.Int64(1024), Architectures: aws.StringSlice([]string{"x86_64"})},
+				{FunctionName: aws.String("worker"), FunctionArn: functionArn("worker"), Runtime: aws.String("python3.12"), MemorySize: aws.Int64(2048), Architectures: aws.StringSlice([]string{"arm64"})},
+			},
+		},
+		nil,
+	)
+	lm.On("ListTags", &lambda.ListTagsInput{Resource: functionArn("api")}).Return(
+		&lambda.ListTagsOutput{Tags: aws.StringMap(map[string]string{"aws:cloudformation:stack-name": "app"})},
+		nil,
+	)
+	lm.On("ListTags", &lambda.ListTagsInput{Resource: functionArn("worker")}).Return(
+		&lambda.ListTagsOutput{Tags: aws.StringMap(map[string]string{"aws:cloudformation:stack-name": "app", "team": "data"})},
+		nil,
+	)
+	lm.On("ListTags", &lambda.ListTagsInput{Resource: functionArn("cron")}).Return(
+		&lambda.ListTagsOutput{Tags: aws.StringMap(map[string]string{"team": "ops"})},
+		nil,
+	)
+
+	// q0-q1: cron, q2-q3: api, q4-q5: worker, each Duration in milliseconds and Invocations.
+	cm.On("GetMetricData", mock.AnythingOfType("*cloudwatch.GetMetricDataInput")).Return(
+		&cloudwatch.GetMetricDataOutput{
+			MetricDataResults: []*cloudwatch.MetricDataResult{
+				metricResult("q0", 3600000),
+				metricResult("q1", 10000),
+				metricResult("q2", 30000000, 6000000),
+				metricResult("q3", 1500000, 500000),
+				metricResult("q4", 18000000),
+				metricResult("q5", 1000000),
+			},
+		},
+		nil,
+	)
+}
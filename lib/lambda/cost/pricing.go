@@ -0,0 +1,57 @@
+package cost
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+)
+
+// defaultRegion is the key of prices used for regions not in the pricing table.
+const defaultRegion = "default"
+
+// Price is the on-demand price of Lambda in USD.
+type Price struct {
+	// X86 and Arm are the prices per GB-second of each architecture.
+	X86 float64 `json:"x86_64"`
+	Arm float64 `json:"arm64"`
+	// Requests is the price per 1M requests.
+	Requests float64 `json:"requests"`
+	// EphemeralStorage is the price per GB-second of ephemeral storage above 512 MB.
+	EphemeralStorage float64 `json:"ephemeral_storage"`
+}
+
+// pricingTable is prices by region, based on the first tier of us-east-1.
+// Free tier and tiered discounts are not considered.
+var pricingTable = map[string]*Price{
+	defaultRegion: {
+		X86:              0.0000166667,
+		Arm:              0.0000133334,
+		Requests:         0.20,
+		EphemeralStorage: 0.0000000309,
+	},
+}
+
+// LoadPricing reads JSON object of prices by region from the file,
+// and adds them to the pricing table. Prices of the same region, or "default", are overwritten.
+func LoadPricing(file string) error {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	var prices map[string]*Price
+	if err := json.Unmarshal(b, &prices); err != nil {
+		return fmt.Errorf("invalid pricing table %s: %s", file, err)
+	}
+	for region, p := range prices {
+		pricingTable[region] = p
+	}
+	return nil
+}
+
+// LookupPrice returns prices of the region, or default prices if the region is not in the table.
+func LookupPrice(region string) *Price {
+	if p, ok := pricingTable[region]; ok {
+		return p
+	}
+	return pricingTable[defaultRegion]
+}
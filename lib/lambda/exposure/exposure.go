@@ -0,0 +1,303 @@
+package exposure
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Blue-Pix/abc/lib/lambda/stats"
+	"github.com/Blue-Pix/abc/lib/util"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+var LambdaClient lambdaiface.LambdaAPI
+
+const (
+	severityCritical = "critical"
+	severityHigh     = "high"
+	severityMedium   = "medium"
+)
+
+// severityOrder sorts findings from the most severe.
+var severityOrder = map[string]int{severityCritical: 0, severityHigh: 1, severityMedium: 2}
+
+const (
+	findingPublicInvoke   = "public-invoke"
+	findingPublicUrl      = "public-function-url"
+	findingNoSourceFilter = "no-source-condition"
+)
+
+// sourceConditionKeys restrict grants to the resources or accounts of the caller, in lower case.
+var sourceConditionKeys = []string{"aws:sourceaccount", "aws:sourcearn", "aws:sourceowner", "aws:principalorgid"}
+
+// urlConditionKey is set to statements added for function URLs, which are checked with URL configs.
+const urlConditionKey = "lambda:functionurlauthtype"
+
+var format string
+
+func NewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "exposure",
+		Short: "Audit public invoke permissions and function URLs of Lambda functions",
+		Long: `
+[abc lambda exposure]
+This command reads the resource-based policy and function URL configs of all functions and their aliases,
+and reports findings with severity. Findings of an alias are reported with the function qualified by the alias.
+- critical: anyone (principal *) can invoke the function, without source account, source ARN or organization conditions
+- high: function URL with AuthType NONE, which anyone can call without authentication
+- medium: service principal can invoke the function without source account or source ARN conditions,
+  so resources of other accounts may invoke it (confused deputy)
+
+Internally it uses aws lambda api.
+Please configure your aws credentials with following policies.
+- lambda:ListFunctions
+- lambda:ListAliases
+- lambda:GetPolicy
+- lambda:ListFunctionUrlConfigs`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := run(cmd, args)
+			return err
+		},
+	}
+	cmd.Flags().StringVarP(&format, "format", "f", "table", "output format (table or json)")
+	return cmd
+}
+
+func run(cmd *cobra.Command, args []string) error {
+	findings, err := FetchData(cmd, args)
+	if err != nil {
+		return err
+	}
+	if len(findings) == 0 && format == "table" {
+		cmd.Println("no exposure found.")
+		return nil
+	}
+	str, err := Output(findings)
+	if err != nil {
+		return err
+	}
+	cmd.Println(str)
+	return nil
+}
+
+// Finding is an exposure of a function.
+type Finding struct {
+	// Function is qualified with the alias for findings of the alias, like api:live.
+	Function string `json:"function"`
+	Severity string `json:"severity"`
+	Type     string `json:"type"`
+	// Resource is the sid of the policy statement or the function URL.
+	Resource string `json:"resource"`
+	Message  string `json:"message"`
+}
+
+// FetchData returns findings sorted by severity and function.
+func FetchData(cmd *cobra.Command, args []string) ([]*Finding, error) {
+	initClient(cmd)
+	functions, err := stats.ListFunctions(LambdaClient)
+	if err != nil {
+		return nil, err
+	}
+	result := []*Finding{}
+	for _, f := range functions {
+		name := aws.StringValue(f.FunctionName)
+		aliases, err := listAliases(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list aliases of %s: %s", name, err)
+		}
+		// each alias has its own policy, the empty qualifier is the unqualified function.
+		for _, qualifier := range append([]string{""}, aliases...) {
+			policyFindings, err := auditPolicy(name, qualifier)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get policy of %s: %s", qualifiedName(name, qualifier), err)
+			}
+			result = append(result, policyFindings...)
+		}
+		urlFindings, err := auditUrls(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list function URLs of %s: %s", name, err)
+		}
+		result = append(result, urlFindings...)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Severity != result[j].Severity {
+			return severityOrder[result[i].Severity] < severityOrder[result[j].Severity]
+		}
+		return result[i].Function < result[j].Function
+	})
+	return result, nil
+}
+
+func initClient(cmd *cobra.Command) {
+	profile, _ := cmd.Flags().GetString("profile")
+	region, _ := cmd.Flags().GetString("region")
+	if LambdaClient == nil {
+		sess := util.CreateSession(profile, region)
+		LambdaClient = lambda.New(sess)
+	}
+}
+
+func isNotFound(err error) bool {
+	aerr, ok := err.(awserr.Error)
+	return ok && aerr.Code() == lambda.ErrCodeResourceNotFoundException
+}
+
+func listAliases(name string) ([]string, error) {
+	var result []string
+	var nextMarker *string
+	for {
+		params := &lambda.ListAliasesInput{
+			FunctionName: aws.String(name),
+			MaxItems:     aws.Int64(50),
+			Marker:       nextMarker,
+		}
+		resp, err := LambdaClient.ListAliases(params)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range resp.Aliases {
+			result = append(result, aws.StringValue(a.Name))
+		}
+		if resp.NextMarker == nil {
+			break
+		}
+		nextMarker = resp.NextMarker
+	}
+	return result, nil
+}
+
+// qualifiedName returns name:qualifier, or name for the empty qualifier.
+func qualifiedName(name string, qualifier string) string {
+	if qualifier == "" {
+		return name
+	}
+	return name + ":" + qualifier
+}
+
+// auditPolicy audits the policy of the function, or the alias if qualifier is not empty.
+func auditPolicy(name string, qualifier string) ([]*Finding, error) {
+	params := &lambda.GetPolicyInput{FunctionName: aws.String(name)}
+	if qualifier != "" {
+		params.Qualifier = aws.String(qualifier)
+	}
+	resp, err := LambdaClient.GetPolicy(params)
+	if err != nil {
+		if isNotFound(err) {
+			// no resource-based policy
+			return nil, nil
+		}
+		return nil, err
+	}
+	p, err := stats.ParsePolicy(aws.StringValue(resp.Policy))
+	if err != nil {
+		return nil, err
+	}
+	var result []*Finding
+	function := qualifiedName(name, qualifier)
+	for _, s := range p.Statement {
+		if s.Effect != "Allow" || s.HasCondition(urlConditionKey) || s.HasCondition(sourceConditionKeys...) {
+			continue
+		}
+		for _, principal := range s.Principals() {
+			if principal == "*" {
+				result = append(result, &Finding{
+					Function: function,
+					Severity: severityCritical,
+					Type:     findingPublicInvoke,
+					Resource: s.Sid,
+					Message:  "anyone can invoke the function",
+				})
+				break
+			}
+			if strings.HasSuffix(principal, ".amazonaws.com") {
+				result = append(result, &Finding{
+					Function: function,
+					Severity: severityMedium,
+					Type:     findingNoSourceFilter,
+					Resource: s.Sid,
+					Message:  fmt.Sprintf("%s can invoke the function without source account or ARN", principal),
+				})
+			}
+		}
+	}
+	return result, nil
+}
+
+// auditUrls audits function URLs of the function and its aliases, which are all listed by ListFunctionUrlConfigs.
+func auditUrls(name string) ([]*Finding, error) {
+	var result []*Finding
+	var nextMarker *string
+	for {
+		params := &lambda.ListFunctionUrlConfigsInput{
+			FunctionName: aws.String(name),
+			MaxItems:     aws.Int64(50),
+			Marker:       nextMarker,
+		}
+		resp, err := LambdaClient.ListFunctionUrlConfigs(params)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range resp.FunctionUrlConfigs {
+			if aws.StringValue(c.AuthType) != lambda.FunctionUrlAuthTypeNone {
+				continue
+			}
+			result = append(result, &Finding{
+				Function: qualifiedName(name, qualifierOf(aws.StringValue(c.FunctionArn))),
+				Severity: severityHigh,
+				Type:     findingPublicUrl,
+				Resource: aws.StringValue(c.FunctionUrl),
+				Message:  "function URL allows unauthenticated requests (AuthType NONE)",
+			})
+		}
+		if resp.NextMarker == nil {
+			break
+		}
+		nextMarker = resp.NextMarker
+	}
+	return result, nil
+}
+
+// qualifierOf returns the alias of the function arn, or empty string for unqualified arn.
+// e.g. arn:aws:lambda:<region>:<account>:function:api:live returns live.
+func qualifierOf(arn string) string {
+	parts := strings.Split(arn, ":")
+	if len(parts) < 8 {
+		return ""
+	}
+	return parts[7]
+}
+
+func Output(findings []*Finding) (string, error) {
+	if format == "json" {
+		jsonBytes, err := json.Marshal(findings)
+		return string(jsonBytes), err
+	} else if format == "table" {
+		return tableOutput(findings), nil
+	}
+	return "", errors.New("invalid format.")
+}
+
+func tableOutput(findings []*Finding) string {
+	tableString := &strings.Builder{}
+	table := tablewriter.NewWriter(tableString)
+	table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
+	table.SetCenterSeparator("|")
+	table.SetAutoWrapText(false)
+	table.SetHeader([]string{"Function", "Severity", "Type", "Resource", "Message"})
+	for _, f := range findings {
+		resource := f.Resource
+		if resource == "" {
+			resource = "-"
+		}
+		table.Append([]string{f.Function, f.Severity, f.Type, resource, f.Message})
+	}
+	table.Render()
+	return tableString.String()
+}
@@ -0,0 +1,70 @@
+package exposure_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Blue-Pix/abc/lib/lambda/exposure"
+	"github.com/Blue-Pix/abc/lib/util/testutil"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/stretchr/testify/assert"
+)
+
+func initMockClient(lm *exposure.MockLambdaClient) {
+	exposure.SetMockDefaultBehaviour(lm)
+	exposure.LambdaClient = lm
+}
+
+func TestOutput(t *testing.T) {
+	t.Run("table format", func(t *testing.T) {
+		lm := &exposure.MockLambdaClient{}
+		initMockClient(lm)
+
+		expected := "| FUNCTION | SEVERITY |        TYPE         |                                  RESOURCE                                  |                                 MESSAGE                                 |\n"
+		expected += "|----------|----------|---------------------|----------------------------------------------------------------------------|-------------------------------------------------------------------------|\n"
+		expected += "| api:live | critical | public-invoke       | public-live                                                                | anyone can invoke the function                                          |\n"
+		expected += "| public   | critical | public-invoke       | public                                                                     | anyone can invoke the function                                          |\n"
+		expected += "| api      | high     | public-function-url | https://abcdefghijklmnopqrstuvwxyz012345.lambda-url.ap-northeast-1.on.aws/ | function URL allows unauthenticated requests (AuthType NONE)            |\n"
+		expected += "| api:beta | high     | public-function-url | https://zyxwvutsrqponmlkjihgfedcba543210.lambda-url.ap-northeast-1.on.aws/ | function URL allows unauthenticated requests (AuthType NONE)            |\n"
+		expected += "| worker   | medium   | no-source-condition | sns                                                                        | sns.amazonaws.com can invoke the function without source account or ARN |\n"
+		expected += "\n"
+
+		out, _, err := testutil.Execute(exposure.NewCmd())
+		assert.Nil(t, err)
+		assert.Equal(t, expected, out)
+	})
+
+	t.Run("json format", func(t *testing.T) {
+		lm := &exposure.MockLambdaClient{}
+		initMockClient(lm)
+
+		expected := "[{\"function\":\"api:live\",\"severity\":\"critical\",\"type\":\"public-invoke\",\"resource\":\"public-live\",\"message\":\"anyone can invoke the function\"},"
+		expected += "{\"function\":\"public\",\"severity\":\"critical\",\"type\":\"public-invoke\",\"resource\":\"public\",\"message\":\"anyone can invoke the function\"},"
+		expected += "{\"function\":\"api\",\"severity\":\"high\",\"type\":\"public-function-url\",\"resource\":\"https://abcdefghijklmnopqrstuvwxyz012345.lambda-url.ap-northeast-1.on.aws/\",\"message\":\"function URL allows unauthenticated requests (AuthType NONE)\"},"
+		expected += "{\"function\":\"api:beta\",\"severity\":\"high\",\"type\":\"public-function-url\",\"resource\":\"https://zyxwvutsrqponmlkjihgfedcba543210.lambda-url.ap-northeast-1.on.aws/\",\"message\":\"function URL allows unauthenticated requests (AuthType NONE)\"},"
+		expected += "{\"function\":\"worker\",\"severity\":\"medium\",\"type\":\"no-source-condition\",\"resource\":\"sns\",\"message\":\"sns.amazonaws.com can invoke the function without source account or ARN\"}]\n"
+
+		out, _, err := testutil.Execute(exposure.NewCmd(), "--format", "json")
+		assert.Nil(t, err)
+		assert.Equal(t, expected, out)
+	})
+
+	t.Run("error of policy", func(t *testing.T) {
+		lm := &exposure.MockLambdaClient{}
+		lm.On("GetPolicy", &lambda.GetPolicyInput{FunctionName: aws.String("api")}).Return(nil, errors.New("AccessDeniedException"))
+		initMockClient(lm)
+
+		_, _, err := testutil.Execute(exposure.NewCmd())
+		assert.EqualError(t, err, "failed to get policy of api: AccessDeniedException")
+	})
+
+	t.Run("error of alias policy", func(t *testing.T) {
+		lm := &exposure.MockLambdaClient{}
+		lm.On("GetPolicy", &lambda.GetPolicyInput{FunctionName: aws.String("api"), Qualifier: aws.String("beta")}).Return(nil, errors.New("AccessDeniedException"))
+		initMockClient(lm)
+
+		_, _, err := testutil.Execute(exposure.NewCmd())
+		assert.EqualError(t, err, "failed to get policy of api:beta: AccessDeniedException")
+	})
+}
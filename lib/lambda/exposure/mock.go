@@ -0,0 +1,115 @@
+package exposure
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockLambdaClient struct {
+	mock.Mock
+	lambdaiface.LambdaAPI
+}
+
+func (client *MockLambdaClient) ListFunctions(params *lambda.ListFunctionsInput) (*lambda.ListFunctionsOutput, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*lambda.ListFunctionsOutput), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
+func (client *MockLambdaClient) ListAliases(params *lambda.ListAliasesInput) (*lambda.ListAliasesOutput, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*lambda.ListAliasesOutput), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
+func (client *MockLambdaClient) GetPolicy(params *lambda.GetPolicyInput) (*lambda.GetPolicyOutput, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*lambda.GetPolicyOutput), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
+func (client *MockLambdaClient) ListFunctionUrlConfigs(params *lambda.ListFunctionUrlConfigsInput) (*lambda.ListFunctionUrlConfigsOutput, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*lambda.ListFunctionUrlConfigsOutput), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
+const apiPolicy = `{"Version":"2012-10-17","Id":"default","Statement":[
+{"Sid":"FunctionURLAllowPublicAccess","Effect":"Allow","Principal":"*","Action":"lambda:InvokeFunctionUrl","Resource":"arn:aws:lambda:ap-northeast-1:123456789012:function:api","Condition":{"StringEquals":{"lambda:FunctionUrlAuthType":"NONE"}}},
+{"Sid":"apigateway","Effect":"Allow","Principal":{"Service":"apigateway.amazonaws.com"},"Action":"lambda:InvokeFunction","Resource":"arn:aws:lambda:ap-northeast-1:123456789012:function:api","Condition":{"ArnLike":{"AWS:SourceArn":"arn:aws:execute-api:ap-northeast-1:123456789012:abcdef/*"}}}]}`
+
+const workerPolicy = `{"Version":"2012-10-17","Id":"default","Statement":[
+{"Sid":"s3","Effect":"Allow","Principal":{"Service":"s3.amazonaws.com"},"Action":"lambda:InvokeFunction","Resource":"arn:aws:lambda:ap-northeast-1:123456789012:function:worker","Condition":{"StringEquals":{"AWS:SourceAccount":"123456789012"}}},
+{"Sid":"sns","Effect":"Allow","Principal":{"Service":"sns.amazonaws.com"},"Action":"lambda:InvokeFunction","Resource":"arn:aws:lambda:ap-northeast-1:123456789012:function:worker"}]}`
+
+// publicPolicy has a single statement without array.
+const publicPolicy = `{"Version":"2012-10-17","Id":"default","Statement":
+{"Sid":"public","Effect":"Allow","Principal":{"AWS":"*"},"Action":"lambda:InvokeFunction","Resource":"arn:aws:lambda:ap-northeast-1:123456789012:function:public"}}`
+
+// livePolicy is the policy of the alias api:live, while the unqualified function is not public.
+const livePolicy = `{"Version":"2012-10-17","Id":"default","Statement":[
+{"Sid":"public-live","Effect":"Allow","Principal":"*","Action":"lambda:InvokeFunction","Resource":"arn:aws:lambda:ap-northeast-1:123456789012:function:api:live"}]}`
+
+func SetMockDefaultBehaviour(lm *MockLambdaClient) {
+	lm.On("ListFunctions", &lambda.ListFunctionsInput{MaxItems: aws.Int64(1000)}).Return(
+		&lambda.ListFunctionsOutput{
+			Functions: []*lambda.FunctionConfiguration{
+				{FunctionName: aws.String("api")},
+				{FunctionName: aws.String("private")},
+				{FunctionName: aws.String("public")},
+				{FunctionName: aws.String("worker")},
+			},
+		},
+		nil,
+	)
+	lm.On("ListAliases", &lambda.ListAliasesInput{FunctionName: aws.String("api"), MaxItems: aws.Int64(50)}).Return(
+		&lambda.ListAliasesOutput{
+			NextMarker: aws.String("next_marker"),
+			Aliases:    []*lambda.AliasConfiguration{{Name: aws.String("live")}},
+		},
+		nil,
+	)
+	lm.On("ListAliases", &lambda.ListAliasesInput{FunctionName: aws.String("api"), MaxItems: aws.Int64(50), Marker: aws.String("next_marker")}).Return(
+		&lambda.ListAliasesOutput{
+			Aliases: []*lambda.AliasConfiguration{{Name: aws.String("beta")}},
+		},
+		nil,
+	)
+	lm.On("ListAliases", mock.AnythingOfType("*lambda.ListAliasesInput")).Return(&lambda.ListAliasesOutput{}, nil)
+	policies := map[string]string{"api": apiPolicy, "worker": workerPolicy, "public": publicPolicy}
+	for name, p := range policies {
+		lm.On("GetPolicy", &lambda.GetPolicyInput{FunctionName: aws.String(name)}).Return(&lambda.GetPolicyOutput{Policy: aws.String(p)}, nil)
+	}
+	lm.On("GetPolicy", &lambda.GetPolicyInput{FunctionName: aws.String("api"), Qualifier: aws.String("live")}).Return(&lambda.GetPolicyOutput{Policy: aws.String(livePolicy)}, nil)
+	// private and api:beta have no policy.
+	lm.On("GetPolicy", mock.AnythingOfType("*lambda.GetPolicyInput")).Return(
+		nil,
+		awserr.New(lambda.ErrCodeResourceNotFoundException, "The resource you requested does not exist.", nil),
+	)
+	lm.On("ListFunctionUrlConfigs", &lambda.ListFunctionUrlConfigsInput{FunctionName: aws.String("api"), MaxItems: aws.Int64(50)}).Return(
+		&lambda.ListFunctionUrlConfigsOutput{
+			FunctionUrlConfigs: []*lambda.FunctionUrlConfig{
+				{FunctionUrl: aws.String("https://abcdefghijklmnopqrstuvwxyz012345.lambda-url.ap-northeast-1.on.aws/"), AuthType: aws.String("NONE")},
+				{FunctionUrl: aws.String("https://0123456789abcdefghijklmnopqrstuv.lambda-url.ap-northeast-1.on.aws/"), AuthType: aws.String("AWS_IAM")},
+				{FunctionUrl: aws.String("https://zyxwvutsrqponmlkjihgfedcba543210.lambda-url.ap-northeast-1.on.aws/"), FunctionArn: aws.String("arn:aws:lambda:ap-northeast-1:123456789012:function:api:beta"), AuthType: aws.String("NONE")},
+			},
+		},
+		nil,
+	)
+	lm.On("ListFunctionUrlConfigs", mock.AnythingOfType("*lambda.ListFunctionUrlConfigsInput")).Return(&lambda.ListFunctionUrlConfigsOutput{}, nil)
+}
@@ -0,0 +1,193 @@
+package idle
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Blue-Pix/abc/lib/lambda/stats"
+	"github.com/Blue-Pix/abc/lib/util"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+var LambdaClient lambdaiface.LambdaAPI
+var CloudWatchClient cloudwatchiface.CloudWatchAPI
+
+// stackNameTag is set to resources by CloudFormation.
+const stackNameTag = "aws:cloudformation:stack-name"
+
+// maxDays is the retention of CloudWatch metrics (15 months).
+const maxDays = 455
+
+var (
+	format string
+	days   int
+)
+
+func NewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "idle",
+		Short: "List Lambda functions not invoked recently",
+		Long: `
+[abc lambda idle]
+This command lists functions which have no invocations in the last --days days,
+with last modified date, runtime and CloudFormation stack owning them.
+Invocations and Throttles of all functions are fetched from CloudWatch metrics in batches.
+Throttled requests are not counted as invocations, so idle functions with throttles may not be dead.
+
+Internally it uses aws lambda api and cloudwatch api.
+Please configure your aws credentials with following policies.
+- lambda:ListFunctions
+- lambda:ListTags
+- cloudwatch:GetMetricData`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := run(cmd, args)
+			return err
+		},
+	}
+	cmd.Flags().StringVarP(&format, "format", "f", "table", "output format (table or json)")
+	cmd.Flags().IntVar(&days, "days", 30, "number of days to look back")
+	return cmd
+}
+
+func run(cmd *cobra.Command, args []string) error {
+	functions, err := FetchData(cmd, args)
+	if err != nil {
+		return err
+	}
+	if len(functions) == 0 && format == "table" {
+		cmd.Printf("no function is idle in the last %d days.\n", days)
+		return nil
+	}
+	str, err := Output(functions)
+	if err != nil {
+		return err
+	}
+	cmd.Println(str)
+	return nil
+}
+
+// Function is a function with no invocations.
+type Function struct {
+	Function     string `json:"function"`
+	Runtime      string `json:"runtime"`
+	LastModified string `json:"last_modified"`
+	// Stack is the CloudFormation stack owning the function, empty if not managed by CloudFormation.
+	Stack     string `json:"stack"`
+	Throttles int64  `json:"throttles"`
+}
+
+// idleMetrics are fetched for each function in this order.
+// Errors is not fetched, since errors are counted in invocations and always 0 for idle functions.
+var idleMetrics = []string{"Invocations", "Throttles"}
+
+// FetchData returns idle functions, least recently modified first.
+func FetchData(cmd *cobra.Command, args []string) ([]*Function, error) {
+	if days < 1 || days > maxDays {
+		return nil, fmt.Errorf("--days must be between 1 and %d", maxDays)
+	}
+	initClient(cmd)
+	functions, err := stats.ListFunctions(LambdaClient)
+	if err != nil {
+		return nil, err
+	}
+	var queries []*stats.MetricQuery
+	for _, f := range functions {
+		for _, m := range idleMetrics {
+			queries = append(queries, &stats.MetricQuery{Function: aws.StringValue(f.FunctionName), Metric: m, Stat: cloudwatch.StatisticSum})
+		}
+	}
+	end := stats.Now()
+	start := end.Add(-time.Duration(days) * 24 * time.Hour)
+	values, err := stats.GetMetrics(CloudWatchClient, queries, start, end)
+	if err != nil {
+		return nil, err
+	}
+	result := []*Function{}
+	for i, f := range functions {
+		n := i * len(idleMetrics)
+		invocations, throttles := values[n], values[n+1]
+		if invocations > 0 {
+			continue
+		}
+		resp, err := LambdaClient.ListTags(&lambda.ListTagsInput{Resource: f.FunctionArn})
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, &Function{
+			Function:     aws.StringValue(f.FunctionName),
+			Runtime:      stats.RuntimeOf(f),
+			LastModified: aws.StringValue(f.LastModified),
+			Stack:        aws.StringValue(resp.Tags[stackNameTag]),
+			Throttles:    int64(throttles),
+		})
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].LastModified < result[j].LastModified })
+	return result, nil
+}
+
+func initClient(cmd *cobra.Command) {
+	profile, _ := cmd.Flags().GetString("profile")
+	region, _ := cmd.Flags().GetString("region")
+	if LambdaClient == nil {
+		sess := util.CreateSession(profile, region)
+		LambdaClient = lambda.New(sess)
+	}
+	if CloudWatchClient == nil {
+		sess := util.CreateSession(profile, region)
+		CloudWatchClient = cloudwatch.New(sess)
+	}
+}
+
+func Output(functions []*Function) (string, error) {
+	if format == "json" {
+		jsonBytes, err := json.Marshal(functions)
+		return string(jsonBytes), err
+	} else if format == "table" {
+		return tableOutput(functions), nil
+	}
+	return "", errors.New("invalid format.")
+}
+
+func tableOutput(functions []*Function) string {
+	tableString := &strings.Builder{}
+	table := tablewriter.NewWriter(tableString)
+	table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
+	table.SetCenterSeparator("|")
+	table.SetHeader([]string{"Function", "Runtime", "Last Modified", "Stack", "Throttles"})
+	table.SetColumnAlignment([]int{tablewriter.ALIGN_LEFT, tablewriter.ALIGN_LEFT, tablewriter.ALIGN_LEFT, tablewriter.ALIGN_LEFT, tablewriter.ALIGN_RIGHT})
+	for _, f := range functions {
+		stack := f.Stack
+		if stack == "" {
+			stack = "-"
+		}
+		table.Append([]string{
+			f.Function,
+			f.Runtime,
+			lastModifiedDate(f.LastModified),
+			stack,
+			strconv.FormatInt(f.Throttles, 10),
+		})
+	}
+	table.Render()
+	fmt.Fprintf(tableString, "\n%d functions have no invocations in the last %d days.", len(functions), days)
+	return tableString.String()
+}
+
+// lastModifiedDate returns YYYY-MM-DD part of LastModified, e.g. 2024-01-01T00:00:00.000+0000.
+func lastModifiedDate(s string) string {
+	if len(s) < 10 {
+		return s
+	}
+	return s[:10]
+}
@@ -0,0 +1,72 @@
+package idle_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Blue-Pix/abc/lib/lambda/idle"
+	"github.com/Blue-Pix/abc/lib/lambda/stats"
+	"github.com/Blue-Pix/abc/lib/util/testutil"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/stretchr/testify/assert"
+)
+
+func initMockClient(lm *idle.MockLambdaClient, cm *idle.MockCloudWatchClient) {
+	idle.SetMockDefaultBehaviour(lm, cm)
+	idle.LambdaClient = lm
+	idle.CloudWatchClient = cm
+}
+
+func TestOutput(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	stats.Now = func() time.Time { return now }
+	defer func() { stats.Now = time.Now }()
+
+	t.Run("table format", func(t *testing.T) {
+		lm := &idle.MockLambdaClient{}
+		cm := &idle.MockCloudWatchClient{}
+		initMockClient(lm, cm)
+
+		expected := "| FUNCTION |  RUNTIME   | LAST MODIFIED |    STACK     | THROTTLES |\n"
+		expected += "|----------|------------|---------------|--------------|-----------|\n"
+		expected += "| legacy   | nodejs12.x | 2021-01-01    | -            |         0 |\n"
+		expected += "| worker   | python3.12 | 2024-03-01    | worker-stack |         2 |\n"
+		expected += "\n"
+		expected += "2 functions have no invocations in the last 7 days.\n"
+
+		out, _, err := testutil.Execute(idle.NewCmd(), "--days", "7")
+		assert.Nil(t, err)
+		assert.Equal(t, expected, out)
+		cm.AssertNumberOfCalls(t, "GetMetricData", 2)
+		params := cm.Calls[0].Arguments.Get(0).(*cloudwatch.GetMetricDataInput)
+		assert.Equal(t, now.Add(-7*24*time.Hour), aws.TimeValue(params.StartTime))
+		assert.Equal(t, now, aws.TimeValue(params.EndTime))
+		assert.Equal(t, 6, len(params.MetricDataQueries))
+		assert.Equal(t, int64(7*24*3600), aws.Int64Value(params.MetricDataQueries[0].MetricStat.Period))
+		lm.AssertNumberOfCalls(t, "ListTags", 2)
+	})
+
+	t.Run("json format", func(t *testing.T) {
+		lm := &idle.MockLambdaClient{}
+		cm := &idle.MockCloudWatchClient{}
+		initMockClient(lm, cm)
+
+		expected := "[{\"function\":\"legacy\",\"runtime\":\"nodejs12.x\",\"last_modified\":\"2021-01-01T00:00:00.000+0000\",\"stack\":\"\",\"throttles\":0},"
+		expected += "{\"function\":\"worker\",\"runtime\":\"python3.12\",\"last_modified\":\"2024-03-01T00:00:00.000+0000\",\"stack\":\"worker-stack\",\"throttles\":2}]\n"
+
+		out, _, err := testutil.Execute(idle.NewCmd(), "--format", "json")
+		assert.Nil(t, err)
+		assert.Equal(t, expected, out)
+	})
+
+	t.Run("invalid days", func(t *testing.T) {
+		lm := &idle.MockLambdaClient{}
+		cm := &idle.MockCloudWatchClient{}
+		initMockClient(lm, cm)
+
+		_, _, err := testutil.Execute(idle.NewCmd(), "--days", "0")
+		assert.EqualError(t, err, "--days must be between 1 and 455")
+		lm.AssertNumberOfCalls(t, "ListFunctions", 0)
+	})
+}
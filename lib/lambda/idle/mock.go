@@ -0,0 +1,99 @@
+package idle
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockLambdaClient struct {
+	mock.Mock
+	lambdaiface.LambdaAPI
+}
+
+type MockCloudWatchClient struct {
+	mock.Mock
+	cloudwatchiface.CloudWatchAPI
+}
+
+func (client *MockLambdaClient) ListFunctions(params *lambda.ListFunctionsInput) (*lambda.ListFunctionsOutput, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*lambda.ListFunctionsOutput), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
+func (client *MockLambdaClient) ListTags(params *lambda.ListTagsInput) (*lambda.ListTagsOutput, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*lambda.ListTagsOutput), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
+func (client *MockCloudWatchClient) GetMetricData(params *cloudwatch.GetMetricDataInput) (*cloudwatch.GetMetricDataOutput, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*cloudwatch.GetMetricDataOutput), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
+func functionArn(name string) *string {
+	return aws.String("arn:aws:lambda:ap-northeast-1:123456789012:function:" + name)
+}
+
+func metricResult(id string, values ...float64) *cloudwatch.MetricDataResult {
+	return &cloudwatch.MetricDataResult{Id: aws.String(id), Values: aws.Float64Slice(values)}
+}
+
+func SetMockDefaultBehaviour(lm *MockLambdaClient, cm *MockCloudWatchClient) {
+	lm.On("ListFunctions", &lambda.ListFunctionsInput{MaxItems: aws.Int64(1000)}).Return(
+		&lambda.ListFunctionsOutput{
+			Functions: []*lambda.FunctionConfiguration{
+				{FunctionName: aws.String("api"), FunctionArn: functionArn("api"), Runtime: aws.String("nodejs20.x"), LastModified: aws.String("2024-05-01T00:00:00.000+0000")},
+				{FunctionName: aws.String("worker"), FunctionArn: functionArn("worker"), Runtime: aws.String("python3.12"), LastModified: aws.String("2024-03-01T00:00:00.000+0000")},
+				{FunctionName: aws.String("legacy"), FunctionArn: functionArn("legacy"), Runtime: aws.String("nodejs12.x"), LastModified: aws.String("2021-01-01T00:00:00.000+0000")},
+			},
+		},
+		nil,
+	)
+	lm.On("ListTags", &lambda.ListTagsInput{Resource: functionArn("worker")}).Return(
+		&lambda.ListTagsOutput{Tags: aws.StringMap(map[string]string{"aws:cloudformation:stack-name": "worker-stack"})},
+		nil,
+	)
+	lm.On("ListTags", mock.AnythingOfType("*lambda.ListTagsInput")).Return(&lambda.ListTagsOutput{}, nil)
+
+	// q0-q1: api, q2-q3: worker, q4-q5: legacy, each Invocations and Throttles.
+	cm.On("GetMetricData", mock.MatchedBy(func(params *cloudwatch.GetMetricDataInput) bool { return params.NextToken == nil })).Return(
+		&cloudwatch.GetMetricDataOutput{
+			NextToken: aws.String("next_token"),
+			MetricDataResults: []*cloudwatch.MetricDataResult{
+				metricResult("q0", 100),
+				metricResult("q1"),
+				metricResult("q2", 0),
+				metricResult("q3", 2),
+			},
+		},
+		nil,
+	)
+	cm.On("GetMetricData", mock.MatchedBy(func(params *cloudwatch.GetMetricDataInput) bool {
+		return aws.StringValue(params.NextToken) == "next_token"
+	})).Return(
+		&cloudwatch.GetMetricDataOutput{
+			MetricDataResults: []*cloudwatch.MetricDataResult{
+				metricResult("q0", 20),
+				metricResult("q4"),
+				metricResult("q5"),
+			},
+		},
+		nil,
+	)
+}
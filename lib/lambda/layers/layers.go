@@ -0,0 +1,360 @@
+package layers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Blue-Pix/abc/lib/lambda/stats"
+	"github.com/Blue-Pix/abc/lib/util"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+var LambdaClient lambdaiface.LambdaAPI
+
+var (
+	format        string
+	prune         bool
+	includeLatest bool
+	dryRun        bool
+)
+
+func NewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "layers",
+		Short: "List Lambda layers and functions using them",
+		Long: `
+[abc lambda layers]
+This command lists all versions of Lambda layers in the account,
+with compatible runtimes, size and functions using them.
+Only $LATEST of functions is checked, since published versions keep working
+after the layer version is deleted.
+
+With --prune, layer versions which no function uses are deleted.
+The latest version of each layer is kept even if it is not used, unless --include-latest is given.
+Layer versions whose policy grants access to principals outside the account are skipped,
+since other accounts may use them.
+
+Internally it uses aws lambda api.
+Please configure your aws credentials with following policies.
+- lambda:ListFunctions
+- lambda:ListLayers
+- lambda:ListLayerVersions
+- lambda:GetLayerVersion
+- lambda:GetLayerVersionPolicy (with --prune)
+- lambda:DeleteLayerVersion (with --prune)`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := run(cmd, args)
+			return err
+		},
+	}
+	cmd.Flags().StringVarP(&format, "format", "f", "table", "output format (table or json)")
+	cmd.Flags().BoolVar(&prune, "prune", false, "(optional) delete layer versions which no function uses")
+	cmd.Flags().BoolVar(&includeLatest, "include-latest", false, "(optional) delete the latest version of each layer too with --prune")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "(optional) show layer versions to delete without deleting them")
+	return cmd
+}
+
+func run(cmd *cobra.Command, args []string) error {
+	versions, err := FetchData(cmd, args)
+	if err != nil {
+		return err
+	}
+	if prune {
+		return runPrune(cmd, versions)
+	}
+	if len(versions) == 0 && format == "table" {
+		cmd.Println("no layer found.")
+		return nil
+	}
+	str, err := Output(versions)
+	if err != nil {
+		return err
+	}
+	cmd.Println(str)
+	return nil
+}
+
+func runPrune(cmd *cobra.Command, versions []*LayerVersion) error {
+	var unused []*LayerVersion
+	var skipped []string
+	for _, v := range Unused(versions) {
+		principals, err := SharedWith(v)
+		if err != nil {
+			return fmt.Errorf("failed to get policy of %s:%d: %s", v.Layer, v.Version, err)
+		}
+		if len(principals) > 0 {
+			skipped = append(skipped, fmt.Sprintf("%s:%d (shared with %s)", v.Layer, v.Version, strings.Join(principals, ", ")))
+			continue
+		}
+		unused = append(unused, v)
+	}
+	if len(skipped) > 0 {
+		cmd.Printf("Skipped:\n- %s\n", strings.Join(skipped, "\n- "))
+	}
+	if len(unused) == 0 {
+		cmd.Println("no layer version to delete.")
+		return nil
+	}
+	cmd.Println("Plan:")
+	for _, v := range unused {
+		cmd.Printf("- %s:%d (%s)\n", v.Layer, v.Version, util.FormatBytes(v.CodeSize))
+	}
+	if dryRun {
+		cmd.Printf("Dry run: %d layer versions (%s) would be deleted.\n", len(unused), util.FormatBytes(totalSize(unused)))
+		return nil
+	}
+	var deleted []*LayerVersion
+	for _, v := range unused {
+		_, err := LambdaClient.DeleteLayerVersion(&lambda.DeleteLayerVersionInput{
+			LayerName:     aws.String(v.Layer),
+			VersionNumber: aws.Int64(v.Version),
+		})
+		if err != nil {
+			fmt.Fprintf(cmd.ErrOrStderr(), "failed to delete %s:%d: %s\n", v.Layer, v.Version, err)
+			continue
+		}
+		cmd.Printf("%s:%d deleted.\n", v.Layer, v.Version)
+		deleted = append(deleted, v)
+	}
+	cmd.Printf("Freed %s by deleting %d layer versions.\n", util.FormatBytes(totalSize(deleted)), len(deleted))
+	if failed := len(unused) - len(deleted); failed > 0 {
+		return fmt.Errorf("failed to delete %d layer versions", failed)
+	}
+	return nil
+}
+
+// LayerVersion is a version of a layer with functions using it.
+type LayerVersion struct {
+	Layer              string   `json:"layer"`
+	Version            int64    `json:"version"`
+	Arn                string   `json:"arn"`
+	CompatibleRuntimes []string `json:"compatible_runtimes"`
+	CodeSize           int64    `json:"code_size"`
+	CreatedDate        string   `json:"created_date"`
+	Functions          []string `json:"functions"`
+	// Latest is whether it is the latest version of the layer.
+	Latest bool `json:"latest"`
+}
+
+func totalSize(versions []*LayerVersion) int64 {
+	var n int64
+	for _, v := range versions {
+		n += v.CodeSize
+	}
+	return n
+}
+
+// FetchData returns all layer versions sorted by layer name and version.
+func FetchData(cmd *cobra.Command, args []string) ([]*LayerVersion, error) {
+	initClient(cmd)
+	functions, err := stats.ListFunctions(LambdaClient)
+	if err != nil {
+		return nil, err
+	}
+	users := make(map[string][]string)
+	for _, f := range functions {
+		for _, l := range f.Layers {
+			users[aws.StringValue(l.Arn)] = append(users[aws.StringValue(l.Arn)], aws.StringValue(f.FunctionName))
+		}
+	}
+	layers, err := listLayers()
+	if err != nil {
+		return nil, err
+	}
+	result := []*LayerVersion{}
+	for _, layer := range layers {
+		items, err := listLayerVersions(layer.LayerName)
+		if err != nil {
+			return nil, err
+		}
+		var latest int64
+		var versions []*LayerVersion
+		for _, item := range items {
+			resp, err := LambdaClient.GetLayerVersion(&lambda.GetLayerVersionInput{
+				LayerName:     layer.LayerName,
+				VersionNumber: item.Version,
+			})
+			if err != nil {
+				return nil, err
+			}
+			v := &LayerVersion{
+				Layer:              aws.StringValue(layer.LayerName),
+				Version:            aws.Int64Value(item.Version),
+				Arn:                aws.StringValue(item.LayerVersionArn),
+				CompatibleRuntimes: aws.StringValueSlice(item.CompatibleRuntimes),
+				CreatedDate:        aws.StringValue(item.CreatedDate),
+				Functions:          users[aws.StringValue(item.LayerVersionArn)],
+			}
+			if resp.Content != nil {
+				v.CodeSize = aws.Int64Value(resp.Content.CodeSize)
+			}
+			if v.CompatibleRuntimes == nil {
+				v.CompatibleRuntimes = []string{}
+			}
+			if v.Functions == nil {
+				v.Functions = []string{}
+			}
+			if v.Version > latest {
+				latest = v.Version
+			}
+			versions = append(versions, v)
+		}
+		for _, v := range versions {
+			v.Latest = v.Version == latest
+		}
+		sort.Slice(versions, func(i, j int) bool { return versions[i].Version < versions[j].Version })
+		result = append(result, versions...)
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].Layer < result[j].Layer })
+	return result, nil
+}
+
+func initClient(cmd *cobra.Command) {
+	profile, _ := cmd.Flags().GetString("profile")
+	region, _ := cmd.Flags().GetString("region")
+	if LambdaClient == nil {
+		sess := util.CreateSession(profile, region)
+		LambdaClient = lambda.New(sess)
+	}
+}
+
+func listLayers() ([]*lambda.LayersListItem, error) {
+	var result []*lambda.LayersListItem
+	var nextMarker *string
+	for {
+		params := &lambda.ListLayersInput{
+			MaxItems: aws.Int64(50),
+			Marker:   nextMarker,
+		}
+		resp, err := LambdaClient.ListLayers(params)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, resp.Layers...)
+		if resp.NextMarker == nil {
+			break
+		}
+		nextMarker = resp.NextMarker
+	}
+	return result, nil
+}
+
+func listLayerVersions(name *string) ([]*lambda.LayerVersionsListItem, error) {
+	var result []*lambda.LayerVersionsListItem
+	var nextMarker *string
+	for {
+		params := &lambda.ListLayerVersionsInput{
+			LayerName: name,
+			MaxItems:  aws.Int64(50),
+			Marker:    nextMarker,
+		}
+		resp, err := LambdaClient.ListLayerVersions(params)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, resp.LayerVersions...)
+		if resp.NextMarker == nil {
+			break
+		}
+		nextMarker = resp.NextMarker
+	}
+	return result, nil
+}
+
+// Unused returns layer versions which no function uses.
+// The latest versions are excluded unless --include-latest.
+func Unused(versions []*LayerVersion) []*LayerVersion {
+	var result []*LayerVersion
+	for _, v := range versions {
+		if len(v.Functions) > 0 || (!includeLatest && v.Latest) {
+			continue
+		}
+		result = append(result, v)
+	}
+	return result
+}
+
+// SharedWith returns principals outside the account which the policy of the layer version grants access to.
+// The account is the owner of the layer in its arn. Principal * is outside the account, even with organization condition.
+func SharedWith(v *LayerVersion) ([]string, error) {
+	resp, err := LambdaClient.GetLayerVersionPolicy(&lambda.GetLayerVersionPolicyInput{
+		LayerName:     aws.String(v.Layer),
+		VersionNumber: aws.Int64(v.Version),
+	})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == lambda.ErrCodeResourceNotFoundException {
+			// no policy
+			return nil, nil
+		}
+		return nil, err
+	}
+	p, err := stats.ParsePolicy(aws.StringValue(resp.Policy))
+	if err != nil {
+		return nil, err
+	}
+	account := accountOf(v.Arn)
+	var result []string
+	for _, s := range p.Statement {
+		if s.Effect != "Allow" {
+			continue
+		}
+		for _, principal := range s.Principals() {
+			if principal != account && accountOf(principal) != account {
+				result = append(result, principal)
+			}
+		}
+	}
+	return result, nil
+}
+
+// accountOf returns the account id in the arn, or empty string if it is not an arn.
+func accountOf(arn string) string {
+	parts := strings.Split(arn, ":")
+	if len(parts) < 5 || parts[0] != "arn" {
+		return ""
+	}
+	return parts[4]
+}
+
+func Output(versions []*LayerVersion) (string, error) {
+	if format == "json" {
+		jsonBytes, err := json.Marshal(versions)
+		return string(jsonBytes), err
+	} else if format == "table" {
+		return tableOutput(versions), nil
+	}
+	return "", errors.New("invalid format.")
+}
+
+func tableOutput(versions []*LayerVersion) string {
+	tableString := &strings.Builder{}
+	table := tablewriter.NewWriter(tableString)
+	table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
+	table.SetCenterSeparator("|")
+	table.SetHeader([]string{"Layer", "Version", "Compatible Runtimes", "Size", "Functions"})
+	table.SetColumnAlignment([]int{tablewriter.ALIGN_LEFT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_LEFT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_LEFT})
+	for _, v := range versions {
+		functions := "-"
+		if len(v.Functions) > 0 {
+			functions = strings.Join(v.Functions, ", ")
+		}
+		table.Append([]string{
+			v.Layer,
+			strconv.FormatInt(v.Version, 10),
+			strings.Join(v.CompatibleRuntimes, ", "),
+			util.FormatBytes(v.CodeSize),
+			functions,
+		})
+	}
+	table.Render()
+	return tableString.String()
+}
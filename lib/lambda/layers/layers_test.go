@@ -0,0 +1,121 @@
+package layers_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Blue-Pix/abc/lib/lambda/layers"
+	"github.com/Blue-Pix/abc/lib/util/testutil"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/stretchr/testify/assert"
+)
+
+func initMockClient(lm *layers.MockLambdaClient) {
+	layers.SetMockDefaultBehaviour(lm)
+	layers.LambdaClient = lm
+}
+
+func TestOutput(t *testing.T) {
+	t.Run("table format", func(t *testing.T) {
+		lm := &layers.MockLambdaClient{}
+		initMockClient(lm)
+
+		expected := "| LAYER  | VERSION |  COMPATIBLE RUNTIMES   |  SIZE   | FUNCTIONS |\n"
+		expected += "|--------|---------|------------------------|---------|-----------|\n"
+		expected += "| common |       1 | python3.8              |  8.0 MB | -         |\n"
+		expected += "| common |       2 | python3.12             |  9.0 MB | worker    |\n"
+		expected += "| common |       3 | python3.12, python3.13 | 10.0 MB | api, cron |\n"
+		expected += "| otel   |       1 |                        |  4.0 MB | -         |\n"
+		expected += "\n"
+
+		out, _, err := testutil.Execute(layers.NewCmd())
+		assert.Nil(t, err)
+		assert.Equal(t, expected, out)
+	})
+
+	t.Run("json format", func(t *testing.T) {
+		lm := &layers.MockLambdaClient{}
+		initMockClient(lm)
+
+		expected := "[{\"layer\":\"otel\",\"version\":1,\"arn\":\"arn:aws:lambda:ap-northeast-1:123456789012:layer:otel:1\",\"compatible_runtimes\":[],\"code_size\":4194304,\"created_date\":\"2024-04-01T00:00:00.000+0000\",\"functions\":[],\"latest\":true}]"
+
+		cmd := layers.NewCmd()
+		cmd.Flags().Set("format", "json")
+		var args []string
+		versions, err := layers.FetchData(cmd, args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual, err := layers.Output(versions[3:])
+		assert.Equal(t, expected, actual)
+		assert.Nil(t, err)
+		assert.Equal(t, []bool{false, false, true, true}, []bool{versions[0].Latest, versions[1].Latest, versions[2].Latest, versions[3].Latest})
+	})
+}
+
+func TestPrune(t *testing.T) {
+	t.Run("dry run", func(t *testing.T) {
+		lm := &layers.MockLambdaClient{}
+		initMockClient(lm)
+
+		out, _, err := testutil.Execute(layers.NewCmd(), "--prune", "--dry-run")
+		assert.Nil(t, err)
+		assert.Equal(t, "Plan:\n- common:1 (8.0 MB)\nDry run: 1 layer versions (8.0 MB) would be deleted.\n", out)
+		lm.AssertNumberOfCalls(t, "DeleteLayerVersion", 0)
+	})
+
+	t.Run("include latest", func(t *testing.T) {
+		lm := &layers.MockLambdaClient{}
+		initMockClient(lm)
+
+		out, _, err := testutil.Execute(layers.NewCmd(), "--prune", "--include-latest")
+		assert.Nil(t, err)
+		assert.Equal(t, "Plan:\n- common:1 (8.0 MB)\n- otel:1 (4.0 MB)\ncommon:1 deleted.\notel:1 deleted.\nFreed 12.0 MB by deleting 2 layer versions.\n", out)
+		lm.AssertCalled(t, "DeleteLayerVersion", &lambda.DeleteLayerVersionInput{LayerName: aws.String("otel"), VersionNumber: aws.Int64(1)})
+		lm.AssertNumberOfCalls(t, "DeleteLayerVersion", 2)
+	})
+
+	t.Run("shared outside the account", func(t *testing.T) {
+		lm := &layers.MockLambdaClient{}
+		lm.On("GetLayerVersionPolicy", &lambda.GetLayerVersionPolicyInput{LayerName: aws.String("common"), VersionNumber: aws.Int64(1)}).Return(
+			&lambda.GetLayerVersionPolicyOutput{Policy: aws.String(`{"Version":"2012-10-17","Statement":[{"Sid":"self","Effect":"Allow","Principal":{"AWS":"arn:aws:iam::123456789012:root"},"Action":"lambda:GetLayerVersion"},{"Sid":"partner","Effect":"Allow","Principal":{"AWS":"arn:aws:iam::210987654321:root"},"Action":"lambda:GetLayerVersion"}]}`)},
+			nil,
+		)
+		lm.On("GetLayerVersionPolicy", &lambda.GetLayerVersionPolicyInput{LayerName: aws.String("otel"), VersionNumber: aws.Int64(1)}).Return(
+			&lambda.GetLayerVersionPolicyOutput{Policy: aws.String(`{"Version":"2012-10-17","Statement":[{"Sid":"self","Effect":"Allow","Principal":{"AWS":"123456789012"},"Action":"lambda:GetLayerVersion"}]}`)},
+			nil,
+		)
+		initMockClient(lm)
+
+		out, _, err := testutil.Execute(layers.NewCmd(), "--prune", "--include-latest")
+		assert.Nil(t, err)
+		assert.Equal(t, "Skipped:\n- common:1 (shared with arn:aws:iam::210987654321:root)\nPlan:\n- otel:1 (4.0 MB)\notel:1 deleted.\nFreed 4.0 MB by deleting 1 layer versions.\n", out)
+		lm.AssertNotCalled(t, "DeleteLayerVersion", &lambda.DeleteLayerVersionInput{LayerName: aws.String("common"), VersionNumber: aws.Int64(1)})
+	})
+
+	t.Run("shared with anyone", func(t *testing.T) {
+		lm := &layers.MockLambdaClient{}
+		lm.On("GetLayerVersionPolicy", &lambda.GetLayerVersionPolicyInput{LayerName: aws.String("common"), VersionNumber: aws.Int64(1)}).Return(
+			&lambda.GetLayerVersionPolicyOutput{Policy: aws.String(`{"Version":"2012-10-17","Statement":[{"Sid":"org","Effect":"Allow","Principal":"*","Action":"lambda:GetLayerVersion","Condition":{"StringEquals":{"aws:PrincipalOrgID":"o-abcdefghij"}}}]}`)},
+			nil,
+		)
+		initMockClient(lm)
+
+		out, _, err := testutil.Execute(layers.NewCmd(), "--prune")
+		assert.Nil(t, err)
+		assert.Equal(t, "Skipped:\n- common:1 (shared with *)\nno layer version to delete.\n", out)
+		lm.AssertNumberOfCalls(t, "DeleteLayerVersion", 0)
+	})
+
+	t.Run("partial failure", func(t *testing.T) {
+		lm := &layers.MockLambdaClient{}
+		lm.On("DeleteLayerVersion", &lambda.DeleteLayerVersionInput{LayerName: aws.String("otel"), VersionNumber: aws.Int64(1)}).Return(nil, errors.New("AccessDeniedException"))
+		initMockClient(lm)
+
+		out, errOut, err := testutil.Execute(layers.NewCmd(), "--prune", "--include-latest")
+		assert.EqualError(t, err, "failed to delete 1 layer versions")
+		assert.Contains(t, out, "common:1 deleted.\nFreed 8.0 MB by deleting 1 layer versions.\n")
+		assert.Equal(t, "failed to delete otel:1: AccessDeniedException\n", errOut)
+	})
+}
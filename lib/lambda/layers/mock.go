@@ -0,0 +1,134 @@
+package layers
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockLambdaClient struct {
+	mock.Mock
+	lambdaiface.LambdaAPI
+}
+
+func (client *MockLambdaClient) ListFunctions(params *lambda.ListFunctionsInput) (*lambda.ListFunctionsOutput, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*lambda.ListFunctionsOutput), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
+func (client *MockLambdaClient) ListLayers(params *lambda.ListLayersInput) (*lambda.ListLayersOutput, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*lambda.ListLayersOutput), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
+func (client *MockLambdaClient) ListLayerVersions(params *lambda.ListLayerVersionsInput) (*lambda.ListLayerVersionsOutput, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*lambda.ListLayerVersionsOutput), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
+func (client *MockLambdaClient) GetLayerVersion(params *lambda.GetLayerVersionInput) (*lambda.GetLayerVersionOutput, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*lambda.GetLayerVersionOutput), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
+func (client *MockLambdaClient) GetLayerVersionPolicy(params *lambda.GetLayerVersionPolicyInput) (*lambda.GetLayerVersionPolicyOutput, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*lambda.GetLayerVersionPolicyOutput), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
+func (client *MockLambdaClient) DeleteLayerVersion(params *lambda.DeleteLayerVersionInput) (*lambda.DeleteLayerVersionOutput, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*lambda.DeleteLayerVersionOutput), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
+const mb = 1024 * 1024
+
+func layerVersionArn(name string, version int64) *string {
+	return aws.String(fmt.Sprintf("arn:aws:lambda:ap-northeast-1:123456789012:layer:%s:%d", name, version))
+}
+
+func SetMockDefaultBehaviour(lm *MockLambdaClient) {
+	lm.On("ListFunctions", &lambda.ListFunctionsInput{MaxItems: aws.Int64(1000)}).Return(
+		&lambda.ListFunctionsOutput{
+			Functions: []*lambda.FunctionConfiguration{
+				{FunctionName: aws.String("api"), Layers: []*lambda.Layer{{Arn: layerVersionArn("common", 3)}}},
+				{FunctionName: aws.String("worker"), Layers: []*lambda.Layer{{Arn: layerVersionArn("common", 2)}}},
+				{FunctionName: aws.String("cron"), Layers: []*lambda.Layer{{Arn: layerVersionArn("common", 3)}}},
+			},
+		},
+		nil,
+	)
+	lm.On("ListLayers", &lambda.ListLayersInput{MaxItems: aws.Int64(50)}).Return(
+		&lambda.ListLayersOutput{
+			NextMarker: aws.String("next_marker"),
+			Layers:     []*lambda.LayersListItem{{LayerName: aws.String("otel")}},
+		},
+		nil,
+	)
+	lm.On("ListLayers", &lambda.ListLayersInput{MaxItems: aws.Int64(50), Marker: aws.String("next_marker")}).Return(
+		&lambda.ListLayersOutput{
+			Layers: []*lambda.LayersListItem{{LayerName: aws.String("common")}},
+		},
+		nil,
+	)
+	lm.On("ListLayerVersions", &lambda.ListLayerVersionsInput{LayerName: aws.String("common"), MaxItems: aws.Int64(50)}).Return(
+		&lambda.ListLayerVersionsOutput{
+			LayerVersions: []*lambda.LayerVersionsListItem{
+				{Version: aws.Int64(3), LayerVersionArn: layerVersionArn("common", 3), CompatibleRuntimes: aws.StringSlice([]string{"python3.12", "python3.13"}), CreatedDate: aws.String("2024-03-01T00:00:00.000+0000")},
+				{Version: aws.Int64(2), LayerVersionArn: layerVersionArn("common", 2), CompatibleRuntimes: aws.StringSlice([]string{"python3.12"}), CreatedDate: aws.String("2024-02-01T00:00:00.000+0000")},
+				{Version: aws.Int64(1), LayerVersionArn: layerVersionArn("common", 1), CompatibleRuntimes: aws.StringSlice([]string{"python3.8"}), CreatedDate: aws.String("2024-01-01T00:00:00.000+0000")},
+			},
+		},
+		nil,
+	)
+	lm.On("ListLayerVersions", &lambda.ListLayerVersionsInput{LayerName: aws.String("otel"), MaxItems: aws.Int64(50)}).Return(
+		&lambda.ListLayerVersionsOutput{
+			LayerVersions: []*lambda.LayerVersionsListItem{
+				{Version: aws.Int64(1), LayerVersionArn: layerVersionArn("otel", 1), CreatedDate: aws.String("2024-04-01T00:00:00.000+0000")},
+			},
+		},
+		nil,
+	)
+	sizes := map[string]map[int64]int64{"common": {1: 8 * mb, 2: 9 * mb, 3: 10 * mb}, "otel": {1: 4 * mb}}
+	for name, versions := range sizes {
+		for version, size := range versions {
+			lm.On("GetLayerVersion", &lambda.GetLayerVersionInput{LayerName: aws.String(name), VersionNumber: aws.Int64(version)}).Return(
+				&lambda.GetLayerVersionOutput{Content: &lambda.LayerVersionContentOutput{CodeSize: aws.Int64(size)}},
+				nil,
+			)
+		}
+	}
+	lm.On("GetLayerVersionPolicy", mock.AnythingOfType("*lambda.GetLayerVersionPolicyInput")).Return(
+		nil,
+		awserr.New(lambda.ErrCodeResourceNotFoundException, "No policy is associated with the given resource.", nil),
+	)
+	lm.On("DeleteLayerVersion", mock.AnythingOfType("*lambda.DeleteLayerVersionInput")).Return(&lambda.DeleteLayerVersionOutput{}, nil)
+}
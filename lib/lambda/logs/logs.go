@@ -0,0 +1,18 @@
+package logs
+
+import (
+	"github.com/spf13/cobra"
+)
+
+func NewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "logs",
+		Short: "commands for log groups of Lambda functions",
+		Long:  `About usage, check each sub commands help`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.Help()
+			return nil
+		},
+	}
+	return cmd
+}
@@ -0,0 +1,96 @@
+package retention
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs/cloudwatchlogsiface"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockLambdaClient struct {
+	mock.Mock
+	lambdaiface.LambdaAPI
+}
+
+type MockLogsClient struct {
+	mock.Mock
+	cloudwatchlogsiface.CloudWatchLogsAPI
+}
+
+func (client *MockLambdaClient) ListFunctions(params *lambda.ListFunctionsInput) (*lambda.ListFunctionsOutput, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*lambda.ListFunctionsOutput), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
+func (client *MockLogsClient) DescribeLogGroups(params *cloudwatchlogs.DescribeLogGroupsInput) (*cloudwatchlogs.DescribeLogGroupsOutput, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*cloudwatchlogs.DescribeLogGroupsOutput), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
+func (client *MockLogsClient) PutRetentionPolicy(params *cloudwatchlogs.PutRetentionPolicyInput) (*cloudwatchlogs.PutRetentionPolicyOutput, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*cloudwatchlogs.PutRetentionPolicyOutput), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
+const mb = 1024 * 1024
+
+func logGroup(name string, retention *int64, storedBytes int64) *cloudwatchlogs.LogGroup {
+	return &cloudwatchlogs.LogGroup{
+		LogGroupName:    aws.String("/aws/lambda/" + name),
+		RetentionInDays: retention,
+		StoredBytes:     aws.Int64(storedBytes),
+	}
+}
+
+func SetMockDefaultBehaviour(lm *MockLambdaClient, cm *MockLogsClient) {
+	lm.On("ListFunctions", &lambda.ListFunctionsInput{MaxItems: aws.Int64(1000)}).Return(
+		&lambda.ListFunctionsOutput{
+			Functions: []*lambda.FunctionConfiguration{
+				{FunctionName: aws.String("api")},
+				{FunctionName: aws.String("worker")},
+				{FunctionName: aws.String("edge")},
+				{FunctionName: aws.String("cron")},
+			},
+		},
+		nil,
+	)
+	cm.On("DescribeLogGroups", &cloudwatchlogs.DescribeLogGroupsInput{LogGroupNamePrefix: aws.String("/aws/lambda/")}).Return(
+		&cloudwatchlogs.DescribeLogGroupsOutput{
+			LogGroups: []*cloudwatchlogs.LogGroup{
+				logGroup("api", nil, 300*mb),
+				logGroup("worker", aws.Int64(90), 20*mb),
+			},
+			NextToken: aws.String("next"),
+		},
+		nil,
+	)
+	cm.On("DescribeLogGroups", &cloudwatchlogs.DescribeLogGroupsInput{LogGroupNamePrefix: aws.String("/aws/lambda/"), NextToken: aws.String("next")}).Return(
+		&cloudwatchlogs.DescribeLogGroupsOutput{
+			LogGroups: []*cloudwatchlogs.LogGroup{
+				logGroup("us-east-1.edge", nil, 1024),
+				logGroup("cron", aws.Int64(14), 5*mb),
+				logGroup("deleted", nil, 50*mb),
+				logGroup("us-west-2.cdn", aws.Int64(7), 2048),
+			},
+		},
+		nil,
+	)
+	cm.On("PutRetentionPolicy", mock.AnythingOfType("*cloudwatchlogs.PutRetentionPolicyInput")).Return(
+		&cloudwatchlogs.PutRetentionPolicyOutput{},
+		nil,
+	)
+}
@@ -0,0 +1,290 @@
+package retention
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/Blue-Pix/abc/lib/lambda/stats"
+	"github.com/Blue-Pix/abc/lib/util"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs/cloudwatchlogsiface"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+var LambdaClient lambdaiface.LambdaAPI
+var LogsClient cloudwatchlogsiface.CloudWatchLogsAPI
+
+// retentionDays are the values accepted by PutRetentionPolicy.
+var retentionDays = []int64{1, 3, 5, 7, 14, 30, 60, 90, 120, 150, 180, 365, 400, 545, 731, 1096, 1827, 2192, 2557, 2922, 3288, 3653}
+
+// edgeReplicaPattern matches names of log groups of Lambda@Edge replicas after the prefix, like us-east-1.<function>.
+var edgeReplicaPattern = regexp.MustCompile(`^[a-z]{2}(-[a-z]+)+-[0-9]+\.(.+)$`)
+
+const (
+	issueNoRetention = "no retention"
+	issueOverPolicy  = "over policy"
+	issueOrphaned    = "orphaned"
+)
+
+var (
+	format string
+	policy int64
+	apply  bool
+	dryRun bool
+)
+
+func NewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "retention",
+		Short: "Enforce retention of log groups of Lambda functions",
+		Long: `
+[abc lambda logs retention]
+This command matches /aws/lambda/* log groups to functions, and reports log groups
+which never expire or whose retention is over --policy days, with stored bytes.
+Log groups whose function no longer exists are reported as orphaned.
+Log groups of Lambda@Edge replicas (/aws/lambda/<region>.<function>) are created in regions
+where the function runs, not where it is defined, so they are never reported as orphaned.
+
+With --apply, retention of reported log groups except orphaned ones is set to --policy days.
+Orphaned log groups are left as is, delete them if they are no longer needed.
+
+Internally it uses aws lambda api and cloudwatch logs api.
+Please configure your aws credentials with following policies.
+- lambda:ListFunctions
+- logs:DescribeLogGroups
+- logs:PutRetentionPolicy (with --apply)`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := run(cmd, args)
+			return err
+		},
+	}
+	cmd.Flags().StringVarP(&format, "format", "f", "table", "output format (table or json)")
+	cmd.Flags().Int64Var(&policy, "policy", 30, "retention in days which log groups should not exceed")
+	cmd.Flags().BoolVar(&apply, "apply", false, "(optional) set retention of reported log groups to --policy days")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "(optional) with --apply, show log groups to update without updating them")
+	return cmd
+}
+
+func run(cmd *cobra.Command, args []string) error {
+	groups, err := FetchData(cmd, args)
+	if err != nil {
+		return err
+	}
+	if apply {
+		return runApply(cmd, groups)
+	}
+	if len(groups) == 0 && format == "table" {
+		cmd.Printf("all log groups have retention of %d days or less.\n", policy)
+		return nil
+	}
+	str, err := Output(groups)
+	if err != nil {
+		return err
+	}
+	cmd.Println(str)
+	return nil
+}
+
+func runApply(cmd *cobra.Command, groups []*Group) error {
+	var targets []*Group
+	for _, g := range groups {
+		if g.Issue != issueOrphaned {
+			targets = append(targets, g)
+		}
+	}
+	if len(targets) == 0 {
+		cmd.Println("no log group to update.")
+		return nil
+	}
+	cmd.Println("Plan:")
+	for _, g := range targets {
+		cmd.Printf("- %s (%s -> %d days)\n", g.LogGroup, formatRetention(g.Retention), policy)
+	}
+	if dryRun {
+		cmd.Printf("Dry run: retention of %d log groups would be set to %d days.\n", len(targets), policy)
+		return nil
+	}
+	updated, failed := Apply(cmd, targets)
+	cmd.Printf("Set retention of %d log groups to %d days.\n", len(updated), policy)
+	if failed > 0 {
+		return fmt.Errorf("failed to put retention policy of %d log groups", failed)
+	}
+	return nil
+}
+
+// Group is a log group which is not compliant with the policy, or orphaned.
+type Group struct {
+	LogGroup string `json:"log_group"`
+	// Function is empty for orphaned log groups.
+	// For Lambda@Edge replicas, it is the replicated function, which may be defined in another region.
+	Function string `json:"function"`
+	// Retention is in days, and nil for log groups which never expire.
+	Retention   *int64 `json:"retention"`
+	StoredBytes int64  `json:"stored_bytes"`
+	Issue       string `json:"issue"`
+}
+
+// FetchData returns log groups to report, sorted by stored bytes in descending order.
+func FetchData(cmd *cobra.Command, args []string) ([]*Group, error) {
+	if !validRetention(policy) {
+		return nil, fmt.Errorf("--policy must be one of %s", strings.Trim(fmt.Sprint(retentionDays), "[]"))
+	}
+	if dryRun && !apply {
+		return nil, errors.New("--dry-run must be used with --apply")
+	}
+	initClient(cmd)
+	functions, err := stats.ListFunctions(LambdaClient)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]bool)
+	for _, f := range functions {
+		names[aws.StringValue(f.FunctionName)] = true
+	}
+	logGroups, err := stats.ListLogGroups(LogsClient)
+	if err != nil {
+		return nil, err
+	}
+	result := []*Group{}
+	for _, lg := range logGroups {
+		g := &Group{
+			LogGroup:    aws.StringValue(lg.LogGroupName),
+			Retention:   lg.RetentionInDays,
+			StoredBytes: aws.Int64Value(lg.StoredBytes),
+		}
+		function, replica := functionName(g.LogGroup)
+		if names[function] || replica {
+			g.Function = function
+		}
+		switch {
+		case g.Function == "":
+			g.Issue = issueOrphaned
+		case g.Retention == nil:
+			g.Issue = issueNoRetention
+		case *g.Retention > policy:
+			g.Issue = issueOverPolicy
+		default:
+			continue
+		}
+		result = append(result, g)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].StoredBytes != result[j].StoredBytes {
+			return result[i].StoredBytes > result[j].StoredBytes
+		}
+		return result[i].LogGroup < result[j].LogGroup
+	})
+	return result, nil
+}
+
+func initClient(cmd *cobra.Command) {
+	profile, _ := cmd.Flags().GetString("profile")
+	region, _ := cmd.Flags().GetString("region")
+	if LambdaClient == nil {
+		sess := util.CreateSession(profile, region)
+		LambdaClient = lambda.New(sess)
+	}
+	if LogsClient == nil {
+		sess := util.CreateSession(profile, region)
+		LogsClient = cloudwatchlogs.New(sess)
+	}
+}
+
+func validRetention(days int64) bool {
+	for _, d := range retentionDays {
+		if d == days {
+			return true
+		}
+	}
+	return false
+}
+
+// functionName returns the function name of the log group, and whether it is a log group of Lambda@Edge replica.
+func functionName(logGroup string) (string, bool) {
+	name := strings.TrimPrefix(logGroup, stats.LogGroupPrefix)
+	if m := edgeReplicaPattern.FindStringSubmatch(name); m != nil {
+		return m[2], true
+	}
+	return name, false
+}
+
+// Apply sets retention of the log groups to the policy, and returns updated ones and the number of failures.
+// Failure of a log group does not stop updating others.
+func Apply(cmd *cobra.Command, groups []*Group) ([]*Group, int) {
+	var updated []*Group
+	failed := 0
+	for _, g := range groups {
+		_, err := LogsClient.PutRetentionPolicy(&cloudwatchlogs.PutRetentionPolicyInput{
+			LogGroupName:    aws.String(g.LogGroup),
+			RetentionInDays: aws.Int64(policy),
+		})
+		if err != nil {
+			fmt.Fprintf(cmd.ErrOrStderr(), "failed to put retention policy of %s: %s\n", g.LogGroup, err)
+			failed++
+			continue
+		}
+		cmd.Printf("%s updated.\n", g.LogGroup)
+		updated = append(updated, g)
+	}
+	return updated, failed
+}
+
+func Output(groups []*Group) (string, error) {
+	if format == "json" {
+		jsonBytes, err := json.Marshal(groups)
+		return string(jsonBytes), err
+	} else if format == "table" {
+		return tableOutput(groups), nil
+	}
+	return "", errors.New("invalid format.")
+}
+
+func tableOutput(groups []*Group) string {
+	tableString := &strings.Builder{}
+	table := tablewriter.NewWriter(tableString)
+	table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
+	table.SetCenterSeparator("|")
+	table.SetAutoWrapText(false)
+	table.SetHeader([]string{"Log Group", "Function", "Retention", "Stored", "Issue"})
+	table.SetColumnAlignment([]int{tablewriter.ALIGN_LEFT, tablewriter.ALIGN_LEFT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_LEFT})
+	var nonCompliant, orphaned []*Group
+	for _, g := range groups {
+		function := g.Function
+		if function == "" {
+			function = "-"
+		}
+		table.Append([]string{g.LogGroup, function, formatRetention(g.Retention), util.FormatBytes(g.StoredBytes), g.Issue})
+		if g.Issue == issueOrphaned {
+			orphaned = append(orphaned, g)
+		} else {
+			nonCompliant = append(nonCompliant, g)
+		}
+	}
+	table.Render()
+	tableString.WriteString(fmt.Sprintf("\n%d log groups (%s) exceed retention of %d days, %d log groups (%s) are orphaned.\n",
+		len(nonCompliant), util.FormatBytes(totalBytes(nonCompliant)), policy, len(orphaned), util.FormatBytes(totalBytes(orphaned))))
+	return tableString.String()
+}
+
+func formatRetention(days *int64) string {
+	if days == nil {
+		return "never expire"
+	}
+	return fmt.Sprintf("%d days", *days)
+}
+
+func totalBytes(groups []*Group) int64 {
+	var n int64
+	for _, g := range groups {
+		n += g.StoredBytes
+	}
+	return n
+}
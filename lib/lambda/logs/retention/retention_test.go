@@ -0,0 +1,161 @@
+package retention_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Blue-Pix/abc/lib/lambda/logs/retention"
+	"github.com/Blue-Pix/abc/lib/util/testutil"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func initMockClient(lm *retention.MockLambdaClient, cm *retention.MockLogsClient) {
+	retention.SetMockDefaultBehaviour(lm, cm)
+	retention.LambdaClient = lm
+	retention.LogsClient = cm
+}
+
+func TestOutput(t *testing.T) {
+	t.Run("table format", func(t *testing.T) {
+		lm := &retention.MockLambdaClient{}
+		cm := &retention.MockLogsClient{}
+		initMockClient(lm, cm)
+
+		expected := "|         LOG GROUP          | FUNCTION |  RETENTION   |  STORED  |    ISSUE     |\n"
+		expected += "|----------------------------|----------|--------------|----------|--------------|\n"
+		expected += "| /aws/lambda/api            | api      | never expire | 300.0 MB | no retention |\n"
+		expected += "| /aws/lambda/deleted        | -        | never expire |  50.0 MB | orphaned     |\n"
+		expected += "| /aws/lambda/worker         | worker   |      90 days |  20.0 MB | over policy  |\n"
+		expected += "| /aws/lambda/us-east-1.edge | edge     | never expire |   1.0 KB | no retention |\n"
+		expected += "\n"
+		expected += "3 log groups (320.0 MB) exceed retention of 30 days, 1 log groups (50.0 MB) are orphaned.\n"
+		expected += "\n"
+
+		out, _, err := testutil.Execute(retention.NewCmd())
+		assert.Nil(t, err)
+		assert.Equal(t, expected, out)
+		assert.NotContains(t, out, "cdn")
+		cm.AssertNotCalled(t, "PutRetentionPolicy", mock.Anything)
+	})
+
+	t.Run("json format", func(t *testing.T) {
+		lm := &retention.MockLambdaClient{}
+		cm := &retention.MockLogsClient{}
+		initMockClient(lm, cm)
+
+		expected := "[{\"log_group\":\"/aws/lambda/api\",\"function\":\"api\",\"retention\":null,\"stored_bytes\":314572800,\"issue\":\"no retention\"},"
+		expected += "{\"log_group\":\"/aws/lambda/deleted\",\"function\":\"\",\"retention\":null,\"stored_bytes\":52428800,\"issue\":\"orphaned\"},"
+		expected += "{\"log_group\":\"/aws/lambda/worker\",\"function\":\"worker\",\"retention\":90,\"stored_bytes\":20971520,\"issue\":\"over policy\"},"
+		expected += "{\"log_group\":\"/aws/lambda/us-east-1.edge\",\"function\":\"edge\",\"retention\":null,\"stored_bytes\":1024,\"issue\":\"no retention\"}]\n"
+
+		out, _, err := testutil.Execute(retention.NewCmd(), "--format", "json")
+		assert.Nil(t, err)
+		assert.Equal(t, expected, out)
+	})
+
+	t.Run("policy", func(t *testing.T) {
+		lm := &retention.MockLambdaClient{}
+		cm := &retention.MockLogsClient{}
+		initMockClient(lm, cm)
+
+		cmd := retention.NewCmd()
+		cmd.Flags().Set("policy", "90")
+		groups, err := retention.FetchData(cmd, []string{})
+		assert.Nil(t, err)
+		assert.Equal(t, 3, len(groups))
+		for _, g := range groups {
+			assert.NotEqual(t, "/aws/lambda/worker", g.LogGroup)
+		}
+	})
+
+	t.Run("edge replica of function in another region", func(t *testing.T) {
+		lm := &retention.MockLambdaClient{}
+		cm := &retention.MockLogsClient{}
+		initMockClient(lm, cm)
+
+		cmd := retention.NewCmd()
+		cmd.Flags().Set("policy", "5")
+		groups, err := retention.FetchData(cmd, []string{})
+		assert.Nil(t, err)
+		assert.Equal(t, "/aws/lambda/us-west-2.cdn", groups[4].LogGroup)
+		assert.Equal(t, "cdn", groups[4].Function)
+		assert.Equal(t, "over policy", groups[4].Issue)
+	})
+
+	t.Run("invalid policy", func(t *testing.T) {
+		lm := &retention.MockLambdaClient{}
+		cm := &retention.MockLogsClient{}
+		initMockClient(lm, cm)
+
+		_, _, err := testutil.Execute(retention.NewCmd(), "--policy", "10")
+		assert.EqualError(t, err, "--policy must be one of 1 3 5 7 14 30 60 90 120 150 180 365 400 545 731 1096 1827 2192 2557 2922 3288 3653")
+	})
+
+	t.Run("dry run without apply", func(t *testing.T) {
+		lm := &retention.MockLambdaClient{}
+		cm := &retention.MockLogsClient{}
+		initMockClient(lm, cm)
+
+		_, _, err := testutil.Execute(retention.NewCmd(), "--dry-run")
+		assert.EqualError(t, err, "--dry-run must be used with --apply")
+	})
+}
+
+func TestApply(t *testing.T) {
+	t.Run("dry run", func(t *testing.T) {
+		lm := &retention.MockLambdaClient{}
+		cm := &retention.MockLogsClient{}
+		initMockClient(lm, cm)
+
+		expected := "Plan:\n"
+		expected += "- /aws/lambda/api (never expire -> 30 days)\n"
+		expected += "- /aws/lambda/worker (90 days -> 30 days)\n"
+		expected += "- /aws/lambda/us-east-1.edge (never expire -> 30 days)\n"
+		expected += "Dry run: retention of 3 log groups would be set to 30 days.\n"
+
+		out, _, err := testutil.Execute(retention.NewCmd(), "--apply", "--dry-run")
+		assert.Nil(t, err)
+		assert.Equal(t, expected, out)
+		cm.AssertNotCalled(t, "PutRetentionPolicy", mock.Anything)
+	})
+
+	t.Run("apply", func(t *testing.T) {
+		lm := &retention.MockLambdaClient{}
+		cm := &retention.MockLogsClient{}
+		initMockClient(lm, cm)
+
+		expected := "Plan:\n"
+		expected += "- /aws/lambda/api (never expire -> 30 days)\n"
+		expected += "- /aws/lambda/worker (90 days -> 30 days)\n"
+		expected += "- /aws/lambda/us-east-1.edge (never expire -> 30 days)\n"
+		expected += "/aws/lambda/api updated.\n"
+		expected += "/aws/lambda/worker updated.\n"
+		expected += "/aws/lambda/us-east-1.edge updated.\n"
+		expected += "Set retention of 3 log groups to 30 days.\n"
+
+		out, _, err := testutil.Execute(retention.NewCmd(), "--apply")
+		assert.Nil(t, err)
+		assert.Equal(t, expected, out)
+		cm.AssertNumberOfCalls(t, "PutRetentionPolicy", 3)
+		cm.AssertCalled(t, "PutRetentionPolicy", &cloudwatchlogs.PutRetentionPolicyInput{LogGroupName: aws.String("/aws/lambda/api"), RetentionInDays: aws.Int64(30)})
+		cm.AssertNotCalled(t, "PutRetentionPolicy", &cloudwatchlogs.PutRetentionPolicyInput{LogGroupName: aws.String("/aws/lambda/deleted"), RetentionInDays: aws.Int64(30)})
+	})
+
+	t.Run("partial failure", func(t *testing.T) {
+		lm := &retention.MockLambdaClient{}
+		cm := &retention.MockLogsClient{}
+		cm.On("PutRetentionPolicy", &cloudwatchlogs.PutRetentionPolicyInput{LogGroupName: aws.String("/aws/lambda/worker"), RetentionInDays: aws.Int64(30)}).Return(
+			nil,
+			errors.New("AccessDeniedException"),
+		)
+		initMockClient(lm, cm)
+
+		out, errOut, err := testutil.Execute(retention.NewCmd(), "--apply")
+		assert.EqualError(t, err, "failed to put retention policy of 1 log groups")
+		assert.Equal(t, "failed to put retention policy of /aws/lambda/worker: AccessDeniedException\n", errOut)
+		assert.Contains(t, out, "Set retention of 2 log groups to 30 days.\n")
+	})
+}
@@ -0,0 +1,109 @@
+package prune_versions
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockLambdaClient struct {
+	mock.Mock
+	lambdaiface.LambdaAPI
+}
+
+func (client *MockLambdaClient) ListFunctions(params *lambda.ListFunctionsInput) (*lambda.ListFunctionsOutput, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*lambda.ListFunctionsOutput), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
+func (client *MockLambdaClient) ListVersionsByFunction(params *lambda.ListVersionsByFunctionInput) (*lambda.ListVersionsByFunctionOutput, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*lambda.ListVersionsByFunctionOutput), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
+func (client *MockLambdaClient) ListAliases(params *lambda.ListAliasesInput) (*lambda.ListAliasesOutput, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*lambda.ListAliasesOutput), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
+func (client *MockLambdaClient) DeleteFunction(params *lambda.DeleteFunctionInput) (*lambda.DeleteFunctionOutput, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*lambda.DeleteFunctionOutput), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
+const mb = 1024 * 1024
+
+func SetMockDefaultBehaviour(lm *MockLambdaClient) {
+	lm.On("ListFunctions", &lambda.ListFunctionsInput{MaxItems: aws.Int64(1000)}).Return(
+		&lambda.ListFunctionsOutput{
+			Functions: []*lambda.FunctionConfiguration{
+				{FunctionName: aws.String("api")},
+				{FunctionName: aws.String("worker")},
+			},
+		},
+		nil,
+	)
+	lm.On("ListVersionsByFunction", &lambda.ListVersionsByFunctionInput{FunctionName: aws.String("api"), MaxItems: aws.Int64(50)}).Return(
+		&lambda.ListVersionsByFunctionOutput{
+			NextMarker: aws.String("next_marker"),
+			Versions: []*lambda.FunctionConfiguration{
+				{Version: aws.String("$LATEST"), CodeSize: aws.Int64(20 * mb)},
+				{Version: aws.String("1"), CodeSize: aws.Int64(10 * mb)},
+				{Version: aws.String("2"), CodeSize: aws.Int64(12 * mb)},
+				{Version: aws.String("3"), CodeSize: aws.Int64(14 * mb)},
+			},
+		},
+		nil,
+	)
+	lm.On("ListVersionsByFunction", &lambda.ListVersionsByFunctionInput{FunctionName: aws.String("api"), MaxItems: aws.Int64(50), Marker: aws.String("next_marker")}).Return(
+		&lambda.ListVersionsByFunctionOutput{
+			Versions: []*lambda.FunctionConfiguration{
+				{Version: aws.String("10"), CodeSize: aws.Int64(18 * mb)},
+				{Version: aws.String("11"), CodeSize: aws.Int64(20 * mb)},
+				{Version: aws.String("12"), CodeSize: aws.Int64(20 * mb)},
+			},
+		},
+		nil,
+	)
+	lm.On("ListAliases", &lambda.ListAliasesInput{FunctionName: aws.String("api"), MaxItems: aws.Int64(50)}).Return(
+		&lambda.ListAliasesOutput{
+			Aliases: []*lambda.AliasConfiguration{
+				{Name: aws.String("live"), FunctionVersion: aws.String("11"), RoutingConfig: &lambda.AliasRoutingConfiguration{
+					AdditionalVersionWeights: map[string]*float64{"2": aws.Float64(0.1)},
+				}},
+			},
+		},
+		nil,
+	)
+	lm.On("ListVersionsByFunction", &lambda.ListVersionsByFunctionInput{FunctionName: aws.String("worker"), MaxItems: aws.Int64(50)}).Return(
+		&lambda.ListVersionsByFunctionOutput{
+			Versions: []*lambda.FunctionConfiguration{
+				{Version: aws.String("$LATEST"), CodeSize: aws.Int64(5 * mb)},
+				{Version: aws.String("1"), CodeSize: aws.Int64(5 * mb)},
+			},
+		},
+		nil,
+	)
+	lm.On("ListAliases", &lambda.ListAliasesInput{FunctionName: aws.String("worker"), MaxItems: aws.Int64(50)}).Return(
+		&lambda.ListAliasesOutput{},
+		nil,
+	)
+	lm.On("DeleteFunction", mock.AnythingOfType("*lambda.DeleteFunctionInput")).Return(&lambda.DeleteFunctionOutput{}, nil)
+}
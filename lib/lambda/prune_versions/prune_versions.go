@@ -0,0 +1,237 @@
+package prune_versions
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/Blue-Pix/abc/lib/lambda/stats"
+	"github.com/Blue-Pix/abc/lib/util"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+	"github.com/spf13/cobra"
+)
+
+var LambdaClient lambdaiface.LambdaAPI
+
+var (
+	keep          int
+	functionNames []string
+	dryRun        bool
+)
+
+func NewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "prune-versions",
+		Short: "Delete old versions of Lambda functions",
+		Long: `
+[abc lambda prune-versions]
+This command deletes old published versions of Lambda functions,
+to free code storage.
+The latest --keep versions of each function, $LATEST,
+and versions referenced by any alias (including weighted routing) are kept.
+--keep is required, so that versions are never deleted by a default count.
+
+Internally it uses aws lambda api.
+Please configure your aws credentials with following policies.
+- lambda:ListFunctions (without --function-name)
+- lambda:ListVersionsByFunction
+- lambda:ListAliases
+- lambda:DeleteFunction`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := run(cmd, args)
+			return err
+		},
+	}
+	cmd.Flags().IntVar(&keep, "keep", 0, "number of latest versions to keep for each function")
+	cmd.Flags().StringSliceVar(&functionNames, "function-name", []string{}, "(optional) functions to prune, comma separated (all functions by default)")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "(optional) show versions to delete without deleting them")
+	cmd.MarkFlagRequired("keep")
+	return cmd
+}
+
+func run(cmd *cobra.Command, args []string) error {
+	versions, err := FetchData(cmd, args)
+	if err != nil {
+		return err
+	}
+	if len(versions) == 0 {
+		cmd.Println("no version to delete.")
+		return nil
+	}
+	cmd.Println("Plan:")
+	for _, v := range versions {
+		cmd.Printf("- %s:%s (%s)\n", v.Function, v.Version, util.FormatBytes(v.CodeSize))
+	}
+	if dryRun {
+		cmd.Printf("Dry run: %d versions (%s) would be deleted.\n", len(versions), util.FormatBytes(totalSize(versions)))
+		return nil
+	}
+	deleted, failed := Prune(cmd, versions)
+	cmd.Printf("Freed %s by deleting %d versions.\n", util.FormatBytes(totalSize(deleted)), len(deleted))
+	if failed > 0 {
+		return fmt.Errorf("failed to delete %d versions", failed)
+	}
+	return nil
+}
+
+// Version is a published version of a function to delete.
+type Version struct {
+	Function string
+	Version  string
+	CodeSize int64
+}
+
+func totalSize(versions []*Version) int64 {
+	var n int64
+	for _, v := range versions {
+		n += v.CodeSize
+	}
+	return n
+}
+
+// FetchData returns versions to delete, older ones first for each function.
+func FetchData(cmd *cobra.Command, args []string) ([]*Version, error) {
+	if keep < 0 {
+		return nil, errors.New("--keep must be 0 or more")
+	}
+	initClient(cmd)
+	names := functionNames
+	if len(names) == 0 {
+		functions, err := stats.ListFunctions(LambdaClient)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range functions {
+			names = append(names, aws.StringValue(f.FunctionName))
+		}
+	}
+	var result []*Version
+	for _, name := range names {
+		versions, err := selectVersions(name)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, versions...)
+	}
+	return result, nil
+}
+
+func initClient(cmd *cobra.Command) {
+	profile, _ := cmd.Flags().GetString("profile")
+	region, _ := cmd.Flags().GetString("region")
+	if LambdaClient == nil {
+		sess := util.CreateSession(profile, region)
+		LambdaClient = lambda.New(sess)
+	}
+}
+
+// selectVersions returns versions of the function except the latest ones and ones referenced by aliases.
+func selectVersions(name string) ([]*Version, error) {
+	versions, err := listVersions(name)
+	if err != nil {
+		return nil, err
+	}
+	referenced, err := aliasedVersions(name)
+	if err != nil {
+		return nil, err
+	}
+	var published []*Version
+	for _, v := range versions {
+		if _, err := strconv.Atoi(aws.StringValue(v.Version)); err != nil {
+			// $LATEST
+			continue
+		}
+		published = append(published, &Version{Function: name, Version: aws.StringValue(v.Version), CodeSize: aws.Int64Value(v.CodeSize)})
+	}
+	sort.Slice(published, func(i, j int) bool {
+		a, _ := strconv.Atoi(published[i].Version)
+		b, _ := strconv.Atoi(published[j].Version)
+		return a < b
+	})
+	if len(published) <= keep {
+		return nil, nil
+	}
+	var result []*Version
+	for _, v := range published[:len(published)-keep] {
+		if !referenced[v.Version] {
+			result = append(result, v)
+		}
+	}
+	return result, nil
+}
+
+func listVersions(name string) ([]*lambda.FunctionConfiguration, error) {
+	var result []*lambda.FunctionConfiguration
+	var nextMarker *string
+	for {
+		params := &lambda.ListVersionsByFunctionInput{
+			FunctionName: aws.String(name),
+			MaxItems:     aws.Int64(50),
+			Marker:       nextMarker,
+		}
+		resp, err := LambdaClient.ListVersionsByFunction(params)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, resp.Versions...)
+		if resp.NextMarker == nil {
+			break
+		}
+		nextMarker = resp.NextMarker
+	}
+	return result, nil
+}
+
+// aliasedVersions returns versions referenced by aliases, including additional versions of weighted routing.
+func aliasedVersions(name string) (map[string]bool, error) {
+	result := make(map[string]bool)
+	var nextMarker *string
+	for {
+		params := &lambda.ListAliasesInput{
+			FunctionName: aws.String(name),
+			MaxItems:     aws.Int64(50),
+			Marker:       nextMarker,
+		}
+		resp, err := LambdaClient.ListAliases(params)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range resp.Aliases {
+			result[aws.StringValue(a.FunctionVersion)] = true
+			if a.RoutingConfig != nil {
+				for v := range a.RoutingConfig.AdditionalVersionWeights {
+					result[v] = true
+				}
+			}
+		}
+		if resp.NextMarker == nil {
+			break
+		}
+		nextMarker = resp.NextMarker
+	}
+	return result, nil
+}
+
+// Prune deletes the versions, and returns deleted ones and the number of failures.
+// Failure of a version does not stop deleting others.
+func Prune(cmd *cobra.Command, versions []*Version) ([]*Version, int) {
+	var deleted []*Version
+	failed := 0
+	for _, v := range versions {
+		_, err := LambdaClient.DeleteFunction(&lambda.DeleteFunctionInput{
+			FunctionName: aws.String(v.Function),
+			Qualifier:    aws.String(v.Version),
+		})
+		if err != nil {
+			fmt.Fprintf(cmd.ErrOrStderr(), "failed to delete %s:%s: %s\n", v.Function, v.Version, err)
+			failed++
+			continue
+		}
+		cmd.Printf("%s:%s deleted.\n", v.Function, v.Version)
+		deleted = append(deleted, v)
+	}
+	return deleted, failed
+}
@@ -0,0 +1,119 @@
+package prune_versions_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Blue-Pix/abc/lib/lambda/prune_versions"
+	"github.com/Blue-Pix/abc/lib/util/testutil"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/stretchr/testify/assert"
+)
+
+const mb = 1024 * 1024
+
+func initMockClient(lm *prune_versions.MockLambdaClient) {
+	prune_versions.SetMockDefaultBehaviour(lm)
+	prune_versions.LambdaClient = lm
+}
+
+func TestFetchData(t *testing.T) {
+	t.Run("keep 3", func(t *testing.T) {
+		lm := &prune_versions.MockLambdaClient{}
+		initMockClient(lm)
+
+		cmd := prune_versions.NewCmd()
+		cmd.Flags().Set("keep", "3")
+		var args []string
+		actual, err := prune_versions.FetchData(cmd, args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []*prune_versions.Version{
+			{Function: "api", Version: "1", CodeSize: 10 * mb},
+			{Function: "api", Version: "3", CodeSize: 14 * mb},
+		}
+		assert.Equal(t, expected, actual)
+	})
+
+	t.Run("keep 0 with function name", func(t *testing.T) {
+		lm := &prune_versions.MockLambdaClient{}
+		initMockClient(lm)
+
+		cmd := prune_versions.NewCmd()
+		cmd.Flags().Set("keep", "0")
+		cmd.Flags().Set("function-name", "worker")
+		var args []string
+		actual, err := prune_versions.FetchData(cmd, args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, []*prune_versions.Version{{Function: "worker", Version: "1", CodeSize: 5 * mb}}, actual)
+		lm.AssertNotCalled(t, "ListFunctions", &lambda.ListFunctionsInput{MaxItems: aws.Int64(1000)})
+	})
+
+	t.Run("negative keep", func(t *testing.T) {
+		lm := &prune_versions.MockLambdaClient{}
+		initMockClient(lm)
+
+		cmd := prune_versions.NewCmd()
+		cmd.Flags().Set("keep", "-1")
+		var args []string
+		_, err := prune_versions.FetchData(cmd, args)
+		assert.EqualError(t, err, "--keep must be 0 or more")
+	})
+}
+
+func TestExecute(t *testing.T) {
+	t.Run("dry run", func(t *testing.T) {
+		lm := &prune_versions.MockLambdaClient{}
+		initMockClient(lm)
+
+		out, _, err := testutil.Execute(prune_versions.NewCmd(), "--keep", "3", "--dry-run")
+		assert.Nil(t, err)
+		expected := "Plan:\n- api:1 (10.0 MB)\n- api:3 (14.0 MB)\nDry run: 2 versions (24.0 MB) would be deleted.\n"
+		assert.Equal(t, expected, out)
+		lm.AssertNotCalled(t, "DeleteFunction", &lambda.DeleteFunctionInput{FunctionName: aws.String("api"), Qualifier: aws.String("1")})
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		lm := &prune_versions.MockLambdaClient{}
+		initMockClient(lm)
+
+		out, _, err := testutil.Execute(prune_versions.NewCmd(), "--keep", "2")
+		assert.Nil(t, err)
+		expected := "Plan:\n- api:1 (10.0 MB)\n- api:3 (14.0 MB)\n- api:10 (18.0 MB)\napi:1 deleted.\napi:3 deleted.\napi:10 deleted.\nFreed 42.0 MB by deleting 3 versions.\n"
+		assert.Equal(t, expected, out)
+		lm.AssertNumberOfCalls(t, "DeleteFunction", 3)
+	})
+
+	t.Run("partial failure", func(t *testing.T) {
+		lm := &prune_versions.MockLambdaClient{}
+		lm.On("DeleteFunction", &lambda.DeleteFunctionInput{FunctionName: aws.String("api"), Qualifier: aws.String("1")}).Return(nil, errors.New("ResourceConflictException"))
+		initMockClient(lm)
+
+		out, errOut, err := testutil.Execute(prune_versions.NewCmd(), "--keep", "3")
+		assert.EqualError(t, err, "failed to delete 1 versions")
+		assert.Contains(t, out, "api:3 deleted.\nFreed 14.0 MB by deleting 1 versions.\n")
+		assert.Equal(t, "failed to delete api:1: ResourceConflictException\n", errOut)
+	})
+
+	t.Run("keep is required", func(t *testing.T) {
+		lm := &prune_versions.MockLambdaClient{}
+		initMockClient(lm)
+
+		_, _, err := testutil.Execute(prune_versions.NewCmd(), "--dry-run")
+		assert.EqualError(t, err, `required flag(s) "keep" not set`)
+		lm.AssertNotCalled(t, "DeleteFunction", &lambda.DeleteFunctionInput{FunctionName: aws.String("api"), Qualifier: aws.String("1")})
+	})
+
+	t.Run("no version to delete", func(t *testing.T) {
+		lm := &prune_versions.MockLambdaClient{}
+		initMockClient(lm)
+
+		out, _, err := testutil.Execute(prune_versions.NewCmd(), "--keep", "10")
+		assert.Nil(t, err)
+		assert.Equal(t, "no version to delete.\n", out)
+	})
+}
@@ -0,0 +1,71 @@
+package rightsize
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs/cloudwatchlogsiface"
+)
+
+// maxLogGroups is the limit of log groups in a Logs Insights query.
+const maxLogGroups = 50
+
+// PollInterval is the interval to check the status of Logs Insights queries. It can be replaced in tests.
+var PollInterval = time.Second
+
+// runQuery runs the Logs Insights query over log groups in batches of 50,
+// and returns rows of results as maps of field to value.
+func runQuery(client cloudwatchlogsiface.CloudWatchLogsAPI, query string, logGroups []string, start time.Time, end time.Time) ([]map[string]string, error) {
+	var result []map[string]string
+	for offset := 0; offset < len(logGroups); offset += maxLogGroups {
+		batch := logGroups[offset:]
+		if len(batch) > maxLogGroups {
+			batch = batch[:maxLogGroups]
+		}
+		resp, err := client.StartQuery(&cloudwatchlogs.StartQueryInput{
+			LogGroupNames: aws.StringSlice(batch),
+			QueryString:   aws.String(query),
+			StartTime:     aws.Int64(start.Unix()),
+			EndTime:       aws.Int64(end.Unix()),
+			Limit:         aws.Int64(10000),
+		})
+		if err != nil {
+			return nil, err
+		}
+		rows, err := waitQueryResults(client, resp.QueryId)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, rows...)
+	}
+	return result, nil
+}
+
+func waitQueryResults(client cloudwatchlogsiface.CloudWatchLogsAPI, queryId *string) ([]map[string]string, error) {
+	for {
+		time.Sleep(PollInterval)
+		resp, err := client.GetQueryResults(&cloudwatchlogs.GetQueryResultsInput{QueryId: queryId})
+		if err != nil {
+			return nil, err
+		}
+		switch aws.StringValue(resp.Status) {
+		case cloudwatchlogs.QueryStatusScheduled, cloudwatchlogs.QueryStatusRunning:
+			continue
+		case cloudwatchlogs.QueryStatusComplete:
+			var rows []map[string]string
+			for _, fields := range resp.Results {
+				row := make(map[string]string)
+				for _, f := range fields {
+					row[aws.StringValue(f.Field)] = aws.StringValue(f.Value)
+				}
+				rows = append(rows, row)
+			}
+			return rows, nil
+		default:
+			return nil, fmt.Errorf("logs insights query %s: %s", aws.StringValue(queryId), strings.ToLower(aws.StringValue(resp.Status)))
+		}
+	}
+}
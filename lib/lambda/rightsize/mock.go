@@ -0,0 +1,123 @@
+package rightsize
+
+import (
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs/cloudwatchlogsiface"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockLambdaClient struct {
+	mock.Mock
+	lambdaiface.LambdaAPI
+}
+
+type MockLogsClient struct {
+	mock.Mock
+	cloudwatchlogsiface.CloudWatchLogsAPI
+}
+
+func (client *MockLambdaClient) ListFunctions(params *lambda.ListFunctionsInput) (*lambda.ListFunctionsOutput, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*lambda.ListFunctionsOutput), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
+func (client *MockLogsClient) DescribeLogGroups(params *cloudwatchlogs.DescribeLogGroupsInput) (*cloudwatchlogs.DescribeLogGroupsOutput, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*cloudwatchlogs.DescribeLogGroupsOutput), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
+func (client *MockLogsClient) StartQuery(params *cloudwatchlogs.StartQueryInput) (*cloudwatchlogs.StartQueryOutput, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*cloudwatchlogs.StartQueryOutput), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
+func (client *MockLogsClient) GetQueryResults(params *cloudwatchlogs.GetQueryResultsInput) (*cloudwatchlogs.GetQueryResultsOutput, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*cloudwatchlogs.GetQueryResultsOutput), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
+// mb is the unit of memory in REPORT lines, which Logs Insights multiplies by 1,000,000 into bytes.
+const mb = 1000 * 1000
+
+func reportRow(logGroup string, invocations string, maxMemoryUsed string, p99Duration string, maxInitDuration string) []*cloudwatchlogs.ResultField {
+	return []*cloudwatchlogs.ResultField{
+		{Field: aws.String("@log"), Value: aws.String("123456789012:" + logGroup)},
+		{Field: aws.String("invocations"), Value: aws.String(invocations)},
+		{Field: aws.String("maxMemoryUsed"), Value: aws.String(maxMemoryUsed)},
+		{Field: aws.String("p99Duration"), Value: aws.String(p99Duration)},
+		{Field: aws.String("maxInitDuration"), Value: aws.String(maxInitDuration)},
+	}
+}
+
+func SetMockDefaultBehaviour(lm *MockLambdaClient, cm *MockLogsClient) {
+	lm.On("ListFunctions", &lambda.ListFunctionsInput{MaxItems: aws.Int64(1000)}).Return(
+		&lambda.ListFunctionsOutput{
+			Functions: []*lambda.FunctionConfiguration{
+				{FunctionName: aws.String("worker"), MemorySize: aws.Int64(512), Timeout: aws.Int64(900)},
+				{FunctionName: aws.String("api"), MemorySize: aws.Int64(1024), Timeout: aws.Int64(3)},
+				{FunctionName: aws.String("batch"), MemorySize: aws.Int64(256), Timeout: aws.Int64(60)},
+				{FunctionName: aws.String("new"), MemorySize: aws.Int64(128), Timeout: aws.Int64(3)},
+			},
+		},
+		nil,
+	)
+	cm.On("DescribeLogGroups", &cloudwatchlogs.DescribeLogGroupsInput{LogGroupNamePrefix: aws.String("/aws/lambda/")}).Return(
+		&cloudwatchlogs.DescribeLogGroupsOutput{
+			NextToken: aws.String("next_token"),
+			LogGroups: []*cloudwatchlogs.LogGroup{
+				{LogGroupName: aws.String("/aws/lambda/api")},
+				{LogGroupName: aws.String("/aws/lambda/batch")},
+			},
+		},
+		nil,
+	)
+	cm.On("DescribeLogGroups", &cloudwatchlogs.DescribeLogGroupsInput{LogGroupNamePrefix: aws.String("/aws/lambda/"), NextToken: aws.String("next_token")}).Return(
+		&cloudwatchlogs.DescribeLogGroupsOutput{
+			LogGroups: []*cloudwatchlogs.LogGroup{
+				{LogGroupName: aws.String("/aws/lambda/deleted")},
+				{LogGroupName: aws.String("/aws/lambda/worker")},
+			},
+		},
+		nil,
+	)
+	cm.On("StartQuery", mock.AnythingOfType("*cloudwatchlogs.StartQueryInput")).Return(
+		&cloudwatchlogs.StartQueryOutput{QueryId: aws.String("query-1")},
+		nil,
+	)
+	cm.On("GetQueryResults", &cloudwatchlogs.GetQueryResultsInput{QueryId: aws.String("query-1")}).Return(
+		&cloudwatchlogs.GetQueryResultsOutput{Status: aws.String(cloudwatchlogs.QueryStatusRunning)},
+		nil,
+	).Once()
+	cm.On("GetQueryResults", &cloudwatchlogs.GetQueryResultsInput{QueryId: aws.String("query-1")}).Return(
+		&cloudwatchlogs.GetQueryResultsOutput{
+			Status: aws.String(cloudwatchlogs.QueryStatusComplete),
+			Results: [][]*cloudwatchlogs.ResultField{
+				reportRow("/aws/lambda/worker", "50", strconv.Itoa(500*mb), "120000", ""),
+				reportRow("/aws/lambda/api", "1000", strconv.Itoa(120*mb), "2800.5", "450.2"),
+				reportRow("/aws/lambda/batch", "10", strconv.Itoa(200*mb), "15000", "1000"),
+			},
+		},
+		nil,
+	)
+}
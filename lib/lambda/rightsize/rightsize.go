@@ -0,0 +1,272 @@
+package rightsize
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Blue-Pix/abc/lib/lambda/stats"
+	"github.com/Blue-Pix/abc/lib/util"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs/cloudwatchlogsiface"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+var LambdaClient lambdaiface.LambdaAPI
+var LogsClient cloudwatchlogsiface.CloudWatchLogsAPI
+
+// reportQuery aggregates REPORT lines of each log group. Memory fields are in bytes.
+const reportQuery = `filter @type = "REPORT"
+| stats count(*) as invocations, max(@maxMemoryUsed) as maxMemoryUsed, pct(@duration, 99) as p99Duration, max(@initDuration) as maxInitDuration by @log`
+
+const (
+	minMemory  = 128
+	maxMemory  = 10240
+	minTimeout = 3
+	maxTimeout = 900
+	// bytesPerMB converts memory fields of Logs Insights to MB of REPORT lines, which is 1,000,000 bytes.
+	bytesPerMB = 1000 * 1000
+	// memoryStep is the unit to round up recommended memory in MB.
+	memoryStep = 64
+	// memoryHeadroom is applied to max memory used for recommended memory.
+	memoryHeadroom = 1.2
+	// timeoutHeadroom is applied to p99 duration with init duration for recommended timeout.
+	timeoutHeadroom = 3
+	// riskPercent is the usage of memory or timeout regarded as the risk of OOM or timeout.
+	riskPercent = 90
+)
+
+const (
+	riskOOM     = "OOM"
+	riskTimeout = "timeout"
+)
+
+var (
+	format string
+	days   int
+)
+
+func NewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rightsize",
+		Short: "Recommend memory and timeout of Lambda functions",
+		Long: `
+[abc lambda rightsize]
+This command runs CloudWatch Logs Insights queries over REPORT lines in /aws/lambda/* log groups
+of the last --days days, to collect max memory used, p99 duration and max init duration of each function.
+Then it recommends memory and timeout compared with the configuration,
+and flags functions at risk of OOM or timeout, whose usage is over 90% of the configuration.
+
+Recommended memory is max memory used with 20% headroom, rounded up to 64 MB.
+Note that CPU is allocated in proportion to memory, so reducing memory may make CPU bound functions slower.
+Recommended timeout is 3 times of p99 duration with max init duration.
+Logs Insights is charged by the size of logs scanned.
+
+Internally it uses aws lambda api and cloudwatch logs api.
+Please configure your aws credentials with following policies.
+- lambda:ListFunctions
+- logs:DescribeLogGroups
+- logs:StartQuery
+- logs:GetQueryResults`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := run(cmd, args)
+			return err
+		},
+	}
+	cmd.Flags().StringVarP(&format, "format", "f", "table", "output format (table or json)")
+	cmd.Flags().IntVar(&days, "days", 14, "number of days of logs to query")
+	return cmd
+}
+
+func run(cmd *cobra.Command, args []string) error {
+	recommendations, err := FetchData(cmd, args)
+	if err != nil {
+		return err
+	}
+	if len(recommendations) == 0 && format == "table" {
+		cmd.Printf("no invocation found in the last %d days.\n", days)
+		return nil
+	}
+	str, err := Output(recommendations)
+	if err != nil {
+		return err
+	}
+	cmd.Println(str)
+	return nil
+}
+
+// Recommendation is memory and timeout recommended for a function.
+type Recommendation struct {
+	Function    string `json:"function"`
+	Invocations int64  `json:"invocations"`
+	// Memory and MaxMemoryUsed are in MB.
+	Memory            int64 `json:"memory"`
+	MaxMemoryUsed     int64 `json:"max_memory_used"`
+	RecommendedMemory int64 `json:"recommended_memory"`
+	// Durations are in milliseconds, and timeouts are in seconds.
+	P99Duration        float64  `json:"p99_duration"`
+	MaxInitDuration    float64  `json:"max_init_duration"`
+	Timeout            int64    `json:"timeout"`
+	RecommendedTimeout int64    `json:"recommended_timeout"`
+	Risks              []string `json:"risks"`
+}
+
+// FetchData returns recommendations of functions which have REPORT lines in the period, sorted by function name.
+func FetchData(cmd *cobra.Command, args []string) ([]*Recommendation, error) {
+	if days < 1 {
+		return nil, errors.New("--days must be 1 or more")
+	}
+	initClient(cmd)
+	functions, err := stats.ListFunctions(LambdaClient)
+	if err != nil {
+		return nil, err
+	}
+	logGroups, err := stats.ListLogGroups(LogsClient)
+	if err != nil {
+		return nil, err
+	}
+	exists := make(map[string]bool)
+	for _, g := range logGroups {
+		exists[aws.StringValue(g.LogGroupName)] = true
+	}
+	// log groups not created yet cannot be queried.
+	var names []string
+	for _, f := range functions {
+		if name := stats.LogGroupPrefix + aws.StringValue(f.FunctionName); exists[name] {
+			names = append(names, name)
+		}
+	}
+	end := stats.Now()
+	start := end.Add(-time.Duration(days) * 24 * time.Hour)
+	rows, err := runQuery(LogsClient, reportQuery, names, start, end)
+	if err != nil {
+		return nil, err
+	}
+	reports := make(map[string]map[string]string)
+	for _, row := range rows {
+		// @log is <account>:<log group>
+		logGroup := row["@log"]
+		if i := strings.Index(logGroup, ":"); i >= 0 {
+			logGroup = logGroup[i+1:]
+		}
+		reports[logGroup] = row
+	}
+	result := []*Recommendation{}
+	for _, f := range functions {
+		row, ok := reports[stats.LogGroupPrefix+aws.StringValue(f.FunctionName)]
+		if !ok {
+			continue
+		}
+		result = append(result, recommend(f, row))
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].Function < result[j].Function })
+	return result, nil
+}
+
+func initClient(cmd *cobra.Command) {
+	profile, _ := cmd.Flags().GetString("profile")
+	region, _ := cmd.Flags().GetString("region")
+	if LambdaClient == nil {
+		sess := util.CreateSession(profile, region)
+		LambdaClient = lambda.New(sess)
+	}
+	if LogsClient == nil {
+		sess := util.CreateSession(profile, region)
+		LogsClient = cloudwatchlogs.New(sess)
+	}
+}
+
+func recommend(f *lambda.FunctionConfiguration, row map[string]string) *Recommendation {
+	r := &Recommendation{
+		Function: aws.StringValue(f.FunctionName),
+		Memory:   aws.Int64Value(f.MemorySize),
+		Timeout:  aws.Int64Value(f.Timeout),
+		Risks:    []string{},
+	}
+	r.Invocations = int64(parseFloat(row["invocations"]))
+	r.MaxMemoryUsed = int64(math.Ceil(parseFloat(row["maxMemoryUsed"]) / bytesPerMB))
+	r.P99Duration = parseFloat(row["p99Duration"])
+	r.MaxInitDuration = parseFloat(row["maxInitDuration"])
+
+	r.RecommendedMemory = int64(math.Ceil(float64(r.MaxMemoryUsed)*memoryHeadroom/memoryStep)) * memoryStep
+	r.RecommendedMemory = clamp(r.RecommendedMemory, minMemory, maxMemory)
+	duration := r.P99Duration + r.MaxInitDuration
+	r.RecommendedTimeout = clamp(int64(math.Ceil(duration*timeoutHeadroom/1000)), minTimeout, maxTimeout)
+
+	if r.Memory > 0 && float64(r.MaxMemoryUsed) >= float64(r.Memory)*riskPercent/100 {
+		r.Risks = append(r.Risks, riskOOM)
+	}
+	if r.Timeout > 0 && duration >= float64(r.Timeout)*1000*riskPercent/100 {
+		r.Risks = append(r.Risks, riskTimeout)
+	}
+	return r
+}
+
+func parseFloat(s string) float64 {
+	v, _ := strconv.ParseFloat(s, 64)
+	return v
+}
+
+func clamp(v int64, min int64, max int64) int64 {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
+
+func Output(recommendations []*Recommendation) (string, error) {
+	if format == "json" {
+		jsonBytes, err := json.Marshal(recommendations)
+		return string(jsonBytes), err
+	} else if format == "table" {
+		return tableOutput(recommendations), nil
+	}
+	return "", errors.New("invalid format.")
+}
+
+func tableOutput(recommendations []*Recommendation) string {
+	tableString := &strings.Builder{}
+	table := tablewriter.NewWriter(tableString)
+	table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
+	table.SetCenterSeparator("|")
+	table.SetHeader([]string{"Function", "Invocations", "Max Memory Used", "Memory", "P99 Duration", "Max Init", "Timeout", "Risk"})
+	table.SetColumnAlignment([]int{tablewriter.ALIGN_LEFT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_LEFT})
+	for _, r := range recommendations {
+		risk := "-"
+		if len(r.Risks) > 0 {
+			risk = strings.Join(r.Risks, ", ")
+		}
+		table.Append([]string{
+			r.Function,
+			strconv.FormatInt(r.Invocations, 10),
+			fmt.Sprintf("%d MB", r.MaxMemoryUsed),
+			change(r.Memory, r.RecommendedMemory, " MB"),
+			fmt.Sprintf("%.0f ms", r.P99Duration),
+			fmt.Sprintf("%.0f ms", r.MaxInitDuration),
+			change(r.Timeout, r.RecommendedTimeout, " s"),
+			risk,
+		})
+	}
+	table.Render()
+	return tableString.String()
+}
+
+// change returns "current -> recommended", or only current if they are the same.
+func change(current int64, recommended int64, unit string) string {
+	if current == recommended {
+		return fmt.Sprintf("%d%s", current, unit)
+	}
+	return fmt.Sprintf("%d -> %d%s", current, recommended, unit)
+}
@@ -0,0 +1,80 @@
+package rightsize_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Blue-Pix/abc/lib/lambda/rightsize"
+	"github.com/Blue-Pix/abc/lib/lambda/stats"
+	"github.com/Blue-Pix/abc/lib/util/testutil"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func initMockClient(lm *rightsize.MockLambdaClient, cm *rightsize.MockLogsClient) {
+	rightsize.SetMockDefaultBehaviour(lm, cm)
+	rightsize.LambdaClient = lm
+	rightsize.LogsClient = cm
+	rightsize.PollInterval = 0
+}
+
+func TestOutput(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	stats.Now = func() time.Time { return now }
+	defer func() { stats.Now = time.Now }()
+
+	t.Run("table format", func(t *testing.T) {
+		lm := &rightsize.MockLambdaClient{}
+		cm := &rightsize.MockLogsClient{}
+		initMockClient(lm, cm)
+
+		expected := "| FUNCTION | INVOCATIONS | MAX MEMORY USED |     MEMORY     | P99 DURATION | MAX INIT |   TIMEOUT    |  RISK   |\n"
+		expected += "|----------|-------------|-----------------|----------------|--------------|----------|--------------|---------|\n"
+		expected += "| api      |        1000 |          120 MB | 1024 -> 192 MB |      2800 ms |   450 ms |    3 -> 10 s | timeout |\n"
+		expected += "| batch    |          10 |          200 MB |         256 MB |     15000 ms |  1000 ms |   60 -> 48 s | -       |\n"
+		expected += "| worker   |          50 |          500 MB |  512 -> 640 MB |    120000 ms |     0 ms | 900 -> 360 s | OOM     |\n"
+		expected += "\n"
+
+		out, _, err := testutil.Execute(rightsize.NewCmd())
+		assert.Nil(t, err)
+		assert.Equal(t, expected, out)
+		cm.AssertCalled(t, "StartQuery", mock.MatchedBy(func(params *cloudwatchlogs.StartQueryInput) bool {
+			return strings.HasPrefix(aws.StringValue(params.QueryString), `filter @type = "REPORT"`) &&
+				assert.ObjectsAreEqual([]string{"/aws/lambda/worker", "/aws/lambda/api", "/aws/lambda/batch"}, aws.StringValueSlice(params.LogGroupNames)) &&
+				aws.Int64Value(params.StartTime) == now.Add(-14*24*time.Hour).Unix() &&
+				aws.Int64Value(params.EndTime) == now.Unix()
+		}))
+		cm.AssertNumberOfCalls(t, "GetQueryResults", 2)
+	})
+
+	t.Run("json format", func(t *testing.T) {
+		lm := &rightsize.MockLambdaClient{}
+		cm := &rightsize.MockLogsClient{}
+		initMockClient(lm, cm)
+
+		expected := "[{\"function\":\"api\",\"invocations\":1000,\"memory\":1024,\"max_memory_used\":120,\"recommended_memory\":192,\"p99_duration\":2800.5,\"max_init_duration\":450.2,\"timeout\":3,\"recommended_timeout\":10,\"risks\":[\"timeout\"]},"
+		expected += "{\"function\":\"batch\",\"invocations\":10,\"memory\":256,\"max_memory_used\":200,\"recommended_memory\":256,\"p99_duration\":15000,\"max_init_duration\":1000,\"timeout\":60,\"recommended_timeout\":48,\"risks\":[]},"
+		expected += "{\"function\":\"worker\",\"invocations\":50,\"memory\":512,\"max_memory_used\":500,\"recommended_memory\":640,\"p99_duration\":120000,\"max_init_duration\":0,\"timeout\":900,\"recommended_timeout\":360,\"risks\":[\"OOM\"]}]\n"
+
+		out, _, err := testutil.Execute(rightsize.NewCmd(), "--format", "json")
+		assert.Nil(t, err)
+		assert.Equal(t, expected, out)
+	})
+
+	t.Run("failed query", func(t *testing.T) {
+		lm := &rightsize.MockLambdaClient{}
+		cm := &rightsize.MockLogsClient{}
+		cm.On("GetQueryResults", &cloudwatchlogs.GetQueryResultsInput{QueryId: aws.String("query-1")}).Return(
+			&cloudwatchlogs.GetQueryResultsOutput{Status: aws.String(cloudwatchlogs.QueryStatusFailed)},
+			nil,
+		)
+		initMockClient(lm, cm)
+
+		_, _, err := testutil.Execute(rightsize.NewCmd())
+		assert.Equal(t, errors.New("logs insights query query-1: failed"), err)
+	})
+}
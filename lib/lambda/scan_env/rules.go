@@ -0,0 +1,158 @@
+package scan_env
+
+import (
+	"math"
+	"regexp"
+	"strings"
+)
+
+const (
+	severityHigh   = "high"
+	severityMedium = "medium"
+	severityLow    = "low"
+)
+
+const (
+	// minEntropyLength is the minimum length of values to check entropy.
+	minEntropyLength = 20
+	// minSecretNameLength is the minimum length of values of variables named like secrets.
+	minSecretNameLength = 8
+	// secretNameEntropy is the threshold of entropy for variables named like secrets.
+	secretNameEntropy = 3.0
+)
+
+// Rule is a pattern of secrets in values of environment variables.
+type Rule struct {
+	Id          string
+	Description string
+	Severity    string
+	// Name restricts the rule to variables with matching names, if set.
+	Name  *regexp.Regexp
+	Value *regexp.Regexp
+}
+
+var rules = []*Rule{
+	{
+		Id:          "aws-access-key-id",
+		Description: "AWS access key ID",
+		Severity:    severityHigh,
+		Value:       regexp.MustCompile(`\b(?:AKIA|ASIA|ABIA|ACCA|A3T[A-Z0-9])[A-Z0-9]{16}\b`),
+	},
+	{
+		Id:          "aws-secret-access-key",
+		Description: "AWS secret access key",
+		Severity:    severityHigh,
+		Name:        regexp.MustCompile(`(?i)(aws|secret_?access)`),
+		Value:       regexp.MustCompile(`^[A-Za-z0-9/+]{40}$`),
+	},
+	{
+		Id:          "private-key",
+		Description: "Private key",
+		Severity:    severityHigh,
+		Value:       regexp.MustCompile(`-----BEGIN (?:[A-Z]+ )*PRIVATE KEY`),
+	},
+	{
+		Id:          "github-token",
+		Description: "GitHub token",
+		Severity:    severityHigh,
+		Value:       regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`),
+	},
+	{
+		Id:          "slack-token",
+		Description: "Slack token",
+		Severity:    severityHigh,
+		Value:       regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}`),
+	},
+	{
+		Id:          "stripe-secret-key",
+		Description: "Stripe secret key",
+		Severity:    severityHigh,
+		Value:       regexp.MustCompile(`\b[rs]k_live_[A-Za-z0-9]{16,}`),
+	},
+	{
+		Id:          "database-url-password",
+		Description: "Database URL with password",
+		Severity:    severityHigh,
+		Value:       regexp.MustCompile(`(?i)\b(?:postgres(?:ql)?|mysql|mariadb|mongodb(?:\+srv)?|redis|rediss|amqps?|mssql|sqlserver|oracle)://[^:/@\s]+:[^@/\s]+@`),
+	},
+	{
+		Id:          "high-entropy-value",
+		Description: "High entropy value",
+		Severity:    severityMedium,
+	},
+	{
+		Id:          "secret-named-variable",
+		Description: "Plaintext value of variable named like a secret",
+		Severity:    severityLow,
+		Name:        regexp.MustCompile(`(?i)(secret|passw(or)?d|pwd|token|api_?key|private_?key|credential|auth)`),
+	},
+}
+
+// references are values pointing to secrets stored elsewhere, which are not secrets themselves.
+var references = regexp.MustCompile(`^(arn:aws[a-z-]*:|\{\{resolve:|/aws/reference/secretsmanager/|ssm:)`)
+
+// Scan returns the rule matched with the variable, or nil.
+// Pattern rules take precedence over entropy checks, which are done only when no pattern matches.
+func Scan(name string, value string, minEntropy float64) *Rule {
+	if value == "" || references.MatchString(value) {
+		return nil
+	}
+	for _, r := range rules {
+		if r.Value == nil {
+			continue
+		}
+		if r.Name != nil && !r.Name.MatchString(name) {
+			continue
+		}
+		if r.Value.MatchString(value) {
+			return r
+		}
+	}
+	// URLs and paths have high entropy without secrets.
+	if strings.Contains(value, "://") || strings.HasPrefix(value, "/") {
+		return nil
+	}
+	entropy := Entropy(value)
+	if len(value) >= minEntropyLength && entropy >= minEntropy {
+		return lookupRule("high-entropy-value")
+	}
+	if len(value) >= minSecretNameLength && entropy >= secretNameEntropy && lookupRule("secret-named-variable").Name.MatchString(name) {
+		return lookupRule("secret-named-variable")
+	}
+	return nil
+}
+
+func lookupRule(id string) *Rule {
+	for _, r := range rules {
+		if r.Id == id {
+			return r
+		}
+	}
+	return nil
+}
+
+// Entropy returns Shannon entropy of the string in bits per character.
+func Entropy(s string) float64 {
+	counts := make(map[rune]int)
+	n := 0
+	for _, c := range s {
+		counts[c]++
+		n++
+	}
+	var result float64
+	for _, count := range counts {
+		p := float64(count) / float64(n)
+		result -= p * math.Log2(p)
+	}
+	return result
+}
+
+// Mask hides the value except the first 4 characters of long values.
+// Characters are counted in runes, not to cut a multibyte character.
+func Mask(value string) string {
+	runes := []rune(value)
+	if len(runes) <= 12 {
+		return "****"
+	}
+	return string(runes[:4]) + "****"
+}
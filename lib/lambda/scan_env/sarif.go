@@ -0,0 +1,98 @@
+package scan_env
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+const (
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifVersion = "2.1.0"
+	toolName     = "abc lambda scan-env"
+	toolUri      = "https://github.com/Blue-Pix/abc"
+)
+
+// sarifLevels are SARIF levels of severities.
+var sarifLevels = map[string]string{
+	severityHigh:   "error",
+	severityMedium: "warning",
+	severityLow:    "note",
+}
+
+type sarifLog struct {
+	Schema  string      `json:"$schema"`
+	Version string      `json:"version"`
+	Runs    []*sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool struct {
+		Driver struct {
+			Name           string       `json:"name"`
+			InformationUri string       `json:"informationUri"`
+			Rules          []*sarifRule `json:"rules"`
+		} `json:"driver"`
+	} `json:"tool"`
+	Results []*sarifResult `json:"results"`
+}
+
+type sarifRule struct {
+	Id               string `json:"id"`
+	ShortDescription struct {
+		Text string `json:"text"`
+	} `json:"shortDescription"`
+	DefaultConfiguration struct {
+		Level string `json:"level"`
+	} `json:"defaultConfiguration"`
+}
+
+type sarifResult struct {
+	RuleId  string `json:"ruleId"`
+	Level   string `json:"level"`
+	Message struct {
+		Text string `json:"text"`
+	} `json:"message"`
+	Locations           []*sarifLocation  `json:"locations"`
+	PartialFingerprints map[string]string `json:"partialFingerprints"`
+}
+
+// sarifLocation points the variable with a logical location, since it is not in any file.
+type sarifLocation struct {
+	LogicalLocations []*sarifLogicalLocation `json:"logicalLocations"`
+}
+
+type sarifLogicalLocation struct {
+	Name               string `json:"name"`
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
+
+func sarifOutput(findings []*Finding) (string, error) {
+	run := &sarifRun{Results: []*sarifResult{}}
+	run.Tool.Driver.Name = toolName
+	run.Tool.Driver.InformationUri = toolUri
+	for _, r := range rules {
+		rule := &sarifRule{Id: r.Id}
+		rule.ShortDescription.Text = r.Description
+		rule.DefaultConfiguration.Level = sarifLevels[r.Severity]
+		run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, rule)
+	}
+	for _, f := range findings {
+		result := &sarifResult{
+			RuleId: f.Rule,
+			Level:  sarifLevels[f.Severity],
+			Locations: []*sarifLocation{{
+				LogicalLocations: []*sarifLogicalLocation{{
+					Name:               f.Variable,
+					FullyQualifiedName: f.Function + "/" + f.Variable,
+					Kind:               "variable",
+				}},
+			}},
+			PartialFingerprints: map[string]string{"functionVariable": f.Function + "/" + f.Variable},
+		}
+		result.Message.Text = fmt.Sprintf("%s found in environment variable %s of %s (value: %s)", f.Description, f.Variable, f.Function, f.Value)
+		run.Results = append(run.Results, result)
+	}
+	jsonBytes, err := json.Marshal(&sarifLog{Schema: sarifSchema, Version: sarifVersion, Runs: []*sarifRun{run}})
+	return string(jsonBytes), err
+}
@@ -0,0 +1,207 @@
+package scan_env
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/Blue-Pix/abc/lib/lambda/stats"
+	"github.com/Blue-Pix/abc/lib/util"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+var LambdaClient lambdaiface.LambdaAPI
+
+var (
+	format        string
+	allowlistFile string
+	minEntropy    float64
+)
+
+func NewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "scan-env",
+		Short: "Scan environment variables of Lambda functions for secrets",
+		Long: `
+[abc lambda scan-env]
+This command scans environment variables of all functions for plaintext secrets,
+like AWS access keys, tokens, private keys and database URLs with passwords.
+Values which match no pattern are also checked with Shannon entropy.
+Values are masked in the output.
+
+Findings can be ignored with --allowlist file, which has a line of <function>:<variable> for each finding.
+Each of them can be a glob pattern like *:SENTRY_DSN, and lines starting with # are comments.
+With --format sarif, findings are output in SARIF 2.1.0 to upload to code scanning tools.
+
+Internally it uses aws lambda api.
+Please configure your aws credentials with following policies.
+- lambda:ListFunctions`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := run(cmd, args)
+			return err
+		},
+	}
+	cmd.Flags().StringVarP(&format, "format", "f", "table", "output format (table, json or sarif)")
+	cmd.Flags().StringVar(&allowlistFile, "allowlist", "", "(optional) file of <function>:<variable> to ignore")
+	cmd.Flags().Float64Var(&minEntropy, "min-entropy", 4.5, "(optional) entropy in bits per character to flag values without patterns")
+	return cmd
+}
+
+func run(cmd *cobra.Command, args []string) error {
+	findings, err := FetchData(cmd, args)
+	if err != nil {
+		return err
+	}
+	if len(findings) == 0 && format == "table" {
+		cmd.Println("no secret found.")
+		return nil
+	}
+	str, err := Output(findings)
+	if err != nil {
+		return err
+	}
+	cmd.Println(str)
+	return nil
+}
+
+// Finding is an environment variable which may contain a secret.
+type Finding struct {
+	Function    string `json:"function"`
+	Variable    string `json:"variable"`
+	Rule        string `json:"rule"`
+	Description string `json:"description"`
+	Severity    string `json:"severity"`
+	// Value is masked.
+	Value string `json:"value"`
+}
+
+// FetchData returns findings sorted by function and variable.
+func FetchData(cmd *cobra.Command, args []string) ([]*Finding, error) {
+	var allowlist []string
+	if allowlistFile != "" {
+		var err error
+		if allowlist, err = LoadAllowlist(allowlistFile); err != nil {
+			return nil, err
+		}
+	}
+	initClient(cmd)
+	functions, err := stats.ListFunctions(LambdaClient)
+	if err != nil {
+		return nil, err
+	}
+	result := []*Finding{}
+	for _, f := range functions {
+		if f.Environment == nil {
+			continue
+		}
+		name := aws.StringValue(f.FunctionName)
+		for variable, value := range aws.StringValueMap(f.Environment.Variables) {
+			if allowed(allowlist, name, variable) {
+				continue
+			}
+			rule := Scan(variable, value, minEntropy)
+			if rule == nil {
+				continue
+			}
+			result = append(result, &Finding{
+				Function:    name,
+				Variable:    variable,
+				Rule:        rule.Id,
+				Description: rule.Description,
+				Severity:    rule.Severity,
+				Value:       Mask(value),
+			})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Function != result[j].Function {
+			return result[i].Function < result[j].Function
+		}
+		return result[i].Variable < result[j].Variable
+	})
+	return result, nil
+}
+
+func initClient(cmd *cobra.Command) {
+	profile, _ := cmd.Flags().GetString("profile")
+	region, _ := cmd.Flags().GetString("region")
+	if LambdaClient == nil {
+		sess := util.CreateSession(profile, region)
+		LambdaClient = lambda.New(sess)
+	}
+}
+
+// LoadAllowlist reads <function>:<variable> patterns from the file, skipping empty lines and comments.
+func LoadAllowlist(file string) ([]string, error) {
+	fp, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+	var result []string
+	scanner := bufio.NewScanner(fp)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.Index(line, ":")
+		if i < 0 {
+			return nil, fmt.Errorf("invalid allowlist %s:%d: %s (expected <function>:<variable>)", file, n, line)
+		}
+		for _, pattern := range []string{line[:i], line[i+1:]} {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("invalid allowlist %s:%d: %s (%s)", file, n, line, err)
+			}
+		}
+		result = append(result, line)
+	}
+	return result, scanner.Err()
+}
+
+func allowed(allowlist []string, function string, variable string) bool {
+	for _, line := range allowlist {
+		i := strings.Index(line, ":")
+		matchFunction, _ := path.Match(line[:i], function)
+		matchVariable, _ := path.Match(line[i+1:], variable)
+		if matchFunction && matchVariable {
+			return true
+		}
+	}
+	return false
+}
+
+func Output(findings []*Finding) (string, error) {
+	if format == "json" {
+		jsonBytes, err := json.Marshal(findings)
+		return string(jsonBytes), err
+	} else if format == "sarif" {
+		return sarifOutput(findings)
+	} else if format == "table" {
+		return tableOutput(findings), nil
+	}
+	return "", errors.New("invalid format.")
+}
+
+func tableOutput(findings []*Finding) string {
+	tableString := &strings.Builder{}
+	table := tablewriter.NewWriter(tableString)
+	table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
+	table.SetCenterSeparator("|")
+	table.SetAutoWrapText(false)
+	table.SetHeader([]string{"Function", "Variable", "Severity", "Rule", "Value"})
+	for _, f := range findings {
+		table.Append([]string{f.Function, f.Variable, f.Severity, f.Rule, f.Value})
+	}
+	table.Render()
+	return tableString.String()
+}
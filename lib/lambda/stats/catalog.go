@@ -0,0 +1,133 @@
+package stats
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const dateFormat = "2006-01-02"
+
+// Now returns current time. It can be replaced in tests.
+var Now = time.Now
+
+// Runtime is the support schedule of a Lambda runtime.
+// Dates are in YYYY-MM-DD format, empty if not announced.
+type Runtime struct {
+	Name string `json:"name"`
+	// Deprecation is the end of support, after which security patches are no longer applied.
+	Deprecation string `json:"deprecation,omitempty"`
+	// BlockCreate is when creating functions with the runtime is blocked.
+	BlockCreate string `json:"block_create,omitempty"`
+	// BlockUpdate is when updating functions with the runtime is blocked.
+	BlockUpdate string `json:"block_update,omitempty"`
+	// Successor is the recommended runtime to migrate to.
+	Successor string `json:"successor,omitempty"`
+}
+
+// runtimeCatalog is built from the schedule published in AWS Lambda Developer Guide.
+// Entries can be added or overwritten with --runtime-catalog.
+var runtimeCatalog = map[string]*Runtime{}
+
+// runtimesJSON is the default catalog in the same format as --runtime-catalog.
+//
+//go:embed runtimes.json
+var runtimesJSON []byte
+
+func init() {
+	resetRuntimeCatalog()
+}
+
+func resetRuntimeCatalog() {
+	runtimes, err := parseRuntimes(runtimesJSON, "runtimes.json")
+	if err != nil {
+		panic(err)
+	}
+	runtimeCatalog = make(map[string]*Runtime)
+	for _, r := range runtimes {
+		runtimeCatalog[r.Name] = r
+	}
+}
+
+// LookupRuntime returns the schedule of the runtime, or nil if it is not in the catalog.
+func LookupRuntime(name string) *Runtime {
+	return runtimeCatalog[name]
+}
+
+// LoadRuntimeCatalog reads JSON array of runtimes from the file,
+// and adds them to the catalog. Runtimes with the same name are overwritten.
+func LoadRuntimeCatalog(file string) error {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	runtimes, err := parseRuntimes(b, file)
+	if err != nil {
+		return err
+	}
+	for _, r := range runtimes {
+		runtimeCatalog[r.Name] = r
+	}
+	return nil
+}
+
+// parseRuntimes parses JSON array of runtimes read from the file, checking format of dates.
+func parseRuntimes(b []byte, file string) ([]*Runtime, error) {
+	var runtimes []*Runtime
+	if err := json.Unmarshal(b, &runtimes); err != nil {
+		return nil, fmt.Errorf("invalid runtime catalog %s: %s", file, err)
+	}
+	for _, r := range runtimes {
+		for _, d := range []string{r.Deprecation, r.BlockCreate, r.BlockUpdate} {
+			if _, err := parseDate(d); err != nil {
+				return nil, fmt.Errorf("invalid date of %s in runtime catalog: %s", r.Name, d)
+			}
+		}
+	}
+	return runtimes, nil
+}
+
+// DeprecationDate returns the date of deprecation, or zero time if not announced.
+func (r *Runtime) DeprecationDate() time.Time {
+	t, _ := parseDate(r.Deprecation)
+	return t
+}
+
+// IsDeprecated returns whether the runtime is already deprecated at t.
+func (r *Runtime) IsDeprecated(t time.Time) bool {
+	d := r.DeprecationDate()
+	return !d.IsZero() && !t.Before(d)
+}
+
+// IsExpiring returns whether the runtime is deprecated within the duration from t.
+func (r *Runtime) IsExpiring(t time.Time, within time.Duration) bool {
+	d := r.DeprecationDate()
+	return !d.IsZero() && t.Before(d) && !t.Add(within).Before(d)
+}
+
+func parseDate(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(dateFormat, s)
+}
+
+// ParseDays parses duration like 90d, in addition to the format of time.ParseDuration.
+func ParseDays(s string) (time.Duration, error) {
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err != nil || days < 0 {
+			return 0, fmt.Errorf("invalid duration: %s", s)
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration: %s", s)
+	}
+	return d, nil
+}
@@ -0,0 +1,364 @@
+package stats
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+// tagKeyPrefix is prefix of group key to group by the value of the tag.
+const tagKeyPrefix = "tag:"
+
+// noValue is shown for functions without the value, like functions outside of VPC.
+const noValue = "-"
+
+// groupKeys are available for --group-by, in addition to tag:<key>.
+var groupKeys = []string{"runtime", "architecture", "package-type", "memory", "timeout", "layer", "vpc", "role"}
+
+// groupTitles are column headers of group keys.
+var groupTitles = map[string]string{
+	"account":      "Account",
+	"region":       "Region",
+	"runtime":      "Runtime",
+	"architecture": "Architecture",
+	"package-type": "Package Type",
+	"memory":       "Memory",
+	"timeout":      "Timeout",
+	"layer":        "Layer",
+	"vpc":          "VPC",
+	"role":         "Role",
+}
+
+// timeoutBuckets are upper bounds of timeout buckets in seconds.
+var timeoutBuckets = []int64{3, 30, 60, 300, 900}
+
+// Group is functions which have the same values of the group keys.
+type Group struct {
+	Values    []string
+	Functions []string
+}
+
+// Tags is tags of functions by function arn.
+type Tags map[string]map[string]string
+
+// validateGroupBy returns group keys, or runtime if --group-by is not passed.
+func validateGroupBy(keys []string) ([]string, error) {
+	if len(keys) == 0 {
+		return []string{"runtime"}, nil
+	}
+	for _, k := range keys {
+		if strings.HasPrefix(k, tagKeyPrefix) && len(k) > len(tagKeyPrefix) {
+			continue
+		}
+		if !contains(groupKeys, k) {
+			return nil, fmt.Errorf("invalid group key: %s (available: %s, tag:<key>)", k, strings.Join(groupKeys, ", "))
+		}
+	}
+	return keys, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// needsTags returns whether tags of functions are required to group.
+func needsTags(keys []string) bool {
+	for _, k := range keys {
+		if strings.HasPrefix(k, tagKeyPrefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// FetchGroups lists functions and groups them by --group-by.
+func FetchGroups(cmd *cobra.Command) ([]*Group, error) {
+	keys, err := validateGroupBy(groupBy)
+	if err != nil {
+		return nil, err
+	}
+	functions, err := fetchFunctions(cmd)
+	if err != nil {
+		return nil, err
+	}
+	return groupWithTags(LambdaClient, functions, keys)
+}
+
+// groupWithTags groups functions, with tags if required by the keys.
+func groupWithTags(client lambdaiface.LambdaAPI, functions []*lambda.FunctionConfiguration, keys []string) ([]*Group, error) {
+	var tags Tags
+	if needsTags(keys) {
+		var err error
+		if tags, err = listTags(client, functions); err != nil {
+			return nil, err
+		}
+	}
+	return groupFunctions(functions, keys, tags), nil
+}
+
+func listTags(client lambdaiface.LambdaAPI, functions []*lambda.FunctionConfiguration) (Tags, error) {
+	tags := make(Tags)
+	for _, f := range functions {
+		resp, err := client.ListTags(&lambda.ListTagsInput{Resource: f.FunctionArn})
+		if err != nil {
+			return nil, err
+		}
+		tags[aws.StringValue(f.FunctionArn)] = aws.StringValueMap(resp.Tags)
+	}
+	return tags, nil
+}
+
+// groupFunctions groups functions by the keys.
+// A function with multiple values of a key, like layers, belongs to each of the groups.
+func groupFunctions(functions []*lambda.FunctionConfiguration, keys []string, tags Tags) []*Group {
+	m := make(map[string]*Group)
+	for _, f := range functions {
+		for _, values := range combineValues(f, keys, tags) {
+			id := strings.Join(values, "\x00")
+			if _, ok := m[id]; !ok {
+				m[id] = &Group{Values: values}
+			}
+			m[id].Functions = append(m[id].Functions, aws.StringValue(f.FunctionName))
+		}
+	}
+	groups := make([]*Group, 0, len(m))
+	for _, g := range m {
+		groups = append(groups, g)
+	}
+	sortGroups(groups)
+	return groups
+}
+
+// combineValues returns all combinations of values of the keys.
+func combineValues(f *lambda.FunctionConfiguration, keys []string, tags Tags) [][]string {
+	combinations := [][]string{{}}
+	for _, k := range keys {
+		var next [][]string
+		for _, c := range combinations {
+			for _, v := range groupValues(f, k, tags) {
+				next = append(next, append(append([]string{}, c...), v))
+			}
+		}
+		combinations = next
+	}
+	return combinations
+}
+
+func groupValues(f *lambda.FunctionConfiguration, key string, tags Tags) []string {
+	switch key {
+	case "runtime":
+		return []string{RuntimeOf(f)}
+	case "architecture":
+		if len(f.Architectures) == 0 {
+			return []string{lambda.ArchitectureX8664}
+		}
+		return aws.StringValueSlice(f.Architectures)
+	case "package-type":
+		if f.PackageType == nil {
+			return []string{lambda.PackageTypeZip}
+		}
+		return []string{aws.StringValue(f.PackageType)}
+	case "memory":
+		return []string{strconv.FormatInt(aws.Int64Value(f.MemorySize), 10)}
+	case "timeout":
+		return []string{timeoutBucket(aws.Int64Value(f.Timeout))}
+	case "layer":
+		if len(f.Layers) == 0 {
+			return []string{noValue}
+		}
+		var arns []string
+		for _, l := range f.Layers {
+			arns = append(arns, aws.StringValue(l.Arn))
+		}
+		return arns
+	case "vpc":
+		if f.VpcConfig == nil || aws.StringValue(f.VpcConfig.VpcId) == "" {
+			return []string{noValue}
+		}
+		return []string{aws.StringValue(f.VpcConfig.VpcId)}
+	case "role":
+		return []string{aws.StringValue(f.Role)}
+	}
+	if v, ok := tags[aws.StringValue(f.FunctionArn)][strings.TrimPrefix(key, tagKeyPrefix)]; ok {
+		return []string{v}
+	}
+	return []string{noValue}
+}
+
+// timeoutBucket returns the range of timeout like 4-30s.
+func timeoutBucket(timeout int64) string {
+	var lower int64
+	for _, upper := range timeoutBuckets {
+		if timeout <= upper {
+			return fmt.Sprintf("%d-%ds", lower, upper)
+		}
+		lower = upper + 1
+	}
+	return fmt.Sprintf(">%ds", lower-1)
+}
+
+func sortGroups(groups []*Group) {
+	sort.Slice(groups, func(i, j int) bool {
+		for k := range groups[i].Values {
+			a, b := groups[i].Values[k], groups[j].Values[k]
+			if a != b {
+				return lessValue(a, b)
+			}
+		}
+		return false
+	})
+}
+
+// lessValue compares values numerically if both start with number, like memory and timeout.
+func lessValue(a, b string) bool {
+	na, okA := leadingNumber(a)
+	nb, okB := leadingNumber(b)
+	if okA && okB && na != nb {
+		return na < nb
+	}
+	return a < b
+}
+
+func leadingNumber(s string) (int64, bool) {
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	n, err := strconv.ParseInt(s[:i], 10, 64)
+	return n, err == nil
+}
+
+// OutputGroups formats groups with the columns of --group-by.
+func OutputGroups(groups []*Group) (string, error) {
+	keys, err := validateGroupBy(groupBy)
+	if err != nil {
+		return "", err
+	}
+	return renderGroups(keys, groups)
+}
+
+func renderGroups(columns []string, groups []*Group) (string, error) {
+	if format == "json" {
+		return groupsJsonOutput(columns, groups)
+	} else if format == "table" {
+		return groupsTableOutput(columns, groups), nil
+	}
+	return "", errors.New("invalid format.")
+}
+
+// jsonField is a field of JSON object, to keep the order of group keys.
+type jsonField struct {
+	key   string
+	value interface{}
+}
+
+type jsonObject []jsonField
+
+func (o jsonObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, f := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, err := json.Marshal(f.key)
+		if err != nil {
+			return nil, err
+		}
+		v, err := json.Marshal(f.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func groupsJsonOutput(columns []string, groups []*Group) (string, error) {
+	statsList := make([]jsonObject, len(groups))
+	for i, g := range groups {
+		var o jsonObject
+		for j, c := range columns {
+			o = append(o, jsonField{c, g.Values[j]})
+		}
+		o = append(o, jsonField{"count", len(g.Functions)})
+		if verbose {
+			o = append(o, jsonField{"functions", g.Functions})
+		}
+		// deprecation of runtime is added only when grouped by runtime.
+		for j, c := range columns {
+			if c != "runtime" {
+				continue
+			}
+			runtime := g.Values[j]
+			o = append(o, jsonField{"deprecated", isDeprecatedRuntime(runtime)})
+			if r := LookupRuntime(runtime); r != nil && r.Deprecation != "" {
+				o = append(o, jsonField{"deprecation_date", r.Deprecation})
+			}
+			if isExpiringRuntime(runtime) {
+				o = append(o, jsonField{"expiring", true})
+			}
+			if r := LookupRuntime(runtime); r != nil && r.Successor != "" {
+				o = append(o, jsonField{"successor", r.Successor})
+			}
+		}
+		statsList[i] = o
+	}
+	jsonBytes, err := json.Marshal(statsList)
+	return string(jsonBytes), err
+}
+
+func groupsTableOutput(columns []string, groups []*Group) string {
+	tableString := &strings.Builder{}
+	table := tablewriter.NewWriter(tableString)
+	table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
+	table.SetCenterSeparator("|")
+	var header []string
+	for _, c := range columns {
+		if title, ok := groupTitles[c]; ok {
+			header = append(header, title)
+		} else {
+			header = append(header, c)
+		}
+	}
+	header = append(header, "Count")
+	if verbose {
+		header = append(header, "Functions")
+	}
+	table.SetHeader(header)
+	for _, g := range groups {
+		var line []string
+		for j, c := range columns {
+			if c == "runtime" {
+				line = append(line, runtimeLabel(g.Values[j]))
+			} else {
+				line = append(line, g.Values[j])
+			}
+		}
+		line = append(line, strconv.Itoa(len(g.Functions)))
+		if verbose {
+			line = append(line, strings.Join(g.Functions, ", "))
+		}
+		table.Append(line)
+	}
+	table.Render()
+	return tableString.String()
+}
@@ -0,0 +1,236 @@
+package stats
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Blue-Pix/abc/lib/util"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/aws/aws-sdk-go/service/ecr/ecriface"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+// imageRuntime is the runtime category of container image functions, which have no runtime.
+const imageRuntime = "container-image"
+
+// baseImageLabel is the OCI annotation of the base image, set by docker buildx and other builders.
+const baseImageLabel = "org.opencontainers.image.base.name"
+
+var EcrClient ecriface.ECRAPI
+
+// httpClient downloads image configs from pre-signed URLs, with timeout not to hang on a stalled download.
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// errNotEcrImage is returned for images which are not in ECR, whose base image is not resolved.
+var errNotEcrImage = errors.New("not an ECR image")
+
+// unknownBaseImage is shown when resolving the base image fails.
+const unknownBaseImage = "unknown (error)"
+
+// ecrImageUri matches <account>.dkr.ecr.<region>.amazonaws.com/<repository>[:tag][@digest].
+var ecrImageUri = regexp.MustCompile(`^(\d{12})\.dkr\.ecr\.[a-z0-9-]+\.amazonaws\.com(?:\.cn)?/([^:@]+)`)
+
+var manifestMediaTypes = []string{
+	"application/vnd.docker.distribution.manifest.v2+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.oci.image.index.v1+json",
+}
+
+// ImageDetail is the image of a container image function.
+type ImageDetail struct {
+	Function  string `json:"function"`
+	ImageUri  string `json:"image_uri"`
+	Digest    string `json:"digest"`
+	BaseImage string `json:"base_image"`
+}
+
+// RuntimeOf returns the runtime of the function, or container-image for image functions.
+func RuntimeOf(f *lambda.FunctionConfiguration) string {
+	if aws.StringValue(f.Runtime) == "" && aws.StringValue(f.PackageType) == lambda.PackageTypeImage {
+		return imageRuntime
+	}
+	return aws.StringValue(f.Runtime)
+}
+
+// FetchImageDetails resolves image uri, digest and base image of container image functions.
+// Base image is resolved from the label of the image config in ECR, and - if it is not available.
+// If resolving fails, it is unknown (error), and the error is shown with --verbose.
+func FetchImageDetails(cmd *cobra.Command, functions []*lambda.FunctionConfiguration) ([]*ImageDetail, error) {
+	initEcrClient(cmd)
+	var result []*ImageDetail
+	for _, f := range functions {
+		if RuntimeOf(f) != imageRuntime {
+			continue
+		}
+		resp, err := LambdaClient.GetFunction(&lambda.GetFunctionInput{FunctionName: f.FunctionName})
+		if err != nil {
+			return nil, err
+		}
+		detail := &ImageDetail{Function: aws.StringValue(f.FunctionName), Digest: noValue, BaseImage: noValue}
+		if resp.Code != nil {
+			detail.ImageUri = aws.StringValue(resp.Code.ImageUri)
+			if i := strings.LastIndex(aws.StringValue(resp.Code.ResolvedImageUri), "@"); i >= 0 {
+				detail.Digest = aws.StringValue(resp.Code.ResolvedImageUri)[i+1:]
+			}
+		}
+		base, err := baseImage(detail, architectureOf(f))
+		if err != nil && err != errNotEcrImage {
+			detail.BaseImage = unknownBaseImage
+			if verbose {
+				fmt.Fprintf(cmd.ErrOrStderr(), "failed to resolve base image of %s: %s\n", detail.Function, err)
+			}
+		} else if base != "" {
+			detail.BaseImage = base
+		}
+		result = append(result, detail)
+	}
+	return result, nil
+}
+
+func initEcrClient(cmd *cobra.Command) {
+	profile, _ := cmd.Flags().GetString("profile")
+	region, _ := cmd.Flags().GetString("region")
+	if EcrClient == nil {
+		sess := util.CreateSession(profile, region)
+		EcrClient = ecr.New(sess)
+	}
+}
+
+func architectureOf(f *lambda.FunctionConfiguration) string {
+	if len(f.Architectures) > 0 && aws.StringValue(f.Architectures[0]) == lambda.ArchitectureArm64 {
+		return "arm64"
+	}
+	return "amd64"
+}
+
+type imageManifest struct {
+	Config struct {
+		Digest string `json:"digest"`
+	} `json:"config"`
+	// Manifests is set for multi-platform image index.
+	Manifests []struct {
+		Digest   string `json:"digest"`
+		Platform struct {
+			Architecture string `json:"architecture"`
+		} `json:"platform"`
+	} `json:"manifests"`
+}
+
+type imageConfig struct {
+	Config struct {
+		Labels map[string]string `json:"Labels"`
+	} `json:"config"`
+}
+
+// baseImage reads the label of the base image from the image config.
+func baseImage(detail *ImageDetail, architecture string) (string, error) {
+	m := ecrImageUri.FindStringSubmatch(detail.ImageUri)
+	if m == nil || detail.Digest == noValue {
+		return "", errNotEcrImage
+	}
+	registry, repository := m[1], m[2]
+	manifest, err := getManifest(registry, repository, detail.Digest)
+	if err != nil {
+		return "", err
+	}
+	if len(manifest.Manifests) > 0 {
+		digest := manifest.Manifests[0].Digest
+		for _, p := range manifest.Manifests {
+			if p.Platform.Architecture == architecture {
+				digest = p.Digest
+				break
+			}
+		}
+		if manifest, err = getManifest(registry, repository, digest); err != nil {
+			return "", err
+		}
+	}
+	resp, err := EcrClient.GetDownloadUrlForLayer(&ecr.GetDownloadUrlForLayerInput{
+		RegistryId:     aws.String(registry),
+		RepositoryName: aws.String(repository),
+		LayerDigest:    aws.String(manifest.Config.Digest),
+	})
+	if err != nil {
+		return "", err
+	}
+	body, err := download(aws.StringValue(resp.DownloadUrl))
+	if err != nil {
+		return "", err
+	}
+	var config imageConfig
+	if err := json.Unmarshal(body, &config); err != nil {
+		return "", err
+	}
+	return config.Config.Labels[baseImageLabel], nil
+}
+
+func getManifest(registry string, repository string, digest string) (*imageManifest, error) {
+	resp, err := EcrClient.BatchGetImage(&ecr.BatchGetImageInput{
+		RegistryId:         aws.String(registry),
+		RepositoryName:     aws.String(repository),
+		ImageIds:           []*ecr.ImageIdentifier{{ImageDigest: aws.String(digest)}},
+		AcceptedMediaTypes: aws.StringSlice(manifestMediaTypes),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Images) == 0 {
+		return nil, fmt.Errorf("image not found: %s@%s", repository, digest)
+	}
+	var manifest imageManifest
+	if err := json.Unmarshal([]byte(aws.StringValue(resp.Images[0].ImageManifest)), &manifest); err != nil {
+		return nil, err
+	}
+	return &manifest, nil
+}
+
+func download(url string) ([]byte, error) {
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download %s: %s", url, resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// OutputImageDetails appends details of container image functions to the output of stats.
+// JSON output becomes an object with stats and images.
+func OutputImageDetails(stats string, details []*ImageDetail) (string, error) {
+	if format == "json" {
+		if details == nil {
+			details = []*ImageDetail{}
+		}
+		jsonBytes, err := json.Marshal(struct {
+			Stats  json.RawMessage `json:"stats"`
+			Images []*ImageDetail  `json:"images"`
+		}{json.RawMessage(stats), details})
+		return string(jsonBytes), err
+	}
+	if len(details) == 0 {
+		return stats, nil
+	}
+	tableString := &strings.Builder{}
+	table := tablewriter.NewWriter(tableString)
+	table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
+	table.SetCenterSeparator("|")
+	table.SetAutoWrapText(false)
+	table.SetHeader([]string{"Function", "Image URI", "Digest", "Base Image"})
+	for _, d := range details {
+		table.Append([]string{d.Function, d.ImageUri, d.Digest, d.BaseImage})
+	}
+	table.Render()
+	return stats + "\n" + tableString.String(), nil
+}
@@ -0,0 +1,32 @@
+package stats
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs/cloudwatchlogsiface"
+)
+
+// LogGroupPrefix is the prefix of log groups created by Lambda, followed by the function name.
+const LogGroupPrefix = "/aws/lambda/"
+
+// ListLogGroups returns all log groups of Lambda functions.
+func ListLogGroups(client cloudwatchlogsiface.CloudWatchLogsAPI) ([]*cloudwatchlogs.LogGroup, error) {
+	var result []*cloudwatchlogs.LogGroup
+	var nextToken *string
+	for {
+		params := &cloudwatchlogs.DescribeLogGroupsInput{
+			LogGroupNamePrefix: aws.String(LogGroupPrefix),
+			NextToken:          nextToken,
+		}
+		resp, err := client.DescribeLogGroups(params)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, resp.LogGroups...)
+		if resp.NextToken == nil {
+			break
+		}
+		nextToken = resp.NextToken
+	}
+	return result, nil
+}
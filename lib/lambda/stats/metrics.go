@@ -0,0 +1,103 @@
+package stats
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+)
+
+// maxQueries is the limit of metric queries in a GetMetricData request.
+const maxQueries = 500
+
+// MetricQuery is a metric of AWS/Lambda namespace to fetch.
+type MetricQuery struct {
+	Function string
+	// Resource is "function:qualifier" for metrics of an alias or version, empty for the function.
+	Resource string
+	Metric   string
+	// Stat is Sum, Maximum or Average.
+	Stat string
+}
+
+// GetMetrics returns a value of each query aggregated over the period, in the order of queries.
+// Queries are sent in batches of 500, and metrics without datapoints are 0.
+func GetMetrics(client cloudwatchiface.CloudWatchAPI, queries []*MetricQuery, start time.Time, end time.Time) ([]float64, error) {
+	result := make([]float64, len(queries))
+	// a single period covering the whole range, which is a multiple of 3600 as required for data older than 63 days.
+	period := int64(math.Ceil(end.Sub(start).Hours())) * 3600
+	for offset := 0; offset < len(queries); offset += maxQueries {
+		batch := queries[offset:]
+		if len(batch) > maxQueries {
+			batch = batch[:maxQueries]
+		}
+		var dataQueries []*cloudwatch.MetricDataQuery
+		for i, q := range batch {
+			dimensions := []*cloudwatch.Dimension{{Name: aws.String("FunctionName"), Value: aws.String(q.Function)}}
+			if q.Resource != "" {
+				dimensions = append(dimensions, &cloudwatch.Dimension{Name: aws.String("Resource"), Value: aws.String(q.Resource)})
+			}
+			dataQueries = append(dataQueries, &cloudwatch.MetricDataQuery{
+				Id: aws.String(fmt.Sprintf("q%d", offset+i)),
+				MetricStat: &cloudwatch.MetricStat{
+					Metric: &cloudwatch.Metric{
+						Namespace:  aws.String("AWS/Lambda"),
+						MetricName: aws.String(q.Metric),
+						Dimensions: dimensions,
+					},
+					Period: aws.Int64(period),
+					Stat:   aws.String(q.Stat),
+				},
+			})
+		}
+		values := make(map[string][]*float64)
+		var nextToken *string
+		for {
+			params := &cloudwatch.GetMetricDataInput{
+				MetricDataQueries: dataQueries,
+				StartTime:         aws.Time(start),
+				EndTime:           aws.Time(end),
+				NextToken:         nextToken,
+			}
+			resp, err := client.GetMetricData(params)
+			if err != nil {
+				return nil, err
+			}
+			for _, r := range resp.MetricDataResults {
+				values[aws.StringValue(r.Id)] = append(values[aws.StringValue(r.Id)], r.Values...)
+			}
+			if resp.NextToken == nil {
+				break
+			}
+			nextToken = resp.NextToken
+		}
+		for i, q := range batch {
+			result[offset+i] = aggregate(q.Stat, aws.Float64ValueSlice(values[fmt.Sprintf("q%d", offset+i)]))
+		}
+	}
+	return result, nil
+}
+
+func aggregate(stat string, values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	var result float64
+	for i, v := range values {
+		switch stat {
+		case cloudwatch.StatisticMaximum:
+			if i == 0 || v > result {
+				result = v
+			}
+		default:
+			result += v
+		}
+	}
+	if stat == cloudwatch.StatisticAverage {
+		result /= float64(len(values))
+	}
+	return result
+}
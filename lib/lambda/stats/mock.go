@@ -2,6 +2,10 @@ package stats
 
 import (
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/aws/aws-sdk-go/service/ecr/ecriface"
 	"github.com/aws/aws-sdk-go/service/lambda"
 	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
 	"github.com/stretchr/testify/mock"
@@ -21,6 +25,61 @@ func (client *MockLambdaClient) ListFunctions(params *lambda.ListFunctionsInput)
 	}
 }
 
+func (client *MockLambdaClient) ListTags(params *lambda.ListTagsInput) (*lambda.ListTagsOutput, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*lambda.ListTagsOutput), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
+func (client *MockLambdaClient) GetFunction(params *lambda.GetFunctionInput) (*lambda.GetFunctionOutput, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*lambda.GetFunctionOutput), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
+type MockEcrClient struct {
+	mock.Mock
+	ecriface.ECRAPI
+}
+
+func (client *MockEcrClient) BatchGetImage(params *ecr.BatchGetImageInput) (*ecr.BatchGetImageOutput, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*ecr.BatchGetImageOutput), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
+func (client *MockEcrClient) GetDownloadUrlForLayer(params *ecr.GetDownloadUrlForLayerInput) (*ecr.GetDownloadUrlForLayerOutput, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*ecr.GetDownloadUrlForLayerOutput), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
+type MockCloudWatchClient struct {
+	mock.Mock
+	cloudwatchiface.CloudWatchAPI
+}
+
+func (client *MockCloudWatchClient) GetMetricData(params *cloudwatch.GetMetricDataInput) (*cloudwatch.GetMetricDataOutput, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*cloudwatch.GetMetricDataOutput), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
 func SetMockDefaultBehaviour(lm *MockLambdaClient) {
 	lm.On("ListFunctions", &lambda.ListFunctionsInput{
 		Marker:   nil,
@@ -77,3 +136,31 @@ func SetMockDefaultBehaviour(lm *MockLambdaClient) {
 		nil,
 	)
 }
+
+type MockProvider struct {
+	mock.Mock
+}
+
+func (p *MockProvider) Account(credential string) (string, error) {
+	args := p.Called(credential)
+	return args.String(0), args.Error(1)
+}
+
+func (p *MockProvider) Regions(credential string) ([]string, error) {
+	args := p.Called(credential)
+	if args.Get(0) != nil {
+		return args.Get(0).([]string), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
+func (p *MockProvider) DefaultRegion(credential string) string {
+	args := p.Called(credential)
+	return args.String(0)
+}
+
+func (p *MockProvider) LambdaClient(credential string, region string) lambdaiface.LambdaAPI {
+	args := p.Called(credential, region)
+	return args.Get(0).(lambdaiface.LambdaAPI)
+}
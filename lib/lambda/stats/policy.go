@@ -0,0 +1,85 @@
+package stats
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// Policy is a resource-based policy of a function or a layer version.
+type Policy struct {
+	Statement Statements `json:"Statement"`
+}
+
+type Statement struct {
+	Sid       string                            `json:"Sid"`
+	Effect    string                            `json:"Effect"`
+	Principal json.RawMessage                   `json:"Principal"`
+	Condition map[string]map[string]interface{} `json:"Condition"`
+}
+
+// Statements accepts a single statement as well as an array of statements.
+type Statements []*Statement
+
+func (s *Statements) UnmarshalJSON(b []byte) error {
+	var list []*Statement
+	if err := json.Unmarshal(b, &list); err == nil {
+		*s = list
+		return nil
+	}
+	var single Statement
+	if err := json.Unmarshal(b, &single); err != nil {
+		return err
+	}
+	*s = Statements{&single}
+	return nil
+}
+
+// Principals returns principals of the statement, like * and s3.amazonaws.com.
+// Principal is either "*" or an object of type to a principal or an array of principals.
+func (s *Statement) Principals() []string {
+	var wildcard string
+	if err := json.Unmarshal(s.Principal, &wildcard); err == nil {
+		return []string{wildcard}
+	}
+	var typed map[string]json.RawMessage
+	if err := json.Unmarshal(s.Principal, &typed); err != nil {
+		return nil
+	}
+	var result []string
+	for _, v := range typed {
+		var one string
+		if err := json.Unmarshal(v, &one); err == nil {
+			result = append(result, one)
+			continue
+		}
+		var list []string
+		if err := json.Unmarshal(v, &list); err == nil {
+			result = append(result, list...)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// HasCondition returns whether the statement has any of condition keys, compared in lower case.
+func (s *Statement) HasCondition(keys ...string) bool {
+	for _, conditions := range s.Condition {
+		for k := range conditions {
+			for _, key := range keys {
+				if strings.ToLower(k) == key {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func ParsePolicy(document string) (*Policy, error) {
+	var p Policy
+	if err := json.Unmarshal([]byte(document), &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
@@ -7,6 +7,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Blue-Pix/abc/lib/util"
 	"github.com/aws/aws-sdk-go/aws"
@@ -19,8 +20,17 @@ import (
 var LambdaClient lambdaiface.LambdaAPI
 
 var (
-	verbose bool
-	format  string
+	verbose      bool
+	format       string
+	warnWithin   string
+	catalogFile  string
+	warnDuration time.Duration
+	regions      []string
+	allRegions   bool
+	profiles     []string
+	roleArns     []string
+	groupBy      []string
+	details      bool
 )
 
 func NewCmd() *cobra.Command {
@@ -32,9 +42,34 @@ func NewCmd() *cobra.Command {
 This command describes Lambda functions count by runtime.
 By default, output format is markdown table.
 
+Deprecated runtimes are marked based on the built-in runtime catalog,
+which records deprecation, block-create and block-update dates and the recommended successor.
+The catalog can be updated by --runtime-catalog with JSON file like below.
+[{"name": "python3.9", "deprecation": "2025-12-15", "block_create": "2026-06-01", "block_update": "2026-07-01", "successor": "python3.13"}]
+With --warn-within, runtimes to be deprecated within the period are also marked.
+
+With --regions, --all-regions, --profiles or --role-arns,
+functions of each account and region are listed concurrently, and shown with account and region.
+Failure of some accounts or regions does not stop the others.
+
+Container image functions have no runtime, and are counted as container-image.
+With --details, image uri, digest and base image of them are also shown.
+Base image is read from the org.opencontainers.image.base.name label of the image in ECR.
+It is unknown (error) when reading the label fails, and the error is shown with --verbose.
+
+With --group-by, functions are counted by the combination of the keys, for example
+--group-by runtime,architecture,tag:team
+Timeout is grouped into buckets (0-3s, 4-30s, 31-60s, 61-300s, 301-900s),
+and a function with multiple layers is counted in each layer.
+
 Internally it uses aws lambda api.
 Please configure your aws credentials with following policies.
-- lambda:ListFunctions`,
+- lambda:ListFunctions
+- lambda:ListTags (with tag:<key> of --group-by)
+- lambda:GetFunction, ecr:BatchGetImage, ecr:GetDownloadUrlForLayer (with --details)
+- sts:GetCallerIdentity (with --regions, --all-regions, --profiles or --role-arns)
+- ec2:DescribeRegions (with --all-regions)
+- sts:AssumeRole (with --role-arns)`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			err := run(cmd, args)
 			return err
@@ -42,30 +77,88 @@ Please configure your aws credentials with following policies.
 	}
 	cmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "show detail")
 	cmd.Flags().StringVarP(&format, "format", "f", "table", "output format (table or json)")
+	cmd.Flags().BoolVar(&details, "details", false, "(optional) show image uri, digest and base image of container image functions")
+	cmd.Flags().StringSliceVar(&groupBy, "group-by", []string{}, "(optional) keys to group by, comma separated (runtime, architecture, package-type, memory, timeout, layer, vpc, role or tag:<key>)")
+	cmd.Flags().StringSliceVar(&regions, "regions", []string{}, "(optional) regions to aggregate, comma separated")
+	cmd.Flags().BoolVar(&allRegions, "all-regions", false, "(optional) aggregate all regions enabled in the account")
+	cmd.Flags().StringSliceVar(&profiles, "profiles", []string{}, "(optional) aws profiles of accounts to aggregate, comma separated")
+	cmd.Flags().StringSliceVar(&roleArns, "role-arns", []string{}, "(optional) roles to assume for accounts to aggregate, comma separated")
+	cmd.Flags().StringVar(&warnWithin, "warn-within", "", "(optional) mark runtimes to be deprecated within the period (e.g. 90d)")
+	cmd.Flags().StringVar(&catalogFile, "runtime-catalog", "", "(optional) JSON file to add or overwrite runtimes of the catalog")
 	return cmd
 }
 
 func run(cmd *cobra.Command, args []string) error {
-	data, err := FetchData(cmd, args)
+	if isMultiTarget() {
+		if details {
+			return errors.New("--details cannot be used with --regions, --all-regions, --profiles or --role-arns")
+		}
+		return runTargets(cmd)
+	}
+	keys, err := validateGroupBy(groupBy)
+	if err != nil {
+		return err
+	}
+	functions, err := fetchFunctions(cmd)
+	if err != nil {
+		return err
+	}
+	var str string
+	if len(functions) == 0 && format == "table" {
+		str = "no function found."
+	} else if len(groupBy) > 0 {
+		groups, err := groupWithTags(LambdaClient, functions, keys)
+		if err != nil {
+			return err
+		}
+		if str, err = OutputGroups(groups); err != nil {
+			return err
+		}
+	} else {
+		if str, err = Output(countByRuntime(functions)); err != nil {
+			return err
+		}
+	}
+	if details {
+		images, err := FetchImageDetails(cmd, functions)
+		if err != nil {
+			return err
+		}
+		if str, err = OutputImageDetails(str, images); err != nil {
+			return err
+		}
+	}
+	cmd.Println(str)
+	return nil
+}
+
+func runTargets(cmd *cobra.Command) error {
+	results, err := FetchTargets(cmd)
 	if err != nil {
 		return err
 	}
 	var str string
-	if len(data) == 0 && format == "table" {
+	if countFunctions(results) == 0 && format == "table" {
 		str = "no function found."
 	} else {
-		str, err = Output(data)
+		str, err = OutputTargets(results)
 		if err != nil {
 			return err
 		}
 	}
 	cmd.Println(str)
+	messages := Errors(results)
+	for _, m := range messages {
+		fmt.Fprintln(cmd.ErrOrStderr(), m)
+	}
+	if len(messages) > 0 {
+		return fmt.Errorf("failed to list functions of %d targets", len(messages))
+	}
 	return nil
 }
 
 func FetchData(cmd *cobra.Command, args []string) (map[string][]string, error) {
-	initClient(cmd)
-	functions, err := listFunctions()
+	functions, err := fetchFunctions(cmd)
 	if err != nil {
 		return nil, err
 	}
@@ -73,6 +166,33 @@ func FetchData(cmd *cobra.Command, args []string) (map[string][]string, error) {
 	return count, nil
 }
 
+func fetchFunctions(cmd *cobra.Command) ([]*lambda.FunctionConfiguration, error) {
+	if err := loadCatalog(); err != nil {
+		return nil, err
+	}
+	initClient(cmd)
+	return ListFunctions(LambdaClient)
+}
+
+// loadCatalog applies --runtime-catalog and --warn-within.
+func loadCatalog() error {
+	resetRuntimeCatalog()
+	if catalogFile != "" {
+		if err := LoadRuntimeCatalog(catalogFile); err != nil {
+			return err
+		}
+	}
+	warnDuration = 0
+	if warnWithin != "" {
+		d, err := ParseDays(warnWithin)
+		if err != nil {
+			return err
+		}
+		warnDuration = d
+	}
+	return nil
+}
+
 func initClient(cmd *cobra.Command) {
 	profile, _ := cmd.Flags().GetString("profile")
 	region, _ := cmd.Flags().GetString("region")
@@ -82,7 +202,8 @@ func initClient(cmd *cobra.Command) {
 	}
 }
 
-func listFunctions() ([]*lambda.FunctionConfiguration, error) {
+// ListFunctions lists all functions with the client.
+func ListFunctions(client lambdaiface.LambdaAPI) ([]*lambda.FunctionConfiguration, error) {
 	var result []*lambda.FunctionConfiguration
 	var nextMarker *string
 	for {
@@ -90,7 +211,7 @@ func listFunctions() ([]*lambda.FunctionConfiguration, error) {
 			MaxItems: aws.Int64(1000),
 			Marker:   nextMarker,
 		}
-		resp, err := LambdaClient.ListFunctions(params)
+		resp, err := client.ListFunctions(params)
 		if err != nil {
 			return nil, err
 		}
@@ -107,7 +228,7 @@ func listFunctions() ([]*lambda.FunctionConfiguration, error) {
 func countByRuntime(result []*lambda.FunctionConfiguration) map[string][]string {
 	m := make(map[string][]string)
 	for _, f := range result {
-		runtime := aws.StringValue(f.Runtime)
+		runtime := RuntimeOf(f)
 		if _, hasKey := m[runtime]; hasKey == false {
 			m[runtime] = []string{aws.StringValue(f.FunctionName)}
 		} else {
@@ -148,9 +269,12 @@ func normalJsonOutput(count map[string][]string) (string, error) {
 	keys := sortKey(count)
 
 	type Stats struct {
-		Runtime    string `json:"runtime"`
-		Count      int    `json:"count"`
-		Deprecated bool   `json:"deprecated"`
+		Runtime         string `json:"runtime"`
+		Count           int    `json:"count"`
+		Deprecated      bool   `json:"deprecated"`
+		DeprecationDate string `json:"deprecation_date,omitempty"`
+		Expiring        bool   `json:"expiring,omitempty"`
+		Successor       string `json:"successor,omitempty"`
 	}
 
 	statsList := make([]Stats, len(keys))
@@ -159,6 +283,11 @@ func normalJsonOutput(count map[string][]string) (string, error) {
 			Runtime:    k,
 			Count:      len(count[k]),
 			Deprecated: isDeprecatedRuntime(k),
+			Expiring:   isExpiringRuntime(k),
+		}
+		if r := LookupRuntime(k); r != nil {
+			statsList[i].DeprecationDate = r.Deprecation
+			statsList[i].Successor = r.Successor
 		}
 	}
 	jsonBytes, err := json.Marshal(statsList)
@@ -168,10 +297,13 @@ func verboseJsonOutput(count map[string][]string) (string, error) {
 	keys := sortKey(count)
 
 	type VerboseStats struct {
-		Runtime    string   `json:"runtime"`
-		Count      int      `json:"count"`
-		Functions  []string `json:"functions"`
-		Deprecated bool     `json:"deprecated"`
+		Runtime         string   `json:"runtime"`
+		Count           int      `json:"count"`
+		Functions       []string `json:"functions"`
+		Deprecated      bool     `json:"deprecated"`
+		DeprecationDate string   `json:"deprecation_date,omitempty"`
+		Expiring        bool     `json:"expiring,omitempty"`
+		Successor       string   `json:"successor,omitempty"`
 	}
 
 	statsList := make([]VerboseStats, len(keys))
@@ -181,6 +313,11 @@ func verboseJsonOutput(count map[string][]string) (string, error) {
 			Count:      len(count[k]),
 			Functions:  count[k],
 			Deprecated: isDeprecatedRuntime(k),
+			Expiring:   isExpiringRuntime(k),
+		}
+		if r := LookupRuntime(k); r != nil {
+			statsList[i].DeprecationDate = r.Deprecation
+			statsList[i].Successor = r.Successor
 		}
 	}
 	jsonBytes, err := json.Marshal(statsList)
@@ -204,10 +341,7 @@ func tableOutput(count map[string][]string) string {
 func normalTableOutput(keys []string, table *tablewriter.Table, count map[string][]string) {
 	table.SetHeader([]string{"Runtime", "Count"})
 	for _, k := range keys {
-		runtime := k
-		if isDeprecatedRuntime(runtime) {
-			runtime = fmt.Sprintf("\x1b[91m%s（Deprecated）\x1b[0m", runtime)
-		}
+		runtime := runtimeLabel(k)
 		table.Append([]string{runtime, strconv.Itoa(len(count[k]))})
 	}
 	table.Render()
@@ -216,24 +350,33 @@ func normalTableOutput(keys []string, table *tablewriter.Table, count map[string
 func verboseTableOutput(keys []string, table *tablewriter.Table, count map[string][]string) {
 	table.SetHeader([]string{"Runtime", "Count", "Functions"})
 	for _, k := range keys {
-		runtime := k
-		if isDeprecatedRuntime(runtime) {
-			runtime = fmt.Sprintf("\x1b[91m%s（Deprecated）\x1b[0m", runtime)
-		}
+		runtime := runtimeLabel(k)
 		table.Append([]string{runtime, strconv.Itoa(len(count[k])), strings.Join(count[k], ", ")})
 	}
 	table.Render()
 }
 
-var deprecatedRuntimes = [7]string{
-	"nodejs8.10", "nodejs6.10", "nodejs4.3", "nodejs4.3-edge", "nodejs", "dotnetcore2.0", "dotnetcore1.0",
+// runtimeLabel marks deprecated runtimes in red, and runtimes to be deprecated soon in yellow.
+func runtimeLabel(runtime string) string {
+	if isDeprecatedRuntime(runtime) {
+		return fmt.Sprintf("\x1b[91m%s（Deprecated）\x1b[0m", runtime)
+	}
+	if isExpiringRuntime(runtime) {
+		return fmt.Sprintf("\x1b[93m%s（EOL:%s）\x1b[0m", runtime, LookupRuntime(runtime).Deprecation)
+	}
+	return runtime
 }
 
 func isDeprecatedRuntime(runtime string) bool {
-	for _, r := range deprecatedRuntimes {
-		if runtime == r {
-			return true
-		}
+	r := LookupRuntime(runtime)
+	return r != nil && r.IsDeprecated(Now())
+}
+
+// isExpiringRuntime returns whether the runtime is deprecated within --warn-within.
+func isExpiringRuntime(runtime string) bool {
+	if warnDuration == 0 {
+		return false
 	}
-	return false
+	r := LookupRuntime(runtime)
+	return r != nil && r.IsExpiring(Now(), warnDuration)
 }
@@ -1,19 +1,30 @@
 package stats_test
 
 import (
+	"bytes"
 	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
 	"testing"
+	"time"
 
 	"github.com/Blue-Pix/abc/lib/lambda/stats"
+	"github.com/Blue-Pix/abc/lib/util/testutil"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/ecr"
 	"github.com/aws/aws-sdk-go/service/lambda"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 func initMockClient(lm *stats.MockLambdaClient) {
 	stats.SetMockDefaultBehaviour(lm)
 	stats.LambdaClient = lm
+	stats.Now = func() time.Time { return time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC) }
 }
 
 func TestFetchData(t *testing.T) {
@@ -222,26 +233,26 @@ func TestOutput(t *testing.T) {
 			initMockClient(lm)
 
 			expected := "["
-			expected += "{\"runtime\":\"dotnetcore1.0\",\"count\":1,\"deprecated\":true},"
-			expected += "{\"runtime\":\"dotnetcore2.0\",\"count\":1,\"deprecated\":true},"
-			expected += "{\"runtime\":\"dotnetcore2.1\",\"count\":1,\"deprecated\":false},"
-			expected += "{\"runtime\":\"dotnetcore3.1\",\"count\":1,\"deprecated\":false},"
-			expected += "{\"runtime\":\"go1.x\",\"count\":4,\"deprecated\":false},"
-			expected += "{\"runtime\":\"java11\",\"count\":1,\"deprecated\":false},"
-			expected += "{\"runtime\":\"java8\",\"count\":1,\"deprecated\":false},"
-			expected += "{\"runtime\":\"nodejs\",\"count\":2,\"deprecated\":true},"
-			expected += "{\"runtime\":\"nodejs10.x\",\"count\":1,\"deprecated\":false},"
-			expected += "{\"runtime\":\"nodejs12.x\",\"count\":1,\"deprecated\":false},"
-			expected += "{\"runtime\":\"nodejs4.3\",\"count\":1,\"deprecated\":true},"
-			expected += "{\"runtime\":\"nodejs4.3-edge\",\"count\":1,\"deprecated\":true},"
-			expected += "{\"runtime\":\"nodejs6.10\",\"count\":1,\"deprecated\":true},"
-			expected += "{\"runtime\":\"nodejs8.10\",\"count\":1,\"deprecated\":true},"
-			expected += "{\"runtime\":\"provided\",\"count\":6,\"deprecated\":false},"
-			expected += "{\"runtime\":\"python3.6\",\"count\":1,\"deprecated\":false},"
-			expected += "{\"runtime\":\"python3.7\",\"count\":1,\"deprecated\":false},"
-			expected += "{\"runtime\":\"python3.8\",\"count\":3,\"deprecated\":false},"
-			expected += "{\"runtime\":\"ruby2.5\",\"count\":1,\"deprecated\":false},"
-			expected += "{\"runtime\":\"ruby2.7\",\"count\":1,\"deprecated\":false}"
+			expected += "{\"runtime\":\"dotnetcore1.0\",\"count\":1,\"deprecated\":true,\"deprecation_date\":\"2019-07-30\",\"successor\":\"dotnet8\"},"
+			expected += "{\"runtime\":\"dotnetcore2.0\",\"count\":1,\"deprecated\":true,\"deprecation_date\":\"2019-05-30\",\"successor\":\"dotnet8\"},"
+			expected += "{\"runtime\":\"dotnetcore2.1\",\"count\":1,\"deprecated\":false,\"deprecation_date\":\"2022-01-05\",\"successor\":\"dotnet8\"},"
+			expected += "{\"runtime\":\"dotnetcore3.1\",\"count\":1,\"deprecated\":false,\"deprecation_date\":\"2023-04-03\",\"successor\":\"dotnet8\"},"
+			expected += "{\"runtime\":\"go1.x\",\"count\":4,\"deprecated\":false,\"deprecation_date\":\"2024-01-08\",\"successor\":\"provided.al2023\"},"
+			expected += "{\"runtime\":\"java11\",\"count\":1,\"deprecated\":false,\"deprecation_date\":\"2026-06-30\",\"successor\":\"java21\"},"
+			expected += "{\"runtime\":\"java8\",\"count\":1,\"deprecated\":false,\"deprecation_date\":\"2024-01-08\",\"successor\":\"java8.al2\"},"
+			expected += "{\"runtime\":\"nodejs\",\"count\":2,\"deprecated\":true,\"deprecation_date\":\"2016-10-31\",\"successor\":\"nodejs22.x\"},"
+			expected += "{\"runtime\":\"nodejs10.x\",\"count\":1,\"deprecated\":false,\"deprecation_date\":\"2021-07-30\",\"successor\":\"nodejs22.x\"},"
+			expected += "{\"runtime\":\"nodejs12.x\",\"count\":1,\"deprecated\":false,\"deprecation_date\":\"2023-03-31\",\"successor\":\"nodejs22.x\"},"
+			expected += "{\"runtime\":\"nodejs4.3\",\"count\":1,\"deprecated\":true,\"deprecation_date\":\"2020-03-05\",\"successor\":\"nodejs22.x\"},"
+			expected += "{\"runtime\":\"nodejs4.3-edge\",\"count\":1,\"deprecated\":true,\"deprecation_date\":\"2020-04-30\",\"successor\":\"nodejs22.x\"},"
+			expected += "{\"runtime\":\"nodejs6.10\",\"count\":1,\"deprecated\":true,\"deprecation_date\":\"2019-08-12\",\"successor\":\"nodejs22.x\"},"
+			expected += "{\"runtime\":\"nodejs8.10\",\"count\":1,\"deprecated\":true,\"deprecation_date\":\"2020-03-06\",\"successor\":\"nodejs22.x\"},"
+			expected += "{\"runtime\":\"provided\",\"count\":6,\"deprecated\":false,\"deprecation_date\":\"2024-01-08\",\"successor\":\"provided.al2023\"},"
+			expected += "{\"runtime\":\"python3.6\",\"count\":1,\"deprecated\":false,\"deprecation_date\":\"2022-07-18\",\"successor\":\"python3.13\"},"
+			expected += "{\"runtime\":\"python3.7\",\"count\":1,\"deprecated\":false,\"deprecation_date\":\"2023-12-04\",\"successor\":\"python3.13\"},"
+			expected += "{\"runtime\":\"python3.8\",\"count\":3,\"deprecated\":false,\"deprecation_date\":\"2024-10-14\",\"successor\":\"python3.13\"},"
+			expected += "{\"runtime\":\"ruby2.5\",\"count\":1,\"deprecated\":false,\"deprecation_date\":\"2021-07-30\",\"successor\":\"ruby3.3\"},"
+			expected += "{\"runtime\":\"ruby2.7\",\"count\":1,\"deprecated\":false,\"deprecation_date\":\"2023-12-07\",\"successor\":\"ruby3.3\"}"
 			expected += "]"
 
 			cmd := stats.NewCmd()
@@ -262,26 +273,26 @@ func TestOutput(t *testing.T) {
 			initMockClient(lm)
 
 			expected := "["
-			expected += "{\"runtime\":\"dotnetcore1.0\",\"count\":1,\"functions\":[\"dotnet1.0-func-1\"],\"deprecated\":true},"
+			expected += "{\"runtime\":\"dotnetcore1.0\",\"count\":1,\"functions\":[\"dotnet1.0-func-1\"],\"deprecated\":true,\"deprecation_date\":\"2019-07-30\",\"successor\":\"dotnet8\"},"
 			expected += "{\"runtime\":\"dotnetcore2.0\",\"count\":1,\"functions\":[\"dotnet2.0-func-1\"],"
-			expected += "\"deprecated\":true},{\"runtime\":\"dotnetcore2.1\",\"count\":1,\"functions\":[\"dotnet2.1-func-1\"],\"deprecated\":false},"
-			expected += "{\"runtime\":\"dotnetcore3.1\",\"count\":1,\"functions\":[\"dotnet3.1-func-1\"],\"deprecated\":false},"
-			expected += "{\"runtime\":\"go1.x\",\"count\":4,\"functions\":[\"go1-func-1\",\"go1-func-2\",\"go1-func-3\",\"go1-func-4\"],\"deprecated\":false},"
-			expected += "{\"runtime\":\"java11\",\"count\":1,\"functions\":[\"java11-func-1\"],\"deprecated\":false},"
-			expected += "{\"runtime\":\"java8\",\"count\":1,\"functions\":[\"java8-func-1\"],\"deprecated\":false},"
-			expected += "{\"runtime\":\"nodejs\",\"count\":2,\"functions\":[\"nodejs0.10-func-1\",\"nodejs0.10-func-2\"],\"deprecated\":true},"
-			expected += "{\"runtime\":\"nodejs10.x\",\"count\":1,\"functions\":[\"node10-func-1\"],\"deprecated\":false},"
-			expected += "{\"runtime\":\"nodejs12.x\",\"count\":1,\"functions\":[\"node12-func-1\"],\"deprecated\":false},"
-			expected += "{\"runtime\":\"nodejs4.3\",\"count\":1,\"functions\":[\"nodejs4.3-func-1\"],\"deprecated\":true},"
-			expected += "{\"runtime\":\"nodejs4.3-edge\",\"count\":1,\"functions\":[\"nodejs4.3edge-func-1\"],\"deprecated\":true},"
-			expected += "{\"runtime\":\"nodejs6.10\",\"count\":1,\"functions\":[\"nodejs6.10-func-1\"],\"deprecated\":true},"
-			expected += "{\"runtime\":\"nodejs8.10\",\"count\":1,\"functions\":[\"nodejs8.10-func-1\"],\"deprecated\":true},"
-			expected += "{\"runtime\":\"provided\",\"count\":6,\"functions\":[\"provided-func-1\",\"provided-func-2\",\"provided-func-3\",\"provided-func-4\",\"provided-func-5\",\"provided-func-6\"],\"deprecated\":false},"
-			expected += "{\"runtime\":\"python3.6\",\"count\":1,\"functions\":[\"python3.6-func-1\"],\"deprecated\":false},"
-			expected += "{\"runtime\":\"python3.7\",\"count\":1,\"functions\":[\"python3.7-func-1\"],\"deprecated\":false},"
-			expected += "{\"runtime\":\"python3.8\",\"count\":3,\"functions\":[\"python3.8-func-1\",\"python3.8-func-2\",\"python3.8-func-3\"],\"deprecated\":false},"
-			expected += "{\"runtime\":\"ruby2.5\",\"count\":1,\"functions\":[\"ruby2.5-func-1\"],\"deprecated\":false},"
-			expected += "{\"runtime\":\"ruby2.7\",\"count\":1,\"functions\":[\"ruby2.7-func-1\"],\"deprecated\":false}"
+			expected += "\"deprecated\":true,\"deprecation_date\":\"2019-05-30\",\"successor\":\"dotnet8\"},{\"runtime\":\"dotnetcore2.1\",\"count\":1,\"functions\":[\"dotnet2.1-func-1\"],\"deprecated\":false,\"deprecation_date\":\"2022-01-05\",\"successor\":\"dotnet8\"},"
+			expected += "{\"runtime\":\"dotnetcore3.1\",\"count\":1,\"functions\":[\"dotnet3.1-func-1\"],\"deprecated\":false,\"deprecation_date\":\"2023-04-03\",\"successor\":\"dotnet8\"},"
+			expected += "{\"runtime\":\"go1.x\",\"count\":4,\"functions\":[\"go1-func-1\",\"go1-func-2\",\"go1-func-3\",\"go1-func-4\"],\"deprecated\":false,\"deprecation_date\":\"2024-01-08\",\"successor\":\"provided.al2023\"},"
+			expected += "{\"runtime\":\"java11\",\"count\":1,\"functions\":[\"java11-func-1\"],\"deprecated\":false,\"deprecation_date\":\"2026-06-30\",\"successor\":\"java21\"},"
+			expected += "{\"runtime\":\"java8\",\"count\":1,\"functions\":[\"java8-func-1\"],\"deprecated\":false,\"deprecation_date\":\"2024-01-08\",\"successor\":\"java8.al2\"},"
+			expected += "{\"runtime\":\"nodejs\",\"count\":2,\"functions\":[\"nodejs0.10-func-1\",\"nodejs0.10-func-2\"],\"deprecated\":true,\"deprecation_date\":\"2016-10-31\",\"successor\":\"nodejs22.x\"},"
+			expected += "{\"runtime\":\"nodejs10.x\",\"count\":1,\"functions\":[\"node10-func-1\"],\"deprecated\":false,\"deprecation_date\":\"2021-07-30\",\"successor\":\"nodejs22.x\"},"
+			expected += "{\"runtime\":\"nodejs12.x\",\"count\":1,\"functions\":[\"node12-func-1\"],\"deprecated\":false,\"deprecation_date\":\"2023-03-31\",\"successor\":\"nodejs22.x\"},"
+			expected += "{\"runtime\":\"nodejs4.3\",\"count\":1,\"functions\":[\"nodejs4.3-func-1\"],\"deprecated\":true,\"deprecation_date\":\"2020-03-05\",\"successor\":\"nodejs22.x\"},"
+			expected += "{\"runtime\":\"nodejs4.3-edge\",\"count\":1,\"functions\":[\"nodejs4.3edge-func-1\"],\"deprecated\":true,\"deprecation_date\":\"2020-04-30\",\"successor\":\"nodejs22.x\"},"
+			expected += "{\"runtime\":\"nodejs6.10\",\"count\":1,\"functions\":[\"nodejs6.10-func-1\"],\"deprecated\":true,\"deprecation_date\":\"2019-08-12\",\"successor\":\"nodejs22.x\"},"
+			expected += "{\"runtime\":\"nodejs8.10\",\"count\":1,\"functions\":[\"nodejs8.10-func-1\"],\"deprecated\":true,\"deprecation_date\":\"2020-03-06\",\"successor\":\"nodejs22.x\"},"
+			expected += "{\"runtime\":\"provided\",\"count\":6,\"functions\":[\"provided-func-1\",\"provided-func-2\",\"provided-func-3\",\"provided-func-4\",\"provided-func-5\",\"provided-func-6\"],\"deprecated\":false,\"deprecation_date\":\"2024-01-08\",\"successor\":\"provided.al2023\"},"
+			expected += "{\"runtime\":\"python3.6\",\"count\":1,\"functions\":[\"python3.6-func-1\"],\"deprecated\":false,\"deprecation_date\":\"2022-07-18\",\"successor\":\"python3.13\"},"
+			expected += "{\"runtime\":\"python3.7\",\"count\":1,\"functions\":[\"python3.7-func-1\"],\"deprecated\":false,\"deprecation_date\":\"2023-12-04\",\"successor\":\"python3.13\"},"
+			expected += "{\"runtime\":\"python3.8\",\"count\":3,\"functions\":[\"python3.8-func-1\",\"python3.8-func-2\",\"python3.8-func-3\"],\"deprecated\":false,\"deprecation_date\":\"2024-10-14\",\"successor\":\"python3.13\"},"
+			expected += "{\"runtime\":\"ruby2.5\",\"count\":1,\"functions\":[\"ruby2.5-func-1\"],\"deprecated\":false,\"deprecation_date\":\"2021-07-30\",\"successor\":\"ruby3.3\"},"
+			expected += "{\"runtime\":\"ruby2.7\",\"count\":1,\"functions\":[\"ruby2.7-func-1\"],\"deprecated\":false,\"deprecation_date\":\"2023-12-07\",\"successor\":\"ruby3.3\"}"
 			expected += "]"
 
 			cmd := stats.NewCmd()
@@ -317,3 +328,533 @@ func TestOutput(t *testing.T) {
 		lm.AssertNumberOfCalls(t, "ListFunctions", 2)
 	})
 }
+
+func TestRuntimeCatalog(t *testing.T) {
+	newMockClient := func() *stats.MockLambdaClient {
+		lm := &stats.MockLambdaClient{}
+		lm.On("ListFunctions", &lambda.ListFunctionsInput{Marker: nil, MaxItems: aws.Int64(1000)}).Return(
+			&lambda.ListFunctionsOutput{
+				Functions: []*lambda.FunctionConfiguration{
+					{Runtime: aws.String("python3.7"), FunctionName: aws.String("python3.7-func-1")},
+					{Runtime: aws.String("python3.8"), FunctionName: aws.String("python3.8-func-1")},
+					{Runtime: aws.String("python3.9"), FunctionName: aws.String("python3.9-func-1")},
+				},
+			},
+			nil,
+		)
+		stats.LambdaClient = lm
+		stats.Now = func() time.Time { return time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC) }
+		return lm
+	}
+
+	t.Run("warn within table format", func(t *testing.T) {
+		newMockClient()
+
+		expected := "|           RUNTIME           | COUNT |\n"
+		expected += "|-----------------------------|-------|\n"
+		expected += "| \x1b[91mpython3.7（Deprecated）\x1b[0m     |     1 |\n"
+		expected += "| \x1b[93mpython3.8（EOL:2024-10-14）\x1b[0m |     1 |\n"
+		expected += "| python3.9                   |     1 |\n"
+
+		cmd := stats.NewCmd()
+		cmd.Flags().Set("warn-within", "120d")
+		var args []string
+		data, err := stats.FetchData(cmd, args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual, err := stats.Output(data)
+		assert.Equal(t, expected, actual)
+		assert.Nil(t, err)
+	})
+
+	t.Run("warn within json format", func(t *testing.T) {
+		newMockClient()
+
+		expected := "["
+		expected += "{\"runtime\":\"python3.7\",\"count\":1,\"deprecated\":true,\"deprecation_date\":\"2023-12-04\",\"successor\":\"python3.13\"},"
+		expected += "{\"runtime\":\"python3.8\",\"count\":1,\"deprecated\":false,\"deprecation_date\":\"2024-10-14\",\"expiring\":true,\"successor\":\"python3.13\"},"
+		expected += "{\"runtime\":\"python3.9\",\"count\":1,\"deprecated\":false,\"deprecation_date\":\"2025-12-15\",\"successor\":\"python3.13\"}"
+		expected += "]"
+
+		cmd := stats.NewCmd()
+		cmd.Flags().Set("format", "json")
+		cmd.Flags().Set("warn-within", "120d")
+		var args []string
+		data, err := stats.FetchData(cmd, args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual, err := stats.Output(data)
+		assert.Equal(t, expected, actual)
+		assert.Nil(t, err)
+	})
+
+	t.Run("override catalog", func(t *testing.T) {
+		newMockClient()
+		f, err := ioutil.TempFile("", "runtime-catalog")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(f.Name())
+		f.WriteString(`[{"name": "python3.9", "deprecation": "2024-06-30", "successor": "python3.12"}]`)
+		f.Close()
+
+		expected := "["
+		expected += "{\"runtime\":\"python3.7\",\"count\":1,\"deprecated\":true,\"deprecation_date\":\"2023-12-04\",\"successor\":\"python3.13\"},"
+		expected += "{\"runtime\":\"python3.8\",\"count\":1,\"deprecated\":false,\"deprecation_date\":\"2024-10-14\",\"successor\":\"python3.13\"},"
+		expected += "{\"runtime\":\"python3.9\",\"count\":1,\"deprecated\":true,\"deprecation_date\":\"2024-06-30\",\"successor\":\"python3.12\"}"
+		expected += "]"
+
+		cmd := stats.NewCmd()
+		cmd.Flags().Set("format", "json")
+		cmd.Flags().Set("runtime-catalog", f.Name())
+		var args []string
+		data, err := stats.FetchData(cmd, args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual, err := stats.Output(data)
+		assert.Equal(t, expected, actual)
+		assert.Nil(t, err)
+		assert.Equal(t, "2024-06-30", stats.LookupRuntime("python3.9").Deprecation)
+	})
+
+	t.Run("built-in catalog", func(t *testing.T) {
+		assert.Equal(t, "dotnet10", stats.LookupRuntime("dotnet8").Successor)
+		for _, name := range []string{"nodejs24.x", "python3.14", "ruby3.4", "java25", "dotnet10"} {
+			assert.NotNil(t, stats.LookupRuntime(name), name)
+		}
+	})
+
+	t.Run("invalid catalog", func(t *testing.T) {
+		lm := newMockClient()
+		f, err := ioutil.TempFile("", "runtime-catalog")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(f.Name())
+		f.WriteString(`[{"name": "python3.9", "deprecation": "2024/06/30"}]`)
+		f.Close()
+
+		cmd := stats.NewCmd()
+		cmd.Flags().Set("runtime-catalog", f.Name())
+		var args []string
+		_, err = stats.FetchData(cmd, args)
+		assert.EqualError(t, err, "invalid date of python3.9 in runtime catalog: 2024/06/30")
+		lm.AssertNumberOfCalls(t, "ListFunctions", 0)
+	})
+
+	t.Run("invalid warn within", func(t *testing.T) {
+		lm := newMockClient()
+
+		cmd := stats.NewCmd()
+		cmd.Flags().Set("warn-within", "3 months")
+		var args []string
+		_, err := stats.FetchData(cmd, args)
+		assert.EqualError(t, err, "invalid duration: 3 months")
+		lm.AssertNumberOfCalls(t, "ListFunctions", 0)
+	})
+}
+
+func TestFetchTargets(t *testing.T) {
+	newClient := func(functions ...string) *stats.MockLambdaClient {
+		lm := &stats.MockLambdaClient{}
+		var configs []*lambda.FunctionConfiguration
+		for i := 0; i < len(functions); i += 2 {
+			configs = append(configs, &lambda.FunctionConfiguration{FunctionName: aws.String(functions[i]), Runtime: aws.String(functions[i+1])})
+		}
+		lm.On("ListFunctions", &lambda.ListFunctionsInput{Marker: nil, MaxItems: aws.Int64(1000)}).Return(
+			&lambda.ListFunctionsOutput{Functions: configs}, nil,
+		)
+		return lm
+	}
+	newProvider := func() *stats.MockProvider {
+		p := &stats.MockProvider{}
+		p.On("Account", "dev").Return("111111111111", nil)
+		p.On("Account", "prod").Return("222222222222", nil)
+		p.On("Account", "arn:aws:iam::333333333333:role/audit").Return("", errors.New("AccessDenied"))
+		p.On("DefaultRegion", "dev").Return("ap-northeast-1")
+		p.On("Regions", "dev").Return([]string{"ap-northeast-1", "us-east-1"}, nil)
+		p.On("LambdaClient", "dev", "ap-northeast-1").Return(newClient("dev-func-1", "python3.8", "dev-func-2", "go1.x"))
+		p.On("LambdaClient", "dev", "us-east-1").Return(newClient("dev-edge-1", "nodejs12.x"))
+		failing := &stats.MockLambdaClient{}
+		failing.On("ListFunctions", &lambda.ListFunctionsInput{Marker: nil, MaxItems: aws.Int64(1000)}).Return(nil, errors.New("UnrecognizedClientException"))
+		p.On("LambdaClient", "prod", "ap-northeast-1").Return(newClient("prod-func-1", "python3.8"))
+		p.On("LambdaClient", "prod", "us-east-1").Return(failing)
+		stats.TargetProvider = p
+		stats.Now = func() time.Time { return time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC) }
+		return p
+	}
+
+	t.Run("profiles and regions", func(t *testing.T) {
+		newProvider()
+
+		expected := "|   ACCOUNT    |     REGION     |  RUNTIME   | COUNT |\n"
+		expected += "|--------------|----------------|------------|-------|\n"
+		expected += "| 111111111111 | ap-northeast-1 | go1.x      |     1 |\n"
+		expected += "| 111111111111 | ap-northeast-1 | python3.8  |     1 |\n"
+		expected += "| 111111111111 | us-east-1      | nodejs12.x |     1 |\n"
+		expected += "| 222222222222 | ap-northeast-1 | python3.8  |     1 |\n"
+
+		cmd := stats.NewCmd()
+		cmd.Flags().Set("profiles", "dev,prod")
+		cmd.Flags().Set("regions", "ap-northeast-1,us-east-1")
+		results, err := stats.FetchTargets(cmd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual, err := stats.OutputTargets(results)
+		assert.Equal(t, expected, actual)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"failed to list functions of 222222222222/us-east-1: UnrecognizedClientException"}, stats.Errors(results))
+	})
+
+	t.Run("all regions and role arn", func(t *testing.T) {
+		p := newProvider()
+
+		expected := "["
+		expected += "{\"account\":\"111111111111\",\"region\":\"ap-northeast-1\",\"runtime\":\"go1.x\",\"count\":1,\"functions\":[\"dev-func-2\"],\"deprecated\":false,\"deprecation_date\":\"2024-01-08\",\"successor\":\"provided.al2023\"},"
+		expected += "{\"account\":\"111111111111\",\"region\":\"ap-northeast-1\",\"runtime\":\"python3.8\",\"count\":1,\"functions\":[\"dev-func-1\"],\"deprecated\":false,\"deprecation_date\":\"2024-10-14\",\"successor\":\"python3.13\"},"
+		expected += "{\"account\":\"111111111111\",\"region\":\"us-east-1\",\"runtime\":\"nodejs12.x\",\"count\":1,\"functions\":[\"dev-edge-1\"],\"deprecated\":false,\"deprecation_date\":\"2023-03-31\",\"successor\":\"nodejs22.x\"}"
+		expected += "]"
+
+		cmd := stats.NewCmd()
+		cmd.Flags().Set("format", "json")
+		cmd.Flags().Set("verbose", "true")
+		cmd.Flags().Set("profiles", "dev")
+		cmd.Flags().Set("role-arns", "arn:aws:iam::333333333333:role/audit")
+		cmd.Flags().Set("all-regions", "true")
+		results, err := stats.FetchTargets(cmd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual, err := stats.OutputTargets(results)
+		assert.Equal(t, expected, actual)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"failed to list functions of arn:aws:iam::333333333333:role/audit: AccessDenied"}, stats.Errors(results))
+		p.AssertNotCalled(t, "Regions", "arn:aws:iam::333333333333:role/audit")
+	})
+
+	t.Run("default region", func(t *testing.T) {
+		newProvider()
+
+		out, _, err := testutil.Execute(stats.NewCmd(), "--profiles", "dev")
+		assert.Nil(t, err)
+		assert.Contains(t, out, "| 111111111111 | ap-northeast-1 | go1.x     |     1 |\n")
+		assert.NotContains(t, out, "us-east-1")
+	})
+
+	t.Run("failed targets", func(t *testing.T) {
+		newProvider()
+
+		out, errOut, err := testutil.Execute(stats.NewCmd(), "--profiles", "prod", "--regions", "ap-northeast-1,us-east-1")
+		assert.EqualError(t, err, "failed to list functions of 1 targets")
+		assert.Contains(t, out, "| 222222222222 | ap-northeast-1 | python3.8 |     1 |\n")
+		assert.Equal(t, "failed to list functions of 222222222222/us-east-1: UnrecognizedClientException\n", errOut)
+	})
+}
+
+func TestGroupBy(t *testing.T) {
+	newMockClient := func() *stats.MockLambdaClient {
+		lm := &stats.MockLambdaClient{}
+		lm.On("ListFunctions", &lambda.ListFunctionsInput{Marker: nil, MaxItems: aws.Int64(1000)}).Return(
+			&lambda.ListFunctionsOutput{
+				Functions: []*lambda.FunctionConfiguration{
+					{
+						FunctionName:  aws.String("api"),
+						FunctionArn:   aws.String("arn:aws:lambda:ap-northeast-1:111111111111:function:api"),
+						Runtime:       aws.String("python3.8"),
+						Architectures: aws.StringSlice([]string{"arm64"}),
+						MemorySize:    aws.Int64(1024),
+						Timeout:       aws.Int64(30),
+						Layers:        []*lambda.Layer{{Arn: aws.String("arn:aws:lambda:ap-northeast-1:111111111111:layer:common:3")}, {Arn: aws.String("arn:aws:lambda:ap-northeast-1:111111111111:layer:otel:1")}},
+						VpcConfig:     &lambda.VpcConfigResponse{VpcId: aws.String("vpc-1234")},
+					},
+					{
+						FunctionName:  aws.String("worker"),
+						FunctionArn:   aws.String("arn:aws:lambda:ap-northeast-1:111111111111:function:worker"),
+						Runtime:       aws.String("python3.8"),
+						Architectures: aws.StringSlice([]string{"x86_64"}),
+						MemorySize:    aws.Int64(128),
+						Timeout:       aws.Int64(900),
+						Layers:        []*lambda.Layer{{Arn: aws.String("arn:aws:lambda:ap-northeast-1:111111111111:layer:common:3")}},
+						VpcConfig:     &lambda.VpcConfigResponse{VpcId: aws.String("")},
+					},
+					{
+						FunctionName: aws.String("cron"),
+						FunctionArn:  aws.String("arn:aws:lambda:ap-northeast-1:111111111111:function:cron"),
+						Runtime:      aws.String("go1.x"),
+						MemorySize:   aws.Int64(256),
+						Timeout:      aws.Int64(3),
+					},
+				},
+			},
+			nil,
+		)
+		lm.On("ListTags", &lambda.ListTagsInput{Resource: aws.String("arn:aws:lambda:ap-northeast-1:111111111111:function:api")}).Return(
+			&lambda.ListTagsOutput{Tags: aws.StringMap(map[string]string{"team": "web"})}, nil,
+		)
+		lm.On("ListTags", &lambda.ListTagsInput{Resource: aws.String("arn:aws:lambda:ap-northeast-1:111111111111:function:worker")}).Return(
+			&lambda.ListTagsOutput{Tags: aws.StringMap(map[string]string{"team": "batch"})}, nil,
+		)
+		lm.On("ListTags", &lambda.ListTagsInput{Resource: aws.String("arn:aws:lambda:ap-northeast-1:111111111111:function:cron")}).Return(
+			&lambda.ListTagsOutput{Tags: map[string]*string{}}, nil,
+		)
+		stats.LambdaClient = lm
+		stats.Now = func() time.Time { return time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC) }
+		return lm
+	}
+
+	t.Run("multiple keys table format", func(t *testing.T) {
+		lm := newMockClient()
+
+		expected := "|  RUNTIME  | ARCHITECTURE | MEMORY | TIMEOUT  | COUNT |\n"
+		expected += "|-----------|--------------|--------|----------|-------|\n"
+		expected += "| go1.x     | x86_64       |    256 | 0-3s     |     1 |\n"
+		expected += "| python3.8 | arm64        |   1024 | 4-30s    |     1 |\n"
+		expected += "| python3.8 | x86_64       |    128 | 301-900s |     1 |\n"
+
+		cmd := stats.NewCmd()
+		cmd.Flags().Set("group-by", "runtime,architecture,memory,timeout")
+		groups, err := stats.FetchGroups(cmd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual, err := stats.OutputGroups(groups)
+		assert.Equal(t, expected, actual)
+		assert.Nil(t, err)
+		lm.AssertNumberOfCalls(t, "ListTags", 0)
+	})
+
+	t.Run("layer, vpc and tag json format", func(t *testing.T) {
+		newMockClient()
+
+		expected := "["
+		expected += "{\"layer\":\"-\",\"vpc\":\"-\",\"tag:team\":\"-\",\"count\":1,\"functions\":[\"cron\"]},"
+		expected += "{\"layer\":\"arn:aws:lambda:ap-northeast-1:111111111111:layer:common:3\",\"vpc\":\"-\",\"tag:team\":\"batch\",\"count\":1,\"functions\":[\"worker\"]},"
+		expected += "{\"layer\":\"arn:aws:lambda:ap-northeast-1:111111111111:layer:common:3\",\"vpc\":\"vpc-1234\",\"tag:team\":\"web\",\"count\":1,\"functions\":[\"api\"]},"
+		expected += "{\"layer\":\"arn:aws:lambda:ap-northeast-1:111111111111:layer:otel:1\",\"vpc\":\"vpc-1234\",\"tag:team\":\"web\",\"count\":1,\"functions\":[\"api\"]}"
+		expected += "]"
+
+		cmd := stats.NewCmd()
+		cmd.Flags().Set("format", "json")
+		cmd.Flags().Set("verbose", "true")
+		cmd.Flags().Set("group-by", "layer,vpc,tag:team")
+		groups, err := stats.FetchGroups(cmd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual, err := stats.OutputGroups(groups)
+		assert.Equal(t, expected, actual)
+		assert.Nil(t, err)
+	})
+
+	t.Run("runtime json format", func(t *testing.T) {
+		newMockClient()
+
+		expected := "["
+		expected += "{\"package-type\":\"Zip\",\"runtime\":\"go1.x\",\"count\":1,\"deprecated\":false,\"deprecation_date\":\"2024-01-08\",\"successor\":\"provided.al2023\"},"
+		expected += "{\"package-type\":\"Zip\",\"runtime\":\"python3.8\",\"count\":2,\"deprecated\":false,\"deprecation_date\":\"2024-10-14\",\"successor\":\"python3.13\"}"
+		expected += "]"
+
+		cmd := stats.NewCmd()
+		cmd.Flags().Set("format", "json")
+		cmd.Flags().Set("group-by", "package-type,runtime")
+		groups, err := stats.FetchGroups(cmd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual, err := stats.OutputGroups(groups)
+		assert.Equal(t, expected, actual)
+		assert.Nil(t, err)
+	})
+
+	t.Run("invalid key", func(t *testing.T) {
+		lm := newMockClient()
+
+		cmd := stats.NewCmd()
+		cmd.Flags().Set("group-by", "runtime,owner")
+		_, err := stats.FetchGroups(cmd)
+		assert.EqualError(t, err, "invalid group key: owner (available: runtime, architecture, package-type, memory, timeout, layer, vpc, role, tag:<key>)")
+		lm.AssertNumberOfCalls(t, "ListFunctions", 0)
+	})
+}
+
+func TestContainerImage(t *testing.T) {
+	// failingPath makes download of the path fail.
+	var failingPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == failingPath {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		switch r.URL.Path {
+		case "/sha256:config-api":
+			w.Write([]byte(`{"architecture":"arm64","config":{"Labels":{"org.opencontainers.image.base.name":"public.ecr.aws/lambda/python:3.12"}}}`))
+		case "/sha256:config-worker":
+			w.Write([]byte(`{"architecture":"amd64","config":{"Labels":null}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	newMockClient := func() (*stats.MockLambdaClient, *stats.MockEcrClient) {
+		lm := &stats.MockLambdaClient{}
+		lm.On("ListFunctions", &lambda.ListFunctionsInput{Marker: nil, MaxItems: aws.Int64(1000)}).Return(
+			&lambda.ListFunctionsOutput{
+				Functions: []*lambda.FunctionConfiguration{
+					{FunctionName: aws.String("api"), PackageType: aws.String("Image"), Architectures: aws.StringSlice([]string{"arm64"})},
+					{FunctionName: aws.String("worker"), PackageType: aws.String("Image")},
+					{FunctionName: aws.String("cron"), PackageType: aws.String("Zip"), Runtime: aws.String("python3.12")},
+				},
+			},
+			nil,
+		)
+		lm.On("GetFunction", &lambda.GetFunctionInput{FunctionName: aws.String("api")}).Return(
+			&lambda.GetFunctionOutput{Code: &lambda.FunctionCodeLocation{
+				ImageUri:         aws.String("111111111111.dkr.ecr.ap-northeast-1.amazonaws.com/api:latest"),
+				ResolvedImageUri: aws.String("111111111111.dkr.ecr.ap-northeast-1.amazonaws.com/api@sha256:index-api"),
+			}}, nil,
+		)
+		lm.On("GetFunction", &lambda.GetFunctionInput{FunctionName: aws.String("worker")}).Return(
+			&lambda.GetFunctionOutput{Code: &lambda.FunctionCodeLocation{
+				ImageUri:         aws.String("111111111111.dkr.ecr.ap-northeast-1.amazonaws.com/worker:v1"),
+				ResolvedImageUri: aws.String("111111111111.dkr.ecr.ap-northeast-1.amazonaws.com/worker@sha256:manifest-worker"),
+			}}, nil,
+		)
+		stats.LambdaClient = lm
+
+		em := &stats.MockEcrClient{}
+		batchGetImage := func(repository string, digest string, manifest string) {
+			em.On("BatchGetImage", mock.MatchedBy(func(params *ecr.BatchGetImageInput) bool {
+				return aws.StringValue(params.RepositoryName) == repository && aws.StringValue(params.ImageIds[0].ImageDigest) == digest
+			})).Return(&ecr.BatchGetImageOutput{Images: []*ecr.Image{{ImageManifest: aws.String(manifest)}}}, nil)
+		}
+		batchGetImage("api", "sha256:index-api", `{"manifests":[{"digest":"sha256:manifest-api-amd64","platform":{"architecture":"amd64"}},{"digest":"sha256:manifest-api-arm64","platform":{"architecture":"arm64"}}]}`)
+		batchGetImage("api", "sha256:manifest-api-arm64", `{"config":{"digest":"sha256:config-api"}}`)
+		batchGetImage("worker", "sha256:manifest-worker", `{"config":{"digest":"sha256:config-worker"}}`)
+		for _, d := range []string{"sha256:config-api", "sha256:config-worker"} {
+			em.On("GetDownloadUrlForLayer", mock.MatchedBy(func(digest string) func(*ecr.GetDownloadUrlForLayerInput) bool {
+				return func(params *ecr.GetDownloadUrlForLayerInput) bool {
+					return aws.StringValue(params.LayerDigest) == digest
+				}
+			}(d))).Return(&ecr.GetDownloadUrlForLayerOutput{DownloadUrl: aws.String(server.URL + "/" + d)}, nil)
+		}
+		stats.EcrClient = em
+		stats.Now = func() time.Time { return time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC) }
+		return lm, em
+	}
+
+	t.Run("counted as container image", func(t *testing.T) {
+		newMockClient()
+
+		cmd := stats.NewCmd()
+		var args []string
+		data, err := stats.FetchData(cmd, args)
+		assert.Nil(t, err)
+		assert.Equal(t, map[string][]string{"container-image": {"api", "worker"}, "python3.12": {"cron"}}, data)
+	})
+
+	t.Run("details table format", func(t *testing.T) {
+		newMockClient()
+
+		expected := "|     RUNTIME     | COUNT |\n"
+		expected += "|-----------------|-------|\n"
+		expected += "| container-image |     2 |\n"
+		expected += "| python3.12      |     1 |\n"
+		expected += "\n"
+		expected += "| FUNCTION |                          IMAGE URI                           |         DIGEST         |            BASE IMAGE             |\n"
+		expected += "|----------|--------------------------------------------------------------|------------------------|-----------------------------------|\n"
+		expected += "| api      | 111111111111.dkr.ecr.ap-northeast-1.amazonaws.com/api:latest | sha256:index-api       | public.ecr.aws/lambda/python:3.12 |\n"
+		expected += "| worker   | 111111111111.dkr.ecr.ap-northeast-1.amazonaws.com/worker:v1  | sha256:manifest-worker | -                                 |\n"
+		expected += "\n"
+
+		out, _, err := testutil.Execute(stats.NewCmd(), "--details")
+		assert.Nil(t, err)
+		assert.Equal(t, expected, out)
+	})
+
+	t.Run("details json format", func(t *testing.T) {
+		lm, _ := newMockClient()
+
+		expected := "{\"stats\":["
+		expected += "{\"runtime\":\"container-image\",\"count\":2,\"deprecated\":false},"
+		expected += "{\"runtime\":\"python3.12\",\"count\":1,\"deprecated\":false,\"deprecation_date\":\"2028-10-31\",\"successor\":\"python3.13\"}"
+		expected += "],\"images\":["
+		expected += "{\"function\":\"api\",\"image_uri\":\"111111111111.dkr.ecr.ap-northeast-1.amazonaws.com/api:latest\",\"digest\":\"sha256:index-api\",\"base_image\":\"public.ecr.aws/lambda/python:3.12\"},"
+		expected += "{\"function\":\"worker\",\"image_uri\":\"111111111111.dkr.ecr.ap-northeast-1.amazonaws.com/worker:v1\",\"digest\":\"sha256:manifest-worker\",\"base_image\":\"-\"}"
+		expected += "]}\n"
+
+		out, _, err := testutil.Execute(stats.NewCmd(), "--details", "--format", "json")
+		assert.Nil(t, err)
+		assert.Equal(t, expected, out)
+		lm.AssertNotCalled(t, "GetFunction", &lambda.GetFunctionInput{FunctionName: aws.String("cron")})
+	})
+
+	t.Run("base image error", func(t *testing.T) {
+		newMockClient()
+		failingPath = "/sha256:config-api"
+		defer func() { failingPath = "" }()
+
+		cmd := stats.NewCmd()
+		cmd.Flags().Set("verbose", "true")
+		errOut := bytes.NewBufferString("")
+		cmd.SetErr(errOut)
+		functions, err := stats.ListFunctions(stats.LambdaClient)
+		assert.Nil(t, err)
+		details, err := stats.FetchImageDetails(cmd, functions)
+		assert.Nil(t, err)
+		assert.Equal(t, "unknown (error)", details[0].BaseImage)
+		assert.Equal(t, "-", details[1].BaseImage)
+		assert.Contains(t, errOut.String(), "failed to resolve base image of api: failed to download "+server.URL+"/sha256:config-api: 500 Internal Server Error\n")
+	})
+
+	t.Run("group by package type", func(t *testing.T) {
+		newMockClient()
+
+		cmd := stats.NewCmd()
+		cmd.Flags().Set("group-by", "package-type,runtime")
+		groups, err := stats.FetchGroups(cmd)
+		assert.Nil(t, err)
+		assert.Equal(t, []*stats.Group{
+			{Values: []string{"Image", "container-image"}, Functions: []string{"api", "worker"}},
+			{Values: []string{"Zip", "python3.12"}, Functions: []string{"cron"}},
+		}, groups)
+	})
+}
+
+func TestGetMetrics(t *testing.T) {
+	t.Run("batches of 500 queries", func(t *testing.T) {
+		cm := &stats.MockCloudWatchClient{}
+		cm.On("GetMetricData", mock.MatchedBy(func(params *cloudwatch.GetMetricDataInput) bool { return len(params.MetricDataQueries) == 500 })).Return(
+			&cloudwatch.GetMetricDataOutput{MetricDataResults: []*cloudwatch.MetricDataResult{
+				{Id: aws.String("q0"), Values: aws.Float64Slice([]float64{1, 2})},
+			}},
+			nil,
+		)
+		cm.On("GetMetricData", mock.MatchedBy(func(params *cloudwatch.GetMetricDataInput) bool { return len(params.MetricDataQueries) == 1 })).Return(
+			&cloudwatch.GetMetricDataOutput{MetricDataResults: []*cloudwatch.MetricDataResult{
+				{Id: aws.String("q500"), Values: aws.Float64Slice([]float64{5, 9, 7})},
+			}},
+			nil,
+		)
+		var queries []*stats.MetricQuery
+		for i := 0; i < 500; i++ {
+			queries = append(queries, &stats.MetricQuery{Function: "f", Metric: "Invocations", Stat: cloudwatch.StatisticSum})
+		}
+		queries = append(queries, &stats.MetricQuery{Function: "f", Resource: "f:live", Metric: "ProvisionedConcurrencyUtilization", Stat: cloudwatch.StatisticMaximum})
+
+		end := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+		values, err := stats.GetMetrics(cm, queries, end.Add(-time.Hour), end)
+		assert.Nil(t, err)
+		assert.Equal(t, 501, len(values))
+		assert.Equal(t, float64(3), values[0])
+		assert.Equal(t, float64(0), values[1])
+		assert.Equal(t, float64(9), values[500])
+		cm.AssertNumberOfCalls(t, "GetMetricData", 2)
+		params := cm.Calls[1].Arguments.Get(0).(*cloudwatch.GetMetricDataInput)
+		assert.Equal(t, "Resource", aws.StringValue(params.MetricDataQueries[0].MetricStat.Metric.Dimensions[1].Name))
+	})
+}
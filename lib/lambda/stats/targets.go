@@ -0,0 +1,257 @@
+package stats
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Blue-Pix/abc/lib/util"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/spf13/cobra"
+)
+
+// max number of targets to list functions at the same time.
+const maxConcurrentTargets = 8
+
+// Provider creates clients for each credential, which is a profile name, role arn,
+// or empty string for the default credential.
+type Provider interface {
+	Account(credential string) (string, error)
+	// Regions returns all regions enabled in the account.
+	Regions(credential string) ([]string, error)
+	DefaultRegion(credential string) string
+	LambdaClient(credential string, region string) lambdaiface.LambdaAPI
+}
+
+// TargetProvider is used with --regions, --all-regions, --profiles and --role-arns.
+var TargetProvider Provider
+
+// Target is an account and region pair to list functions.
+type Target struct {
+	Credential string
+	Account    string
+	Region     string
+}
+
+// TargetResult is functions of the target, or error occurred while listing them.
+type TargetResult struct {
+	*Target
+	Functions []*lambda.FunctionConfiguration
+	Tags      Tags
+	Err       error
+}
+
+// isMultiTarget returns whether functions are listed from multiple accounts or regions.
+func isMultiTarget() bool {
+	return len(regions) > 0 || allRegions || len(profiles) > 0 || len(roleArns) > 0
+}
+
+// FetchTargets lists functions of each account and region pair concurrently.
+// Errors of each target are stored in the result, not returned.
+func FetchTargets(cmd *cobra.Command) ([]*TargetResult, error) {
+	keys, err := validateGroupBy(groupBy)
+	if err != nil {
+		return nil, err
+	}
+	if err := loadCatalog(); err != nil {
+		return nil, err
+	}
+	initProvider(cmd)
+	targets, results := resolveTargets()
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+	)
+	sem := make(chan struct{}, maxConcurrentTargets)
+	for _, target := range targets {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(target *Target) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			client := TargetProvider.LambdaClient(target.Credential, target.Region)
+			result := &TargetResult{Target: target}
+			result.Functions, result.Err = ListFunctions(client)
+			if result.Err == nil && needsTags(keys) {
+				result.Tags, result.Err = listTags(client, result.Functions)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			results = append(results, result)
+		}(target)
+	}
+	wg.Wait()
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Account != results[j].Account {
+			return results[i].Account < results[j].Account
+		}
+		return results[i].Region < results[j].Region
+	})
+	return results, nil
+}
+
+func initProvider(cmd *cobra.Command) {
+	if TargetProvider == nil {
+		profile, _ := cmd.Flags().GetString("profile")
+		region, _ := cmd.Flags().GetString("region")
+		TargetProvider = &sessionProvider{profile: profile, region: region, sessions: make(map[string]*session.Session)}
+	}
+}
+
+// resolveTargets returns account and region pairs.
+// Credentials whose account or regions cannot be resolved are returned as failed results.
+func resolveTargets() ([]*Target, []*TargetResult) {
+	credentials := []string{""}
+	if len(profiles) > 0 || len(roleArns) > 0 {
+		credentials = append(append([]string{}, profiles...), roleArns...)
+	}
+	var targets []*Target
+	var failures []*TargetResult
+	for _, credential := range credentials {
+		account, err := TargetProvider.Account(credential)
+		if err != nil {
+			failures = append(failures, &TargetResult{Target: &Target{Credential: credential, Account: credentialName(credential)}, Err: err})
+			continue
+		}
+		names := regions
+		if allRegions {
+			if names, err = TargetProvider.Regions(credential); err != nil {
+				failures = append(failures, &TargetResult{Target: &Target{Credential: credential, Account: account}, Err: err})
+				continue
+			}
+		} else if len(names) == 0 {
+			names = []string{TargetProvider.DefaultRegion(credential)}
+		}
+		for _, region := range names {
+			targets = append(targets, &Target{Credential: credential, Account: account, Region: region})
+		}
+	}
+	return targets, failures
+}
+
+func credentialName(credential string) string {
+	if credential == "" {
+		return "default"
+	}
+	return credential
+}
+
+// Errors returns messages of failed targets.
+func Errors(results []*TargetResult) []string {
+	var messages []string
+	for _, r := range results {
+		if r.Err == nil {
+			continue
+		}
+		target := r.Account
+		if r.Region != "" {
+			target += "/" + r.Region
+		}
+		messages = append(messages, fmt.Sprintf("failed to list functions of %s: %s", target, r.Err))
+	}
+	return messages
+}
+
+// sessionProvider creates sessions from shared config with --profile and --region as base.
+// Roles are assumed with the base credential.
+type sessionProvider struct {
+	profile  string
+	region   string
+	mu       sync.Mutex
+	sessions map[string]*session.Session
+}
+
+func (p *sessionProvider) session(credential string) *session.Session {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if sess, ok := p.sessions[credential]; ok {
+		return sess
+	}
+	var sess *session.Session
+	switch {
+	case strings.HasPrefix(credential, "arn:"):
+		base := util.CreateSession(p.profile, p.region)
+		sess = base.Copy(&aws.Config{Credentials: stscreds.NewCredentials(base, credential)})
+	case credential != "":
+		sess = util.CreateSession(credential, p.region)
+	default:
+		sess = util.CreateSession(p.profile, p.region)
+	}
+	p.sessions[credential] = sess
+	return sess
+}
+
+func (p *sessionProvider) Account(credential string) (string, error) {
+	resp, err := sts.New(p.session(credential)).GetCallerIdentity(&sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", err
+	}
+	return aws.StringValue(resp.Account), nil
+}
+
+func (p *sessionProvider) Regions(credential string) ([]string, error) {
+	sess := p.session(credential)
+	config := &aws.Config{}
+	// DescribeRegions can be called in any region.
+	if aws.StringValue(sess.Config.Region) == "" {
+		config.Region = aws.String("us-east-1")
+	}
+	resp, err := ec2.New(sess, config).DescribeRegions(&ec2.DescribeRegionsInput{})
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, r := range resp.Regions {
+		names = append(names, aws.StringValue(r.RegionName))
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (p *sessionProvider) DefaultRegion(credential string) string {
+	return aws.StringValue(p.session(credential).Config.Region)
+}
+
+func (p *sessionProvider) LambdaClient(credential string, region string) lambdaiface.LambdaAPI {
+	return lambda.New(p.session(credential), &aws.Config{Region: aws.String(region)})
+}
+
+func countFunctions(results []*TargetResult) int {
+	n := 0
+	for _, r := range results {
+		n += len(r.Functions)
+	}
+	return n
+}
+
+// targetGroups groups functions of each target, with account and region as leading values.
+func targetGroups(results []*TargetResult, keys []string) []*Group {
+	var groups []*Group
+	for _, r := range results {
+		if r.Err != nil {
+			continue
+		}
+		for _, g := range groupFunctions(r.Functions, keys, r.Tags) {
+			g.Values = append([]string{r.Account, r.Region}, g.Values...)
+			groups = append(groups, g)
+		}
+	}
+	return groups
+}
+
+// OutputTargets is OutputGroups with account and region.
+func OutputTargets(results []*TargetResult) (string, error) {
+	keys, err := validateGroupBy(groupBy)
+	if err != nil {
+		return "", err
+	}
+	columns := append([]string{"account", "region"}, keys...)
+	return renderGroups(columns, targetGroups(results, keys))
+}
@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockLambdaClient struct {
+	mock.Mock
+	lambdaiface.LambdaAPI
+}
+
+func (client *MockLambdaClient) GetAccountSettings(params *lambda.GetAccountSettingsInput) (*lambda.GetAccountSettingsOutput, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*lambda.GetAccountSettingsOutput), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
+func (client *MockLambdaClient) ListFunctions(params *lambda.ListFunctionsInput) (*lambda.ListFunctionsOutput, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*lambda.ListFunctionsOutput), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
+func (client *MockLambdaClient) ListVersionsByFunction(params *lambda.ListVersionsByFunctionInput) (*lambda.ListVersionsByFunctionOutput, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*lambda.ListVersionsByFunctionOutput), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
+const mb = 1024 * 1024
+
+func SetMockDefaultBehaviour(lm *MockLambdaClient) {
+	lm.On("GetAccountSettings", &lambda.GetAccountSettingsInput{}).Return(
+		&lambda.GetAccountSettingsOutput{
+			AccountLimit: &lambda.AccountLimit{TotalCodeSize: aws.Int64(75 * 1024 * mb)},
+			AccountUsage: &lambda.AccountUsage{TotalCodeSize: aws.Int64(200 * mb)},
+		},
+		nil,
+	)
+	lm.On("ListFunctions", &lambda.ListFunctionsInput{MaxItems: aws.Int64(1000)}).Return(
+		&lambda.ListFunctionsOutput{
+			Functions: []*lambda.FunctionConfiguration{
+				{FunctionName: aws.String("api")},
+				{FunctionName: aws.String("worker")},
+			},
+		},
+		nil,
+	)
+	common := &lambda.Layer{Arn: aws.String("arn:aws:lambda:ap-northeast-1:123456789012:layer:common:2"), CodeSize: aws.Int64(10 * mb)}
+	lm.On("ListVersionsByFunction", &lambda.ListVersionsByFunctionInput{FunctionName: aws.String("api"), MaxItems: aws.Int64(50)}).Return(
+		&lambda.ListVersionsByFunctionOutput{
+			NextMarker: aws.String("next_marker"),
+			Versions: []*lambda.FunctionConfiguration{
+				{Version: aws.String("$LATEST"), CodeSize: aws.Int64(20 * mb), Layers: []*lambda.Layer{common}},
+				{Version: aws.String("1"), CodeSize: aws.Int64(18 * mb), Layers: []*lambda.Layer{
+					{Arn: aws.String("arn:aws:lambda:ap-northeast-1:123456789012:layer:common:1"), CodeSize: aws.Int64(8 * mb)},
+				}},
+			},
+		},
+		nil,
+	)
+	lm.On("ListVersionsByFunction", &lambda.ListVersionsByFunctionInput{FunctionName: aws.String("api"), MaxItems: aws.Int64(50), Marker: aws.String("next_marker")}).Return(
+		&lambda.ListVersionsByFunctionOutput{
+			Versions: []*lambda.FunctionConfiguration{
+				{Version: aws.String("2"), CodeSize: aws.Int64(20 * mb), Layers: []*lambda.Layer{common}},
+			},
+		},
+		nil,
+	)
+	lm.On("ListVersionsByFunction", &lambda.ListVersionsByFunctionInput{FunctionName: aws.String("worker"), MaxItems: aws.Int64(50)}).Return(
+		&lambda.ListVersionsByFunctionOutput{
+			Versions: []*lambda.FunctionConfiguration{
+				{Version: aws.String("$LATEST"), CodeSize: aws.Int64(5 * mb), Layers: []*lambda.Layer{common}},
+			},
+		},
+		nil,
+	)
+}
@@ -0,0 +1,213 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Blue-Pix/abc/lib/lambda/stats"
+	"github.com/Blue-Pix/abc/lib/util"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+var LambdaClient lambdaiface.LambdaAPI
+
+// warnPercent is the usage of the quota to suggest pruning versions.
+const warnPercent = 80
+
+var format string
+
+func NewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "storage",
+		Short: "Show code storage of Lambda functions against the quota",
+		Long: `
+[abc lambda storage]
+This command sums code size of all published versions of each function,
+and layer versions used by them, and compares the total with the code storage quota of the account.
+Layer versions used by multiple functions are counted once in the total.
+Functions are sorted by total size in descending order.
+
+Internally it uses aws lambda api.
+Please configure your aws credentials with following policies.
+- lambda:GetAccountSettings
+- lambda:ListFunctions
+- lambda:ListVersionsByFunction`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := run(cmd, args)
+			return err
+		},
+	}
+	cmd.Flags().StringVarP(&format, "format", "f", "table", "output format (table or json)")
+	return cmd
+}
+
+func run(cmd *cobra.Command, args []string) error {
+	report, err := FetchData(cmd, args)
+	if err != nil {
+		return err
+	}
+	str, err := Output(report)
+	if err != nil {
+		return err
+	}
+	cmd.Println(str)
+	return nil
+}
+
+// FunctionStorage is code size of a function.
+type FunctionStorage struct {
+	Function string `json:"function"`
+	Versions int    `json:"versions"`
+	// CodeSize is the sum of code size of $LATEST and all published versions.
+	CodeSize int64 `json:"code_size"`
+	// LayerSize is the sum of code size of layer versions used by any version of the function.
+	LayerSize int64 `json:"layer_size"`
+	Total     int64 `json:"total"`
+}
+
+// Report is code storage of the account.
+type Report struct {
+	Functions        []*FunctionStorage `json:"functions"`
+	FunctionCodeSize int64              `json:"function_code_size"`
+	// LayerCodeSize is the sum of layer versions used by functions, without duplicates.
+	LayerCodeSize int64 `json:"layer_code_size"`
+	Total         int64 `json:"total"`
+	Quota         int64 `json:"quota"`
+	// Usage is the total code size reported by Lambda, which includes layers not used by any function.
+	Usage int64 `json:"usage"`
+}
+
+func FetchData(cmd *cobra.Command, args []string) (*Report, error) {
+	initClient(cmd)
+	settings, err := LambdaClient.GetAccountSettings(&lambda.GetAccountSettingsInput{})
+	if err != nil {
+		return nil, err
+	}
+	functions, err := stats.ListFunctions(LambdaClient)
+	if err != nil {
+		return nil, err
+	}
+	report := &Report{Functions: []*FunctionStorage{}}
+	if settings.AccountLimit != nil {
+		report.Quota = aws.Int64Value(settings.AccountLimit.TotalCodeSize)
+	}
+	if settings.AccountUsage != nil {
+		report.Usage = aws.Int64Value(settings.AccountUsage.TotalCodeSize)
+	}
+	layers := make(map[string]int64)
+	for _, f := range functions {
+		versions, err := listVersions(f.FunctionName)
+		if err != nil {
+			return nil, err
+		}
+		s := &FunctionStorage{Function: aws.StringValue(f.FunctionName)}
+		used := make(map[string]int64)
+		for _, v := range versions {
+			if aws.StringValue(v.Version) != "$LATEST" {
+				s.Versions++
+			}
+			s.CodeSize += aws.Int64Value(v.CodeSize)
+			for _, l := range v.Layers {
+				used[aws.StringValue(l.Arn)] = aws.Int64Value(l.CodeSize)
+			}
+		}
+		for arn, size := range used {
+			s.LayerSize += size
+			layers[arn] = size
+		}
+		s.Total = s.CodeSize + s.LayerSize
+		report.FunctionCodeSize += s.CodeSize
+		report.Functions = append(report.Functions, s)
+	}
+	for _, size := range layers {
+		report.LayerCodeSize += size
+	}
+	report.Total = report.FunctionCodeSize + report.LayerCodeSize
+	sort.SliceStable(report.Functions, func(i, j int) bool {
+		return report.Functions[i].Total > report.Functions[j].Total
+	})
+	return report, nil
+}
+
+func initClient(cmd *cobra.Command) {
+	profile, _ := cmd.Flags().GetString("profile")
+	region, _ := cmd.Flags().GetString("region")
+	if LambdaClient == nil {
+		sess := util.CreateSession(profile, region)
+		LambdaClient = lambda.New(sess)
+	}
+}
+
+func listVersions(name *string) ([]*lambda.FunctionConfiguration, error) {
+	var result []*lambda.FunctionConfiguration
+	var nextMarker *string
+	for {
+		params := &lambda.ListVersionsByFunctionInput{
+			FunctionName: name,
+			MaxItems:     aws.Int64(50),
+			Marker:       nextMarker,
+		}
+		resp, err := LambdaClient.ListVersionsByFunction(params)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, resp.Versions...)
+		if resp.NextMarker == nil {
+			break
+		}
+		nextMarker = resp.NextMarker
+	}
+	return result, nil
+}
+
+// Percent returns the total against the quota.
+func (r *Report) Percent() float64 {
+	if r.Quota == 0 {
+		return 0
+	}
+	return float64(r.Total) * 100 / float64(r.Quota)
+}
+
+func Output(report *Report) (string, error) {
+	if format == "json" {
+		jsonBytes, err := json.Marshal(report)
+		return string(jsonBytes), err
+	} else if format == "table" {
+		return tableOutput(report), nil
+	}
+	return "", errors.New("invalid format.")
+}
+
+func tableOutput(report *Report) string {
+	tableString := &strings.Builder{}
+	table := tablewriter.NewWriter(tableString)
+	table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
+	table.SetCenterSeparator("|")
+	table.SetHeader([]string{"Function", "Versions", "Code Size", "Layer Size", "Total"})
+	table.SetColumnAlignment([]int{tablewriter.ALIGN_LEFT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT})
+	for _, f := range report.Functions {
+		table.Append([]string{
+			f.Function,
+			strconv.Itoa(f.Versions),
+			util.FormatBytes(f.CodeSize),
+			util.FormatBytes(f.LayerSize),
+			util.FormatBytes(f.Total),
+		})
+	}
+	table.Render()
+	fmt.Fprintf(tableString, "\nFunctions: %s, Layers: %s\n", util.FormatBytes(report.FunctionCodeSize), util.FormatBytes(report.LayerCodeSize))
+	fmt.Fprintf(tableString, "Total: %s / %s (%.1f%%)\n", util.FormatBytes(report.Total), util.FormatBytes(report.Quota), report.Percent())
+	fmt.Fprintf(tableString, "Usage reported by Lambda: %s", util.FormatBytes(report.Usage))
+	if report.Percent() >= warnPercent {
+		fmt.Fprintf(tableString, "\nOver %d%% of the quota is used. Old versions can be deleted with abc lambda prune-versions.", warnPercent)
+	}
+	return tableString.String()
+}
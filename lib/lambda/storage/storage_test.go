@@ -0,0 +1,126 @@
+package storage_test
+
+import (
+	"testing"
+
+	"github.com/Blue-Pix/abc/lib/lambda/storage"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/stretchr/testify/assert"
+)
+
+const mb = 1024 * 1024
+
+func initMockClient(lm *storage.MockLambdaClient) {
+	storage.SetMockDefaultBehaviour(lm)
+	storage.LambdaClient = lm
+}
+
+func TestFetchData(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		lm := &storage.MockLambdaClient{}
+		initMockClient(lm)
+
+		cmd := storage.NewCmd()
+		var args []string
+		actual, err := storage.FetchData(cmd, args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := &storage.Report{
+			Functions: []*storage.FunctionStorage{
+				{Function: "api", Versions: 2, CodeSize: 58 * mb, LayerSize: 18 * mb, Total: 76 * mb},
+				{Function: "worker", Versions: 0, CodeSize: 5 * mb, LayerSize: 10 * mb, Total: 15 * mb},
+			},
+			FunctionCodeSize: 63 * mb,
+			LayerCodeSize:    18 * mb,
+			Total:            81 * mb,
+			Quota:            75 * 1024 * mb,
+			Usage:            200 * mb,
+		}
+		assert.Equal(t, expected, actual)
+		lm.AssertNumberOfCalls(t, "ListVersionsByFunction", 3)
+	})
+}
+
+func TestOutput(t *testing.T) {
+	t.Run("table format", func(t *testing.T) {
+		lm := &storage.MockLambdaClient{}
+		initMockClient(lm)
+
+		expected := "| FUNCTION | VERSIONS | CODE SIZE | LAYER SIZE |  TOTAL  |\n"
+		expected += "|----------|----------|-----------|------------|---------|\n"
+		expected += "| api      |        2 |   58.0 MB |    18.0 MB | 76.0 MB |\n"
+		expected += "| worker   |        0 |    5.0 MB |    10.0 MB | 15.0 MB |\n"
+		expected += "\n"
+		expected += "Functions: 63.0 MB, Layers: 18.0 MB\n"
+		expected += "Total: 81.0 MB / 75.0 GB (0.1%)\n"
+		expected += "Usage reported by Lambda: 200.0 MB"
+
+		cmd := storage.NewCmd()
+		var args []string
+		report, err := storage.FetchData(cmd, args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual, err := storage.Output(report)
+		assert.Equal(t, expected, actual)
+		assert.Nil(t, err)
+	})
+
+	t.Run("near the quota", func(t *testing.T) {
+		lm := &storage.MockLambdaClient{}
+		lm.On("GetAccountSettings", &lambda.GetAccountSettingsInput{}).Return(
+			&lambda.GetAccountSettingsOutput{AccountLimit: &lambda.AccountLimit{TotalCodeSize: aws.Int64(100 * mb)}},
+			nil,
+		)
+		initMockClient(lm)
+
+		cmd := storage.NewCmd()
+		var args []string
+		report, err := storage.FetchData(cmd, args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual, err := storage.Output(report)
+		assert.Nil(t, err)
+		assert.Contains(t, actual, "Total: 81.0 MB / 100.0 MB (81.0%)\n")
+		assert.Contains(t, actual, "\nOver 80% of the quota is used. Old versions can be deleted with abc lambda prune-versions.")
+	})
+
+	t.Run("json format", func(t *testing.T) {
+		lm := &storage.MockLambdaClient{}
+		initMockClient(lm)
+
+		expected := "{\"functions\":["
+		expected += "{\"function\":\"api\",\"versions\":2,\"code_size\":60817408,\"layer_size\":18874368,\"total\":79691776},"
+		expected += "{\"function\":\"worker\",\"versions\":0,\"code_size\":5242880,\"layer_size\":10485760,\"total\":15728640}"
+		expected += "],\"function_code_size\":66060288,\"layer_code_size\":18874368,\"total\":84934656,\"quota\":80530636800,\"usage\":209715200}"
+
+		cmd := storage.NewCmd()
+		cmd.Flags().Set("format", "json")
+		var args []string
+		report, err := storage.FetchData(cmd, args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual, err := storage.Output(report)
+		assert.Equal(t, expected, actual)
+		assert.Nil(t, err)
+	})
+
+	t.Run("invalid format", func(t *testing.T) {
+		lm := &storage.MockLambdaClient{}
+		initMockClient(lm)
+
+		cmd := storage.NewCmd()
+		cmd.Flags().Set("format", "csv")
+		var args []string
+		report, err := storage.FetchData(cmd, args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = storage.Output(report)
+		assert.EqualError(t, err, "invalid format.")
+	})
+}
@@ -0,0 +1,105 @@
+package triggers
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockLambdaClient struct {
+	mock.Mock
+	lambdaiface.LambdaAPI
+}
+
+type MockCloudWatchClient struct {
+	mock.Mock
+	cloudwatchiface.CloudWatchAPI
+}
+
+func (client *MockLambdaClient) ListFunctions(params *lambda.ListFunctionsInput) (*lambda.ListFunctionsOutput, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*lambda.ListFunctionsOutput), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
+func (client *MockLambdaClient) ListEventSourceMappings(params *lambda.ListEventSourceMappingsInput) (*lambda.ListEventSourceMappingsOutput, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*lambda.ListEventSourceMappingsOutput), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
+func (client *MockCloudWatchClient) GetMetricData(params *cloudwatch.GetMetricDataInput) (*cloudwatch.GetMetricDataOutput, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*cloudwatch.GetMetricDataOutput), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
+func metricResult(id string, values ...float64) *cloudwatch.MetricDataResult {
+	return &cloudwatch.MetricDataResult{Id: aws.String(id), Values: aws.Float64Slice(values)}
+}
+
+func mapping(uuid string, function string, source string, state string, batchSize int64, result string) *lambda.EventSourceMappingConfiguration {
+	return &lambda.EventSourceMappingConfiguration{
+		UUID:                 aws.String(uuid),
+		FunctionArn:          aws.String("arn:aws:lambda:ap-northeast-1:123456789012:function:" + function),
+		EventSourceArn:       aws.String(source),
+		State:                aws.String(state),
+		BatchSize:            aws.Int64(batchSize),
+		LastProcessingResult: aws.String(result),
+	}
+}
+
+func SetMockDefaultBehaviour(lm *MockLambdaClient, cm *MockCloudWatchClient) {
+	lm.On("ListEventSourceMappings", &lambda.ListEventSourceMappingsInput{MaxItems: aws.Int64(100)}).Return(
+		&lambda.ListEventSourceMappingsOutput{
+			EventSourceMappings: []*lambda.EventSourceMappingConfiguration{
+				mapping("1", "orders-worker", "arn:aws:sqs:ap-northeast-1:123456789012:orders", "Enabled", 10, "No records processed"),
+				mapping("2", "clicks-consumer:live", "arn:aws:kinesis:ap-northeast-1:123456789012:stream/clicks", "Enabled", 100, "OK"),
+			},
+			NextMarker: aws.String("next"),
+		},
+		nil,
+	)
+	lm.On("ListEventSourceMappings", &lambda.ListEventSourceMappingsInput{MaxItems: aws.Int64(100), Marker: aws.String("next")}).Return(
+		&lambda.ListEventSourceMappingsOutput{
+			EventSourceMappings: []*lambda.EventSourceMappingConfiguration{
+				mapping("3", "audit", "arn:aws:dynamodb:ap-northeast-1:123456789012:table/users/stream/2024-01-01T00:00:00.000", "Enabled", 100, "PROBLEM: Function call failed"),
+				mapping("4", "audit", "arn:aws:kafka:ap-northeast-1:123456789012:cluster/events/abcd-1234", "Disabled", 500, ""),
+			},
+		},
+		nil,
+	)
+	lm.On("ListFunctions", &lambda.ListFunctionsInput{MaxItems: aws.Int64(1000)}).Return(
+		&lambda.ListFunctionsOutput{
+			Functions: []*lambda.FunctionConfiguration{
+				{FunctionName: aws.String("orders-worker"), Runtime: aws.String("nodejs12.x")},
+				{FunctionName: aws.String("clicks-consumer"), Runtime: aws.String("python3.12")},
+				{FunctionName: aws.String("audit"), Runtime: aws.String("python3.12")},
+			},
+		},
+		nil,
+	)
+
+	// q0-q1: IteratorAge of clicks-consumer and audit, in milliseconds.
+	cm.On("GetMetricData", mock.AnythingOfType("*cloudwatch.GetMetricDataInput")).Return(
+		&cloudwatch.GetMetricDataOutput{
+			MetricDataResults: []*cloudwatch.MetricDataResult{
+				metricResult("q0", 120000, 900000),
+				metricResult("q1", 1500),
+			},
+		},
+		nil,
+	)
+}
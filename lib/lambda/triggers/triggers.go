@@ -0,0 +1,320 @@
+package triggers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Blue-Pix/abc/lib/lambda/stats"
+	"github.com/Blue-Pix/abc/lib/util"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+var LambdaClient lambdaiface.LambdaAPI
+var CloudWatchClient cloudwatchiface.CloudWatchAPI
+
+// maxDays is the retention of CloudWatch metrics (15 months).
+const maxDays = 455
+
+// processingResultOK and processingResultNoRecords are LastProcessingResult of healthy mappings.
+const (
+	processingResultOK        = "OK"
+	processingResultNoRecords = "No records processed"
+)
+
+// streamSources are event sources which have IteratorAge metric.
+var streamSources = map[string]bool{"kinesis": true, "dynamodb": true}
+
+var (
+	format         string
+	days           int
+	maxIteratorAge time.Duration
+	catalogFile    string
+)
+
+func NewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "triggers",
+		Short: "List event source mappings of Lambda functions with their health",
+		Long: `
+[abc lambda triggers]
+This command lists all event source mappings (SQS, Kinesis, DynamoDB Streams, MSK and others)
+with state, batch size and last processing result.
+For Kinesis and DynamoDB Streams, the max IteratorAge metric of the function in the last --days days is added.
+IteratorAge is published per function, not per mapping, so it is the worst of all streams
+when a function has multiple stream mappings.
+
+Warnings are shown for mappings which are
+- disabled
+- failing, whose last processing result is neither OK nor "No records processed"
+- lagging, whose function iterator age is over --max-iterator-age
+- targeting a function with a deprecated runtime in the runtime catalog of abc lambda stats
+
+Internally it uses aws lambda api and cloudwatch api.
+Please configure your aws credentials with following policies.
+- lambda:ListEventSourceMappings
+- lambda:ListFunctions
+- cloudwatch:GetMetricData`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := run(cmd, args)
+			return err
+		},
+	}
+	cmd.Flags().StringVarP(&format, "format", "f", "table", "output format (table or json)")
+	cmd.Flags().IntVar(&days, "days", 1, "number of days to look back for iterator age")
+	cmd.Flags().DurationVar(&maxIteratorAge, "max-iterator-age", 5*time.Minute, "(optional) iterator age over which stream mappings are lagging")
+	cmd.Flags().StringVar(&catalogFile, "runtime-catalog", "", "(optional) JSON file to add or overwrite runtimes of the catalog")
+	return cmd
+}
+
+func run(cmd *cobra.Command, args []string) error {
+	mappings, err := FetchData(cmd, args)
+	if err != nil {
+		return err
+	}
+	if len(mappings) == 0 && format == "table" {
+		cmd.Println("no event source mapping found.")
+		return nil
+	}
+	str, err := Output(mappings)
+	if err != nil {
+		return err
+	}
+	cmd.Println(str)
+	return nil
+}
+
+// Mapping is an event source mapping with its health.
+type Mapping struct {
+	UUID     string `json:"uuid"`
+	Function string `json:"function"`
+	Runtime  string `json:"runtime"`
+	// Source is the type of event source, like sqs, kinesis, dynamodb and kafka.
+	Source               string `json:"source"`
+	EventSourceArn       string `json:"event_source_arn"`
+	State                string `json:"state"`
+	BatchSize            int64  `json:"batch_size"`
+	LastProcessingResult string `json:"last_processing_result"`
+	// FunctionIteratorAge is the max IteratorAge of the function in milliseconds, and nil for sources other than streams.
+	// It is shared by all stream mappings of the function, because the metric has no dimension of mappings.
+	FunctionIteratorAge *int64   `json:"function_iterator_age"`
+	Warnings            []string `json:"warnings"`
+}
+
+// FetchData returns event source mappings sorted by function and source.
+func FetchData(cmd *cobra.Command, args []string) ([]*Mapping, error) {
+	if days < 1 || days > maxDays {
+		return nil, fmt.Errorf("--days must be between 1 and %d", maxDays)
+	}
+	if catalogFile != "" {
+		if err := stats.LoadRuntimeCatalog(catalogFile); err != nil {
+			return nil, err
+		}
+	}
+	initClient(cmd)
+	items, err := listEventSourceMappings()
+	if err != nil {
+		return nil, err
+	}
+	functions, err := stats.ListFunctions(LambdaClient)
+	if err != nil {
+		return nil, err
+	}
+	runtimes := make(map[string]string)
+	for _, f := range functions {
+		runtimes[aws.StringValue(f.FunctionName)] = stats.RuntimeOf(f)
+	}
+	result := []*Mapping{}
+	var queries []*stats.MetricQuery
+	var streams []*Mapping
+	// queryIndex is the index of the IteratorAge query of each function.
+	queryIndex := make(map[string]int)
+	for _, item := range items {
+		m := &Mapping{
+			UUID:                 aws.StringValue(item.UUID),
+			Function:             functionName(aws.StringValue(item.FunctionArn)),
+			Source:               sourceType(item),
+			EventSourceArn:       aws.StringValue(item.EventSourceArn),
+			State:                aws.StringValue(item.State),
+			BatchSize:            aws.Int64Value(item.BatchSize),
+			LastProcessingResult: aws.StringValue(item.LastProcessingResult),
+			Warnings:             []string{},
+		}
+		m.Runtime = runtimes[m.Function]
+		if streamSources[m.Source] {
+			streams = append(streams, m)
+			if _, ok := queryIndex[m.Function]; !ok {
+				queryIndex[m.Function] = len(queries)
+				queries = append(queries, &stats.MetricQuery{Function: m.Function, Metric: "IteratorAge", Stat: cloudwatch.StatisticMaximum})
+			}
+		}
+		result = append(result, m)
+	}
+	if len(queries) > 0 {
+		end := stats.Now()
+		start := end.Add(-time.Duration(days) * 24 * time.Hour)
+		values, err := stats.GetMetrics(CloudWatchClient, queries, start, end)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range streams {
+			m.FunctionIteratorAge = aws.Int64(int64(values[queryIndex[m.Function]]))
+		}
+	}
+	for _, m := range result {
+		m.Warnings = warnings(m)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Function != result[j].Function {
+			return result[i].Function < result[j].Function
+		}
+		return result[i].EventSourceArn < result[j].EventSourceArn
+	})
+	return result, nil
+}
+
+func initClient(cmd *cobra.Command) {
+	profile, _ := cmd.Flags().GetString("profile")
+	region, _ := cmd.Flags().GetString("region")
+	if LambdaClient == nil {
+		sess := util.CreateSession(profile, region)
+		LambdaClient = lambda.New(sess)
+	}
+	if CloudWatchClient == nil {
+		sess := util.CreateSession(profile, region)
+		CloudWatchClient = cloudwatch.New(sess)
+	}
+}
+
+func listEventSourceMappings() ([]*lambda.EventSourceMappingConfiguration, error) {
+	var result []*lambda.EventSourceMappingConfiguration
+	var nextMarker *string
+	for {
+		params := &lambda.ListEventSourceMappingsInput{
+			MaxItems: aws.Int64(100),
+			Marker:   nextMarker,
+		}
+		resp, err := LambdaClient.ListEventSourceMappings(params)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, resp.EventSourceMappings...)
+		if resp.NextMarker == nil {
+			break
+		}
+		nextMarker = resp.NextMarker
+	}
+	return result, nil
+}
+
+// functionName returns the name of the function from the arn, which may be qualified with an alias or version.
+func functionName(arn string) string {
+	parts := strings.Split(arn, ":")
+	if len(parts) < 7 {
+		return arn
+	}
+	return parts[6]
+}
+
+// sourceType returns the service of the event source arn, or kafka for self-managed Apache Kafka.
+func sourceType(item *lambda.EventSourceMappingConfiguration) string {
+	if item.SelfManagedEventSource != nil {
+		return "kafka"
+	}
+	parts := strings.Split(aws.StringValue(item.EventSourceArn), ":")
+	if len(parts) < 3 {
+		return "-"
+	}
+	return parts[2]
+}
+
+func warnings(m *Mapping) []string {
+	result := []string{}
+	if m.State == "Disabled" {
+		result = append(result, "disabled")
+	}
+	if m.LastProcessingResult != "" && m.LastProcessingResult != processingResultOK && m.LastProcessingResult != processingResultNoRecords {
+		result = append(result, fmt.Sprintf("failing (%s)", m.LastProcessingResult))
+	}
+	if m.FunctionIteratorAge != nil && iteratorAge(m) > maxIteratorAge {
+		result = append(result, fmt.Sprintf("function lagging (iterator age %s)", iteratorAge(m)))
+	}
+	if r := stats.LookupRuntime(m.Runtime); r != nil && r.IsDeprecated(stats.Now()) {
+		result = append(result, fmt.Sprintf("deprecated runtime %s", m.Runtime))
+	}
+	return result
+}
+
+// iteratorAge returns IteratorAge of the function of the mapping rounded to seconds.
+func iteratorAge(m *Mapping) time.Duration {
+	return (time.Duration(aws.Int64Value(m.FunctionIteratorAge)) * time.Millisecond).Round(time.Second)
+}
+
+func Output(mappings []*Mapping) (string, error) {
+	if format == "json" {
+		jsonBytes, err := json.Marshal(mappings)
+		return string(jsonBytes), err
+	} else if format == "table" {
+		return tableOutput(mappings), nil
+	}
+	return "", errors.New("invalid format.")
+}
+
+func tableOutput(mappings []*Mapping) string {
+	tableString := &strings.Builder{}
+	table := tablewriter.NewWriter(tableString)
+	table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
+	table.SetCenterSeparator("|")
+	table.SetAutoWrapText(false)
+	table.SetHeader([]string{"Function", "Source", "Resource", "State", "Batch Size", "Last Result", "Function Iterator Age", "Warning"})
+	table.SetColumnAlignment([]int{tablewriter.ALIGN_LEFT, tablewriter.ALIGN_LEFT, tablewriter.ALIGN_LEFT, tablewriter.ALIGN_LEFT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_LEFT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_LEFT})
+	for _, m := range mappings {
+		age := "-"
+		if m.FunctionIteratorAge != nil {
+			age = iteratorAge(m).String()
+		}
+		table.Append([]string{
+			m.Function,
+			m.Source,
+			resourceName(m.EventSourceArn),
+			m.State,
+			strconv.FormatInt(m.BatchSize, 10),
+			orNoValue(m.LastProcessingResult),
+			age,
+			orNoValue(strings.Join(m.Warnings, ", ")),
+		})
+	}
+	table.Render()
+	return tableString.String()
+}
+
+// resourceName returns the name of the queue, stream, table or cluster from the arn.
+// e.g. arn:aws:dynamodb:<region>:<account>:table/orders/stream/<label> returns orders.
+func resourceName(arn string) string {
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) < 6 {
+		return orNoValue(arn)
+	}
+	resource := strings.Split(parts[5], "/")
+	if len(resource) > 1 {
+		return resource[1]
+	}
+	return resource[0]
+}
+
+func orNoValue(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,120 @@
+package triggers_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Blue-Pix/abc/lib/lambda/stats"
+	"github.com/Blue-Pix/abc/lib/lambda/triggers"
+	"github.com/Blue-Pix/abc/lib/util/testutil"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/stretchr/testify/assert"
+)
+
+func initMockClient(lm *triggers.MockLambdaClient, cm *triggers.MockCloudWatchClient) {
+	triggers.SetMockDefaultBehaviour(lm, cm)
+	triggers.LambdaClient = lm
+	triggers.CloudWatchClient = cm
+	stats.Now = func() time.Time { return time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC) }
+}
+
+func TestOutput(t *testing.T) {
+	t.Run("table format", func(t *testing.T) {
+		lm := &triggers.MockLambdaClient{}
+		cm := &triggers.MockCloudWatchClient{}
+		initMockClient(lm, cm)
+
+		expected := "|    FUNCTION     |  SOURCE  | RESOURCE |  STATE   | BATCH SIZE |          LAST RESULT          | FUNCTION ITERATOR AGE |                 WARNING                 |\n"
+		expected += "|-----------------|----------|----------|----------|------------|-------------------------------|-----------------------|-----------------------------------------|\n"
+		expected += "| audit           | dynamodb | users    | Enabled  |        100 | PROBLEM: Function call failed |                    2s | failing (PROBLEM: Function call failed) |\n"
+		expected += "| audit           | kafka    | events   | Disabled |        500 | -                             |                     - | disabled                                |\n"
+		expected += "| clicks-consumer | kinesis  | clicks   | Enabled  |        100 | OK                            |                 15m0s | function lagging (iterator age 15m0s)   |\n"
+		expected += "| orders-worker   | sqs      | orders   | Enabled  |         10 | No records processed          |                     - | deprecated runtime nodejs12.x           |\n"
+		expected += "\n"
+
+		out, _, err := testutil.Execute(triggers.NewCmd())
+		assert.Nil(t, err)
+		assert.Equal(t, expected, out)
+		params := cm.Calls[0].Arguments.Get(0).(*cloudwatch.GetMetricDataInput)
+		assert.Equal(t, 2, len(params.MetricDataQueries))
+		stat := params.MetricDataQueries[0].MetricStat
+		assert.Equal(t, "IteratorAge", aws.StringValue(stat.Metric.MetricName))
+		assert.Equal(t, "clicks-consumer", aws.StringValue(stat.Metric.Dimensions[0].Value))
+		assert.Equal(t, "Maximum", aws.StringValue(stat.Stat))
+	})
+
+	t.Run("json format", func(t *testing.T) {
+		lm := &triggers.MockLambdaClient{}
+		cm := &triggers.MockCloudWatchClient{}
+		initMockClient(lm, cm)
+
+		expected := "[{\"uuid\":\"3\",\"function\":\"audit\",\"runtime\":\"python3.12\",\"source\":\"dynamodb\",\"event_source_arn\":\"arn:aws:dynamodb:ap-northeast-1:123456789012:table/users/stream/2024-01-01T00:00:00.000\",\"state\":\"Enabled\",\"batch_size\":100,\"last_processing_result\":\"PROBLEM: Function call failed\",\"function_iterator_age\":1500,\"warnings\":[\"failing (PROBLEM: Function call failed)\"]},"
+		expected += "{\"uuid\":\"4\",\"function\":\"audit\",\"runtime\":\"python3.12\",\"source\":\"kafka\",\"event_source_arn\":\"arn:aws:kafka:ap-northeast-1:123456789012:cluster/events/abcd-1234\",\"state\":\"Disabled\",\"batch_size\":500,\"last_processing_result\":\"\",\"function_iterator_age\":null,\"warnings\":[\"disabled\"]},"
+		expected += "{\"uuid\":\"2\",\"function\":\"clicks-consumer\",\"runtime\":\"python3.12\",\"source\":\"kinesis\",\"event_source_arn\":\"arn:aws:kinesis:ap-northeast-1:123456789012:stream/clicks\",\"state\":\"Enabled\",\"batch_size\":100,\"last_processing_result\":\"OK\",\"function_iterator_age\":900000,\"warnings\":[\"function lagging (iterator age 15m0s)\"]},"
+		expected += "{\"uuid\":\"1\",\"function\":\"orders-worker\",\"runtime\":\"nodejs12.x\",\"source\":\"sqs\",\"event_source_arn\":\"arn:aws:sqs:ap-northeast-1:123456789012:orders\",\"state\":\"Enabled\",\"batch_size\":10,\"last_processing_result\":\"No records processed\",\"function_iterator_age\":null,\"warnings\":[\"deprecated runtime nodejs12.x\"]}]\n"
+
+		out, _, err := testutil.Execute(triggers.NewCmd(), "--format", "json")
+		assert.Nil(t, err)
+		assert.Equal(t, expected, out)
+	})
+
+	t.Run("max iterator age", func(t *testing.T) {
+		lm := &triggers.MockLambdaClient{}
+		cm := &triggers.MockCloudWatchClient{}
+		initMockClient(lm, cm)
+
+		cmd := triggers.NewCmd()
+		cmd.Flags().Set("max-iterator-age", "1s")
+		mappings, err := triggers.FetchData(cmd, []string{})
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"failing (PROBLEM: Function call failed)", "function lagging (iterator age 2s)"}, mappings[0].Warnings)
+	})
+
+	t.Run("one query per function", func(t *testing.T) {
+		lm := &triggers.MockLambdaClient{}
+		cm := &triggers.MockCloudWatchClient{}
+		lm.On("ListEventSourceMappings", &lambda.ListEventSourceMappingsInput{MaxItems: aws.Int64(100)}).Return(
+			&lambda.ListEventSourceMappingsOutput{
+				EventSourceMappings: []*lambda.EventSourceMappingConfiguration{
+					{UUID: aws.String("1"), FunctionArn: aws.String("arn:aws:lambda:ap-northeast-1:123456789012:function:audit"), EventSourceArn: aws.String("arn:aws:kinesis:ap-northeast-1:123456789012:stream/clicks")},
+					{UUID: aws.String("2"), FunctionArn: aws.String("arn:aws:lambda:ap-northeast-1:123456789012:function:audit"), EventSourceArn: aws.String("arn:aws:kinesis:ap-northeast-1:123456789012:stream/views")},
+				},
+			},
+			nil,
+		)
+		initMockClient(lm, cm)
+
+		mappings, err := triggers.FetchData(triggers.NewCmd(), []string{})
+		assert.Nil(t, err)
+		params := cm.Calls[0].Arguments.Get(0).(*cloudwatch.GetMetricDataInput)
+		assert.Equal(t, 1, len(params.MetricDataQueries))
+		assert.Equal(t, int64(900000), *mappings[0].FunctionIteratorAge)
+		assert.Equal(t, int64(900000), *mappings[1].FunctionIteratorAge)
+	})
+
+	t.Run("no mapping", func(t *testing.T) {
+		lm := &triggers.MockLambdaClient{}
+		cm := &triggers.MockCloudWatchClient{}
+		lm.On("ListEventSourceMappings", &lambda.ListEventSourceMappingsInput{MaxItems: aws.Int64(100)}).Return(
+			&lambda.ListEventSourceMappingsOutput{},
+			nil,
+		)
+		initMockClient(lm, cm)
+
+		out, _, err := testutil.Execute(triggers.NewCmd())
+		assert.Nil(t, err)
+		assert.Equal(t, "no event source mapping found.\n", out)
+		cm.AssertNotCalled(t, "GetMetricData")
+	})
+
+	t.Run("invalid days", func(t *testing.T) {
+		lm := &triggers.MockLambdaClient{}
+		cm := &triggers.MockCloudWatchClient{}
+		initMockClient(lm, cm)
+
+		_, _, err := testutil.Execute(triggers.NewCmd(), "--days", "0")
+		assert.EqualError(t, err, "--days must be between 1 and 455")
+	})
+}
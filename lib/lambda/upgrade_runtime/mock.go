@@ -0,0 +1,82 @@
+package upgrade_runtime
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockLambdaClient struct {
+	mock.Mock
+	lambdaiface.LambdaAPI
+}
+
+func (client *MockLambdaClient) ListFunctions(params *lambda.ListFunctionsInput) (*lambda.ListFunctionsOutput, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*lambda.ListFunctionsOutput), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
+func (client *MockLambdaClient) ListTags(params *lambda.ListTagsInput) (*lambda.ListTagsOutput, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*lambda.ListTagsOutput), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
+func (client *MockLambdaClient) UpdateFunctionConfiguration(params *lambda.UpdateFunctionConfigurationInput) (*lambda.FunctionConfiguration, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*lambda.FunctionConfiguration), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
+func (client *MockLambdaClient) GetFunctionConfiguration(params *lambda.GetFunctionConfigurationInput) (*lambda.FunctionConfiguration, error) {
+	args := client.Called(params)
+	if args.Get(0) != nil {
+		return args.Get(0).(*lambda.FunctionConfiguration), args.Error(1)
+	} else {
+		return nil, args.Error(1)
+	}
+}
+
+func (client *MockLambdaClient) WaitUntilFunctionUpdated(params *lambda.GetFunctionConfigurationInput) error {
+	args := client.Called(params)
+	return args.Error(0)
+}
+
+func functionArn(name string) string {
+	return "arn:aws:lambda:ap-northeast-1:123456789012:function:" + name
+}
+
+func SetMockDefaultBehaviour(lm *MockLambdaClient) {
+	lm.On("ListFunctions", &lambda.ListFunctionsInput{MaxItems: aws.Int64(1000)}).Return(
+		&lambda.ListFunctionsOutput{
+			Functions: []*lambda.FunctionConfiguration{
+				{FunctionName: aws.String("api"), FunctionArn: aws.String(functionArn("api")), Runtime: aws.String("nodejs12.x")},
+				{FunctionName: aws.String("web"), FunctionArn: aws.String(functionArn("web")), Runtime: aws.String("nodejs12.x")},
+				{FunctionName: aws.String("batch"), FunctionArn: aws.String(functionArn("batch")), Runtime: aws.String("python3.8")},
+				{FunctionName: aws.String("latest"), FunctionArn: aws.String(functionArn("latest")), Runtime: aws.String("python3.13")},
+				{FunctionName: aws.String("image"), FunctionArn: aws.String(functionArn("image")), PackageType: aws.String("Image")},
+			},
+		},
+		nil,
+	)
+	for _, name := range []string{"api", "batch", "latest", "image"} {
+		lm.On("ListTags", &lambda.ListTagsInput{Resource: aws.String(functionArn(name))}).Return(&lambda.ListTagsOutput{}, nil)
+	}
+	lm.On("ListTags", &lambda.ListTagsInput{Resource: aws.String(functionArn("web"))}).Return(
+		&lambda.ListTagsOutput{Tags: aws.StringMap(map[string]string{"aws:cloudformation:stack-name": "web-stack"})},
+		nil,
+	)
+	lm.On("UpdateFunctionConfiguration", mock.AnythingOfType("*lambda.UpdateFunctionConfigurationInput")).Return(&lambda.FunctionConfiguration{}, nil)
+	lm.On("WaitUntilFunctionUpdated", mock.AnythingOfType("*lambda.GetFunctionConfigurationInput")).Return(nil)
+}
@@ -0,0 +1,231 @@
+package upgrade_runtime
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Blue-Pix/abc/lib/lambda/stats"
+	"github.com/Blue-Pix/abc/lib/util"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+	"github.com/spf13/cobra"
+)
+
+var LambdaClient lambdaiface.LambdaAPI
+
+// stackNameTag is set to resources by CloudFormation.
+const stackNameTag = "aws:cloudformation:stack-name"
+
+var (
+	from        string
+	to          string
+	auto        bool
+	dryRun      bool
+	rollback    bool
+	catalogFile string
+)
+
+func NewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "upgrade-runtime",
+		Short: "Upgrade runtime of Lambda functions",
+		Long: `
+[abc lambda upgrade-runtime]
+This command upgrades runtime of Lambda functions from --from to --to.
+With --auto, each runtime is upgraded to its successor in the runtime catalog of abc lambda stats,
+and without --from, only deprecated runtimes are upgraded.
+
+Functions managed by CloudFormation are skipped with their stack,
+because the change would be reverted by the next stack update.
+Functions are updated one by one, waiting for LastUpdateStatus to be Successful.
+If any update fails, functions already updated are rolled back to the previous runtime,
+including the failed one if its update was accepted but did not complete,
+unless --rollback=false is passed.
+
+Internally it uses aws lambda api.
+Please configure your aws credentials with following policies.
+- lambda:ListFunctions
+- lambda:ListTags
+- lambda:UpdateFunctionConfiguration
+- lambda:GetFunctionConfiguration`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := run(cmd, args)
+			return err
+		},
+	}
+	cmd.Flags().StringVar(&from, "from", "", "runtime to upgrade (e.g. nodejs12.x)")
+	cmd.Flags().StringVar(&to, "to", "", "runtime to upgrade to (e.g. nodejs22.x)")
+	cmd.Flags().BoolVar(&auto, "auto", false, "upgrade to the successor in the runtime catalog")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "(optional) show plan without updating functions")
+	cmd.Flags().BoolVar(&rollback, "rollback", true, "(optional) roll back updated functions if any update fails")
+	cmd.Flags().StringVar(&catalogFile, "runtime-catalog", "", "(optional) JSON file to add or overwrite runtimes of the catalog")
+	return cmd
+}
+
+func run(cmd *cobra.Command, args []string) error {
+	plan, err := FetchData(cmd, args)
+	if err != nil {
+		return err
+	}
+	if len(plan.Upgrades) == 0 && len(plan.Skipped) == 0 {
+		cmd.Println("no function to upgrade.")
+		return nil
+	}
+	cmd.Println("Plan:")
+	for _, u := range plan.Upgrades {
+		cmd.Printf("- %s: %s -> %s\n", u.Function, u.From, u.To)
+	}
+	for _, s := range plan.Skipped {
+		cmd.Printf("- %s: skipped, managed by CloudFormation stack %s\n", s.Function, s.Stack)
+	}
+	if dryRun || len(plan.Upgrades) == 0 {
+		return nil
+	}
+	return Apply(cmd, plan.Upgrades)
+}
+
+// Upgrade is a function to upgrade runtime.
+type Upgrade struct {
+	Function string
+	From     string
+	To       string
+}
+
+// Skipped is a function managed by CloudFormation.
+type Skipped struct {
+	Function string
+	Stack    string
+}
+
+type Plan struct {
+	Upgrades []*Upgrade
+	Skipped  []*Skipped
+}
+
+func validate() error {
+	if auto && to != "" {
+		return errors.New("--to cannot be used with --auto")
+	}
+	if !auto && (from == "" || to == "") {
+		return errors.New("--from and --to, or --auto is required")
+	}
+	return nil
+}
+
+func FetchData(cmd *cobra.Command, args []string) (*Plan, error) {
+	if err := validate(); err != nil {
+		return nil, err
+	}
+	if catalogFile != "" {
+		if err := stats.LoadRuntimeCatalog(catalogFile); err != nil {
+			return nil, err
+		}
+	}
+	initClient(cmd)
+	functions, err := stats.ListFunctions(LambdaClient)
+	if err != nil {
+		return nil, err
+	}
+	plan := &Plan{}
+	for _, f := range functions {
+		runtime := aws.StringValue(f.Runtime)
+		target := targetRuntime(runtime)
+		if target == "" || target == runtime {
+			continue
+		}
+		resp, err := LambdaClient.ListTags(&lambda.ListTagsInput{Resource: f.FunctionArn})
+		if err != nil {
+			return nil, err
+		}
+		if stack := aws.StringValue(resp.Tags[stackNameTag]); stack != "" {
+			plan.Skipped = append(plan.Skipped, &Skipped{Function: aws.StringValue(f.FunctionName), Stack: stack})
+			continue
+		}
+		plan.Upgrades = append(plan.Upgrades, &Upgrade{Function: aws.StringValue(f.FunctionName), From: runtime, To: target})
+	}
+	return plan, nil
+}
+
+// targetRuntime returns the runtime to upgrade to, or empty string if the runtime is not the target.
+func targetRuntime(runtime string) string {
+	if runtime == "" || (from != "" && runtime != from) {
+		return ""
+	}
+	if !auto {
+		return to
+	}
+	r := stats.LookupRuntime(runtime)
+	if r == nil || r.Successor == "" {
+		return ""
+	}
+	if from == "" && !r.IsDeprecated(stats.Now()) {
+		return ""
+	}
+	return r.Successor
+}
+
+func initClient(cmd *cobra.Command) {
+	profile, _ := cmd.Flags().GetString("profile")
+	region, _ := cmd.Flags().GetString("region")
+	if LambdaClient == nil {
+		sess := util.CreateSession(profile, region)
+		LambdaClient = lambda.New(sess)
+	}
+}
+
+// Apply upgrades functions one by one.
+// It stops at the first failure, and rolls back functions already upgraded with --rollback.
+func Apply(cmd *cobra.Command, upgrades []*Upgrade) error {
+	// updated has functions whose runtime may have changed, including one whose update failed after it was accepted.
+	var updated []*Upgrade
+	for _, u := range upgrades {
+		accepted, err := updateRuntime(u.Function, u.To)
+		if accepted {
+			updated = append(updated, u)
+		}
+		if err != nil {
+			cmd.Printf("%s: failed to upgrade to %s.\n", u.Function, u.To)
+			if rollback && len(updated) > 0 {
+				cmd.Printf("Rolling back %d functions.\n", len(updated))
+				rollbackAll(cmd, updated)
+			}
+			return fmt.Errorf("failed to upgrade runtime of %s: %s", u.Function, err)
+		}
+		cmd.Printf("%s: upgraded to %s.\n", u.Function, u.To)
+	}
+	cmd.Printf("All %d functions successfully upgraded.\n", len(updated))
+	return nil
+}
+
+func rollbackAll(cmd *cobra.Command, upgrades []*Upgrade) {
+	for i := len(upgrades) - 1; i >= 0; i-- {
+		u := upgrades[i]
+		if _, err := updateRuntime(u.Function, u.From); err != nil {
+			fmt.Fprintf(cmd.ErrOrStderr(), "failed to roll back %s to %s: %s\n", u.Function, u.From, err)
+			continue
+		}
+		cmd.Printf("%s: rolled back to %s.\n", u.Function, u.From)
+	}
+}
+
+// updateRuntime updates runtime and waits for LastUpdateStatus.
+// It returns whether the update was accepted, which is true even if waiting for the status fails.
+func updateRuntime(name string, runtime string) (bool, error) {
+	_, err := LambdaClient.UpdateFunctionConfiguration(&lambda.UpdateFunctionConfigurationInput{
+		FunctionName: aws.String(name),
+		Runtime:      aws.String(runtime),
+	})
+	if err != nil {
+		return false, err
+	}
+	params := &lambda.GetFunctionConfigurationInput{FunctionName: aws.String(name)}
+	if err := LambdaClient.WaitUntilFunctionUpdated(params); err != nil {
+		resp, getErr := LambdaClient.GetFunctionConfiguration(params)
+		if getErr == nil && aws.StringValue(resp.LastUpdateStatus) == lambda.LastUpdateStatusFailed {
+			return true, fmt.Errorf("%s (%s)", aws.StringValue(resp.LastUpdateStatusReason), aws.StringValue(resp.LastUpdateStatusReasonCode))
+		}
+		return true, err
+	}
+	return true, nil
+}
@@ -0,0 +1,157 @@
+package upgrade_runtime_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Blue-Pix/abc/lib/lambda/stats"
+	"github.com/Blue-Pix/abc/lib/lambda/upgrade_runtime"
+	"github.com/Blue-Pix/abc/lib/util/testutil"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func initMockClient(lm *upgrade_runtime.MockLambdaClient) {
+	upgrade_runtime.SetMockDefaultBehaviour(lm)
+	upgrade_runtime.LambdaClient = lm
+	stats.Now = func() time.Time { return time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC) }
+}
+
+func TestFetchData(t *testing.T) {
+	t.Run("from and to", func(t *testing.T) {
+		lm := &upgrade_runtime.MockLambdaClient{}
+		initMockClient(lm)
+
+		cmd := upgrade_runtime.NewCmd()
+		cmd.Flags().Set("from", "nodejs12.x")
+		cmd.Flags().Set("to", "nodejs20.x")
+		var args []string
+		actual, err := upgrade_runtime.FetchData(cmd, args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := &upgrade_runtime.Plan{
+			Upgrades: []*upgrade_runtime.Upgrade{{Function: "api", From: "nodejs12.x", To: "nodejs20.x"}},
+			Skipped:  []*upgrade_runtime.Skipped{{Function: "web", Stack: "web-stack"}},
+		}
+		assert.Equal(t, expected, actual)
+	})
+
+	t.Run("auto upgrades deprecated runtimes", func(t *testing.T) {
+		lm := &upgrade_runtime.MockLambdaClient{}
+		initMockClient(lm)
+
+		cmd := upgrade_runtime.NewCmd()
+		cmd.Flags().Set("auto", "true")
+		var args []string
+		actual, err := upgrade_runtime.FetchData(cmd, args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := &upgrade_runtime.Plan{
+			Upgrades: []*upgrade_runtime.Upgrade{
+				{Function: "api", From: "nodejs12.x", To: "nodejs22.x"},
+				{Function: "batch", From: "python3.8", To: "python3.13"},
+			},
+			Skipped: []*upgrade_runtime.Skipped{{Function: "web", Stack: "web-stack"}},
+		}
+		assert.Equal(t, expected, actual)
+		lm.AssertNotCalled(t, "ListTags", &lambda.ListTagsInput{Resource: aws.String("arn:aws:lambda:ap-northeast-1:123456789012:function:latest")})
+	})
+
+	t.Run("invalid flags", func(t *testing.T) {
+		lm := &upgrade_runtime.MockLambdaClient{}
+		initMockClient(lm)
+
+		_, _, err := testutil.Execute(upgrade_runtime.NewCmd(), "--from", "nodejs12.x")
+		assert.EqualError(t, err, "--from and --to, or --auto is required")
+		_, _, err = testutil.Execute(upgrade_runtime.NewCmd(), "--auto", "--to", "nodejs22.x")
+		assert.EqualError(t, err, "--to cannot be used with --auto")
+		lm.AssertNumberOfCalls(t, "ListFunctions", 0)
+	})
+}
+
+func TestExecute(t *testing.T) {
+	t.Run("dry run", func(t *testing.T) {
+		lm := &upgrade_runtime.MockLambdaClient{}
+		initMockClient(lm)
+
+		out, _, err := testutil.Execute(upgrade_runtime.NewCmd(), "--auto", "--dry-run")
+		assert.Nil(t, err)
+		expected := "Plan:\n"
+		expected += "- api: nodejs12.x -> nodejs22.x\n"
+		expected += "- batch: python3.8 -> python3.13\n"
+		expected += "- web: skipped, managed by CloudFormation stack web-stack\n"
+		assert.Equal(t, expected, out)
+		lm.AssertNumberOfCalls(t, "UpdateFunctionConfiguration", 0)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		lm := &upgrade_runtime.MockLambdaClient{}
+		initMockClient(lm)
+
+		out, _, err := testutil.Execute(upgrade_runtime.NewCmd(), "--auto", "--from", "python3.8")
+		assert.Nil(t, err)
+		expected := "Plan:\n"
+		expected += "- batch: python3.8 -> python3.13\n"
+		expected += "batch: upgraded to python3.13.\n"
+		expected += "All 1 functions successfully upgraded.\n"
+		assert.Equal(t, expected, out)
+		lm.AssertCalled(t, "UpdateFunctionConfiguration", &lambda.UpdateFunctionConfigurationInput{FunctionName: aws.String("batch"), Runtime: aws.String("python3.13")})
+		lm.AssertCalled(t, "WaitUntilFunctionUpdated", &lambda.GetFunctionConfigurationInput{FunctionName: aws.String("batch")})
+	})
+
+	t.Run("rollback on failure", func(t *testing.T) {
+		lm := &upgrade_runtime.MockLambdaClient{}
+		lm.On("WaitUntilFunctionUpdated", &lambda.GetFunctionConfigurationInput{FunctionName: aws.String("batch")}).Return(errors.New("ResourceNotReady: failed waiting for successful resource state")).Once()
+		lm.On("GetFunctionConfiguration", &lambda.GetFunctionConfigurationInput{FunctionName: aws.String("batch")}).Return(
+			&lambda.FunctionConfiguration{
+				LastUpdateStatus:           aws.String("Failed"),
+				LastUpdateStatusReason:     aws.String("The role defined for the function cannot be assumed by Lambda."),
+				LastUpdateStatusReasonCode: aws.String("InvalidConfiguration"),
+			},
+			nil,
+		)
+		initMockClient(lm)
+
+		out, _, err := testutil.Execute(upgrade_runtime.NewCmd(), "--auto")
+		assert.EqualError(t, err, "failed to upgrade runtime of batch: The role defined for the function cannot be assumed by Lambda. (InvalidConfiguration)")
+		expected := "Plan:\n"
+		expected += "- api: nodejs12.x -> nodejs22.x\n"
+		expected += "- batch: python3.8 -> python3.13\n"
+		expected += "- web: skipped, managed by CloudFormation stack web-stack\n"
+		expected += "api: upgraded to nodejs22.x.\n"
+		expected += "batch: failed to upgrade to python3.13.\n"
+		expected += "Rolling back 2 functions.\n"
+		expected += "batch: rolled back to python3.8.\n"
+		expected += "api: rolled back to nodejs12.x.\n"
+		assert.Equal(t, expected, out)
+		lm.AssertCalled(t, "UpdateFunctionConfiguration", &lambda.UpdateFunctionConfigurationInput{FunctionName: aws.String("batch"), Runtime: aws.String("python3.8")})
+		lm.AssertCalled(t, "UpdateFunctionConfiguration", &lambda.UpdateFunctionConfigurationInput{FunctionName: aws.String("api"), Runtime: aws.String("nodejs12.x")})
+	})
+
+	t.Run("no rollback", func(t *testing.T) {
+		lm := &upgrade_runtime.MockLambdaClient{}
+		lm.On("UpdateFunctionConfiguration", &lambda.UpdateFunctionConfigurationInput{FunctionName: aws.String("batch"), Runtime: aws.String("python3.13")}).Return(nil, errors.New("ResourceConflictException"))
+		initMockClient(lm)
+
+		out, _, err := testutil.Execute(upgrade_runtime.NewCmd(), "--auto", "--rollback=false")
+		assert.EqualError(t, err, "failed to upgrade runtime of batch: ResourceConflictException")
+		assert.NotContains(t, out, "Rolling back")
+		lm.AssertNotCalled(t, "UpdateFunctionConfiguration", &lambda.UpdateFunctionConfigurationInput{FunctionName: aws.String("api"), Runtime: aws.String("nodejs12.x")})
+		lm.AssertNotCalled(t, "GetFunctionConfiguration", mock.Anything)
+	})
+	t.Run("no rollback of rejected update", func(t *testing.T) {
+		lm := &upgrade_runtime.MockLambdaClient{}
+		lm.On("UpdateFunctionConfiguration", &lambda.UpdateFunctionConfigurationInput{FunctionName: aws.String("batch"), Runtime: aws.String("python3.13")}).Return(nil, errors.New("ResourceConflictException"))
+		initMockClient(lm)
+
+		out, _, err := testutil.Execute(upgrade_runtime.NewCmd(), "--auto")
+		assert.EqualError(t, err, "failed to upgrade runtime of batch: ResourceConflictException")
+		assert.Contains(t, out, "Rolling back 1 functions.\napi: rolled back to nodejs12.x.\n")
+		lm.AssertNotCalled(t, "UpdateFunctionConfiguration", &lambda.UpdateFunctionConfigurationInput{FunctionName: aws.String("batch"), Runtime: aws.String("python3.8")})
+	})
+}
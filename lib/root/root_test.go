@@ -102,7 +102,7 @@ func TestExecute(t *testing.T) {
 				em := &purge_stack.MockEcrClient{}
 				purge_stack.SetMockDefaultBehaviour(cm, em)
 				purge_stack.CfnClient = cm
-				purge_stack.EcrClient = em
+				purge_stack.Register(&purge_stack.EcrHandler{Client: em})
 
 				b := bytes.NewBufferString("")
 				cmd.SetOut(b)
@@ -111,7 +111,7 @@ func TestExecute(t *testing.T) {
 				if err != nil {
 					t.Fatal(err)
 				}
-				expected := "All images in ecr1 successfully deleted.\nPerform delete-stack is in progress asynchronously.\nPlease check deletion status by yourself.\n"
+				expected := "foo\nPlan:\n- [ecr] foo ecr1 (AWS::ECR::Repository): delete 4 images\necr1: 4/4 images deleted.\nAll images in ecr1 successfully deleted.\nPerform delete-stack is in progress asynchronously.\nPlease check deletion status by yourself.\n"
 				assert.Equal(t, expected, string(out))
 			})
 		})
@@ -218,7 +218,7 @@ func TestExecute(t *testing.T) {
 					if err != nil {
 						t.Fatal(err)
 					}
-					expected := "[{\"runtime\":\"dotnetcore1.0\",\"count\":1,\"deprecated\":true},"
+					expected := "[{\"runtime\":\"dotnetcore1.0\",\"count\":1,\"deprecated\":true,\"deprecation_date\":\"2019-07-30\",\"successor\":\"dotnet8\"},"
 					assert.True(t, strings.HasPrefix(string(out), expected))
 				})
 
@@ -242,7 +242,7 @@ func TestExecute(t *testing.T) {
 					if err != nil {
 						t.Fatal(err)
 					}
-					expected := "[{\"runtime\":\"dotnetcore1.0\",\"count\":1,\"functions\":[\"dotnet1.0-func-1\"],\"deprecated\":true},"
+					expected := "[{\"runtime\":\"dotnetcore1.0\",\"count\":1,\"functions\":[\"dotnet1.0-func-1\"],\"deprecated\":true,\"deprecation_date\":\"2019-07-30\",\"successor\":\"dotnet8\"},"
 					assert.True(t, strings.HasPrefix(string(out), expected))
 				})
 
@@ -0,0 +1,23 @@
+// Package testutil has helpers shared by tests of commands.
+package testutil
+
+import (
+	"bytes"
+
+	"github.com/spf13/cobra"
+)
+
+// Execute runs the command with args, and returns stdout and stderr.
+// Usage and error are not printed, the error is returned instead.
+func Execute(cmd *cobra.Command, args ...string) (string, string, error) {
+	// nil args makes cobra parse os.Args of the test binary.
+	cmd.SetArgs(append([]string{}, args...))
+	out := bytes.NewBufferString("")
+	errOut := bytes.NewBufferString("")
+	cmd.SetOut(out)
+	cmd.SetErr(errOut)
+	cmd.SilenceUsage = true
+	cmd.SilenceErrors = true
+	err := cmd.Execute()
+	return out.String(), errOut.String(), err
+}